@@ -2,8 +2,20 @@ package config
 
 import (
 	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
 	"errors"
+	"fmt"
+	"log"
 	"os"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
@@ -13,8 +25,51 @@ import (
 type Config struct {
 	MongoClient *mongo.Client
 	DBName      string
-	JWTSecret   []byte
-	AESKey      []byte
+	JWTSecret   []byte // HMAC key for OTP hashes; no longer signs tokens
+	AESKey      []byte // legacy AES-CFB key, still used to read records written before AESKeys
+
+	// JWTKeys are the token signing keys (Ed25519 or RSA), by key ID (kid).
+	// New tokens are signed with JWTActiveKeyID; the others still verify and
+	// stay in the JWKS, so a key can be rolled over without logging anyone out.
+	JWTKeys        map[string]crypto.Signer
+	JWTActiveKeyID string
+	JWTIssuer      string // "iss" of every token, checked on the way in
+	JWTAudience    string // "aud" of every token, checked on the way in
+
+	// AESKeys are the AES-256-GCM keys for stored secrets, by key ID. New
+	// ciphertexts use AESActiveKeyID; the others stay readable for rotation.
+	AESKeys        map[string][]byte
+	AESActiveKeyID string
+
+	// MasterKeyProvider names the provider that wraps per-user data keys
+	// (see utils.MasterKeys). "local" wraps them with MasterKeys.
+	MasterKeyProvider string
+
+	// MasterKeys are the local provider's key-wrapping keys, by key ID. They
+	// are kept apart from AESKeys, which only wrapped data keys before
+	// MasterKeys existed and are still read for those.
+	MasterKeys        map[string][]byte
+	MasterActiveKeyID string
+
+	// AttemptStore names where login attempt counters live (see
+	// utils.Attempts): "mongo", shared by every instance, or "memory"
+	AttemptStore string
+
+	// MessageProvider names where SMS and WhatsApp go (see
+	// utils.MessengerFor): "twilio", or "fake", which records every message,
+	// email included, in memory instead of sending it
+	MessageProvider    string
+	TwilioAccountSID   string
+	TwilioAuthToken    string
+	TwilioSMSFrom      string // E.164 number SMS are sent from
+	TwilioWhatsAppFrom string // E.164 number of the WhatsApp sender
+
+	// WebAuthnRPID is the domain passkeys are bound to (the frontend's host
+	// or a parent of it); WebAuthnOrigins are the pages that may use them
+	WebAuthnRPID    string
+	WebAuthnOrigins []string
+
+	DefaultCurrency string // used for properties priced before rate plans existed
 }
 
 func LoadConfig() (*Config, error) {
@@ -30,11 +85,70 @@ func LoadConfig() (*Config, error) {
 	if jwt == "" {
 		return nil, errors.New("JWT_SECRET required")
 	}
+	jwtKeys, jwtActiveKeyID, err := loadJWTKeys(os.Getenv("JWT_KEYS"), os.Getenv("JWT_ACTIVE_KEY_ID"), os.Getenv("JWT_DEV_KEY") == "true")
+	if err != nil {
+		return nil, err
+	}
+	issuer := os.Getenv("JWT_ISSUER")
+	if issuer == "" {
+		issuer = "unit-wise"
+	}
+	audience := os.Getenv("JWT_AUDIENCE")
+	if audience == "" {
+		audience = "unit-wise-api"
+	}
+
 	aes := os.Getenv("AES_KEY")
-	if len(aes) != 32 {
+	keys, activeKeyID, err := loadAESKeys(os.Getenv("AES_KEYS"), os.Getenv("AES_ACTIVE_KEY_ID"), aes)
+	if err != nil {
+		return nil, err
+	}
+	if aes != "" && len(aes) != 32 {
 		return nil, errors.New("AES_KEY must be exactly 32 bytes")
 	}
 
+	masterKeys := os.Getenv("MASTER_KEY_PROVIDER")
+	if masterKeys == "" {
+		masterKeys = "local"
+	}
+	var wrapKeys map[string][]byte
+	var wrapActiveKeyID string
+	if masterKeys == "local" {
+		wrapKeys, wrapActiveKeyID, err = loadMasterKeys(os.Getenv("MASTER_KEYS"), os.Getenv("MASTER_ACTIVE_KEY_ID"), keys)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	attempts := os.Getenv("ATTEMPT_STORE")
+	if attempts == "" {
+		attempts = "mongo"
+	}
+
+	messages := os.Getenv("MESSAGE_PROVIDER")
+	if messages == "" {
+		messages = "twilio"
+	}
+
+	rpID := os.Getenv("WEBAUTHN_RP_ID")
+	if rpID == "" {
+		rpID = "localhost"
+	}
+	origins := []string{}
+	for _, origin := range strings.Split(os.Getenv("WEBAUTHN_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	if len(origins) == 0 {
+		origins = []string{"http://localhost:4200"}
+	}
+
+	currency := os.Getenv("DEFAULT_CURRENCY")
+	if currency == "" {
+		currency = "KES"
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoURI))
@@ -45,7 +159,30 @@ func LoadConfig() (*Config, error) {
 		return nil, err
 	}
 
-	cfg := &Config{MongoClient: client, DBName: dbName, JWTSecret: []byte(jwt), AESKey: []byte(aes)}
+	cfg := &Config{
+		MongoClient:        client,
+		DBName:             dbName,
+		JWTSecret:          []byte(jwt),
+		JWTKeys:            jwtKeys,
+		JWTActiveKeyID:     jwtActiveKeyID,
+		JWTIssuer:          issuer,
+		JWTAudience:        audience,
+		AESKey:             []byte(aes),
+		AESKeys:            keys,
+		AESActiveKeyID:     activeKeyID,
+		MasterKeyProvider:  masterKeys,
+		MasterKeys:         wrapKeys,
+		MasterActiveKeyID:  wrapActiveKeyID,
+		AttemptStore:       attempts,
+		MessageProvider:    messages,
+		TwilioAccountSID:   os.Getenv("TWILIO_ACCOUNT_SID"),
+		TwilioAuthToken:    os.Getenv("TWILIO_AUTH_TOKEN"),
+		TwilioSMSFrom:      os.Getenv("TWILIO_SMS_FROM"),
+		TwilioWhatsAppFrom: os.Getenv("TWILIO_WHATSAPP_FROM"),
+		WebAuthnRPID:       rpID,
+		WebAuthnOrigins:    origins,
+		DefaultCurrency:    currency,
+	}
 
 	// ensure indexes
 	// if err := ensureIndexes(cfg); err != nil {
@@ -55,6 +192,186 @@ func LoadConfig() (*Config, error) {
 	return cfg, nil
 }
 
+// loadAESKeys parses AES_KEYS ("id:key,id:key", each key 32 raw bytes or
+// base64 of 32 bytes). Without AES_KEYS the legacy AES_KEY becomes key "k0",
+// so existing deployments keep working with no new settings.
+func loadAESKeys(spec, activeID, legacy string) (map[string][]byte, string, error) {
+	keys := map[string][]byte{}
+
+	if spec == "" {
+		if len(legacy) != 32 {
+			return nil, "", errors.New("AES_KEY must be exactly 32 bytes when AES_KEYS is not set")
+		}
+		keys["k0"] = []byte(legacy)
+		if activeID == "" {
+			activeID = "k0"
+		}
+	}
+
+	if err := parseKeyring("AES_KEYS", spec, keys); err != nil {
+		return nil, "", err
+	}
+
+	if activeID == "" {
+		return nil, "", errors.New("AES_ACTIVE_KEY_ID required when AES_KEYS is set")
+	}
+	if _, ok := keys[activeID]; !ok {
+		return nil, "", fmt.Errorf("AES_ACTIVE_KEY_ID %q is not in AES_KEYS", activeID)
+	}
+	return keys, activeID, nil
+}
+
+// loadMasterKeys parses MASTER_KEYS, in the AES_KEYS format, for the local
+// master key provider. Its key IDs must differ from those in AES_KEYS, so data
+// keys still wrapped under an AES key are found and re-wrapped, and no key may
+// be reused from AES_KEYS.
+func loadMasterKeys(spec, activeID string, aesKeys map[string][]byte) (map[string][]byte, string, error) {
+	if spec == "" {
+		return nil, "", errors.New("MASTER_KEYS required for the local master key provider")
+	}
+	keys := map[string][]byte{}
+	if err := parseKeyring("MASTER_KEYS", spec, keys); err != nil {
+		return nil, "", err
+	}
+	for id, key := range keys {
+		if _, ok := aesKeys[id]; ok {
+			return nil, "", fmt.Errorf("MASTER_KEYS key id %q is already used in AES_KEYS", id)
+		}
+		for _, aesKey := range aesKeys {
+			if subtle.ConstantTimeCompare(key, aesKey) == 1 {
+				return nil, "", fmt.Errorf("MASTER_KEYS key %q must not reuse an AES_KEYS key", id)
+			}
+		}
+	}
+
+	if activeID == "" {
+		return nil, "", errors.New("MASTER_ACTIVE_KEY_ID required when MASTER_KEYS is set")
+	}
+	if _, ok := keys[activeID]; !ok {
+		return nil, "", fmt.Errorf("MASTER_ACTIVE_KEY_ID %q is not in MASTER_KEYS", activeID)
+	}
+	return keys, activeID, nil
+}
+
+// parseKeyring adds the keys of an "id:key,id:key" list to keys, each key 32
+// raw bytes or base64 of 32 bytes. name is the variable, for errors.
+func parseKeyring(name, spec string, keys map[string][]byte) error {
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, raw, ok := strings.Cut(entry, ":")
+		if !ok || id == "" {
+			return fmt.Errorf("%s entry %q must be id:key", name, entry)
+		}
+		key := []byte(raw)
+		if len(key) != 32 {
+			decoded, err := base64.StdEncoding.DecodeString(raw)
+			if err != nil || len(decoded) != 32 {
+				return fmt.Errorf("%s key %q must be 32 bytes or base64 of 32 bytes", name, id)
+			}
+			key = decoded
+		}
+		keys[id] = key
+	}
+	return nil
+}
+
+// loadJWTKeys parses JWT_KEYS ("kid:path,kid:path", each path a PEM private
+// key: PKCS#8 Ed25519 or RSA, or PKCS#1 RSA of at least 2048 bits).
+//
+// To rotate: add the new key to JWT_KEYS so verifiers pick it up from the
+// JWKS, then make it JWT_ACTIVE_KEY_ID, then drop the old key once the
+// tokens it signed have expired (refresh tokens live 7 days).
+//
+// JWT_KEYS is required. Only with JWT_DEV_KEY=true may it be left out, in
+// which case an Ed25519 key is generated for this process alone: every
+// restart logs everyone out, and tokens from one instance fail on another.
+func loadJWTKeys(spec, activeID string, devKey bool) (map[string]crypto.Signer, string, error) {
+	keys := map[string]crypto.Signer{}
+
+	if spec == "" {
+		if !devKey {
+			return nil, "", errors.New("JWT_KEYS required (set JWT_DEV_KEY=true to sign with a temporary key in development)")
+		}
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, "", err
+		}
+		kid := make([]byte, 8)
+		if _, err := rand.Read(kid); err != nil {
+			return nil, "", err
+		}
+		id := "dev-" + hex.EncodeToString(kid)
+		log.Printf("⚠️ JWT_KEYS not set, signing tokens with temporary key %s", id)
+		keys[id] = key
+		return keys, id, nil
+	}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, path, ok := strings.Cut(entry, ":")
+		if !ok || id == "" || path == "" {
+			return nil, "", fmt.Errorf("JWT_KEYS entry %q must be kid:path", entry)
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, "", fmt.Errorf("JWT_KEYS key %q: %w", id, err)
+		}
+		key, err := parseSigningKey(raw)
+		if err != nil {
+			return nil, "", fmt.Errorf("JWT_KEYS key %q: %w", id, err)
+		}
+		keys[id] = key
+	}
+
+	if activeID == "" {
+		return nil, "", errors.New("JWT_ACTIVE_KEY_ID required when JWT_KEYS is set")
+	}
+	if _, ok := keys[activeID]; !ok {
+		return nil, "", fmt.Errorf("JWT_ACTIVE_KEY_ID %q is not in JWT_KEYS", activeID)
+	}
+	return keys, activeID, nil
+}
+
+// parseSigningKey reads one PEM private key, accepting only the key types
+// tokens are signed with
+func parseSigningKey(raw []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		return k, nil
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < 2048 {
+			return nil, errors.New("RSA keys must be at least 2048 bits")
+		}
+		return k, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %T, use Ed25519 or RSA", key)
+}
+
 // func ensureIndexes(cfg *Config) error {
 // 	// db := cfg.MongoClient.Database(cfg.DBName)
 // 	// users unique email
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadJWTKeysRequiresKeysOutsideDev(t *testing.T) {
+	if _, _, err := loadJWTKeys("", "", false); err == nil {
+		t.Fatal("started without JWT_KEYS and without JWT_DEV_KEY")
+	}
+
+	keys, active, err := loadJWTKeys("", "", true)
+	if err != nil {
+		t.Fatalf("dev key: %v", err)
+	}
+	if _, ok := keys[active]; !ok || len(keys) != 1 {
+		t.Fatalf("dev key: active %q not in %v", active, keys)
+	}
+}
+
+func TestLoadJWTKeysFromPEM(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "k1.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The dev flag must not replace configured keys
+	keys, active, err := loadJWTKeys("k1:"+path, "k1", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if active != "k1" || !key.Equal(keys["k1"]) {
+		t.Fatalf("loaded %v, active %q", keys, active)
+	}
+
+	if _, _, err := loadJWTKeys("k1:"+path, "", false); err == nil {
+		t.Error("accepted JWT_KEYS without JWT_ACTIVE_KEY_ID")
+	}
+	if _, _, err := loadJWTKeys("k1:"+path, "k2", false); err == nil {
+		t.Error("accepted a JWT_ACTIVE_KEY_ID missing from JWT_KEYS")
+	}
+}
+
+func TestLoadMasterKeys(t *testing.T) {
+	aesKeys := map[string][]byte{"k1": []byte("0123456789abcdef0123456789abcdef")}
+	master := "m1:" + strings.Repeat("m", 32)
+
+	keys, active, err := loadMasterKeys(master, "m1", aesKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if active != "m1" || string(keys["m1"]) != strings.Repeat("m", 32) {
+		t.Fatalf("loaded %v, active %q", keys, active)
+	}
+
+	cases := map[string][2]string{
+		"missing":               {"", ""},
+		"no active key":         {master, ""},
+		"active key not listed": {master, "m2"},
+		"malformed entry":       {"m1", "m1"},
+		"short key":             {"m1:tooshort", "m1"},
+		"id shared with AES":    {"k1:" + strings.Repeat("m", 32), "k1"},
+		"key reused from AES":   {"m1:0123456789abcdef0123456789abcdef", "m1"},
+	}
+	for name, in := range cases {
+		if _, _, err := loadMasterKeys(in[0], in[1], aesKeys); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
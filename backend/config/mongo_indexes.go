@@ -1,7 +1,15 @@
 package config
 
 import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/phillip/backend/models"
 )
 
 // EnsureCategoryIndexes creates indexes for the categories collection
@@ -29,7 +37,259 @@ import (
 // 	}
 // }
 
+// EnsureBookingIndexes backs the overlap lookup done on every booking write
+func EnsureBookingIndexes(client *mongo.Client, dbName string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	col := client.Database(dbName).Collection("bookings")
+
+	overlapIdx := mongo.IndexModel{
+		Keys:    bson.D{{Key: "property_id", Value: 1}, {Key: "start_date", Value: 1}, {Key: "end_date", Value: 1}},
+		Options: options.Index().SetBackground(true),
+	}
+
+	_, err := col.Indexes().CreateMany(ctx, []mongo.IndexModel{overlapIdx})
+	if err != nil {
+		log.Printf("⚠️ Could not create booking indexes: %v", err)
+	} else {
+		log.Println("✅ Booking indexes ensured")
+	}
+}
+
+// EnsureCleaningTaskIndexes backs the housekeeper agenda and the per-booking task lookup
+func EnsureCleaningTaskIndexes(client *mongo.Client, dbName string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	col := client.Database(dbName).Collection("cleaning_tasks")
+
+	agendaIdx := mongo.IndexModel{
+		Keys:    bson.D{{Key: "housekeeper_id", Value: 1}, {Key: "scheduled_for", Value: 1}},
+		Options: options.Index().SetBackground(true),
+	}
+
+	bookingIdx := mongo.IndexModel{
+		Keys:    bson.D{{Key: "booking_id", Value: 1}},
+		Options: options.Index().SetBackground(true),
+	}
+
+	_, err := col.Indexes().CreateMany(ctx, []mongo.IndexModel{agendaIdx, bookingIdx})
+	if err != nil {
+		log.Printf("⚠️ Could not create cleaning task indexes: %v", err)
+	} else {
+		log.Println("✅ Cleaning task indexes ensured")
+	}
+
+	// One live (not cancelled) task per booking, so concurrent booking
+	// updates can't both schedule a turnover. Kept apart from the indexes
+	// above: it fails to build while duplicates from before it exist.
+	oneTaskIdx := mongo.IndexModel{
+		Keys: bson.D{{Key: "booking_id", Value: 1}},
+		Options: options.Index().
+			SetName("booking_id_live_unique").
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"status": bson.M{"$in": models.LiveTaskStatuses}}).
+			SetBackground(true),
+	}
+	if _, err := col.Indexes().CreateOne(ctx, oneTaskIdx); err != nil {
+		log.Printf("⚠️ Could not create unique cleaning task index (cancel duplicate tasks per booking first): %v", err)
+	}
+}
+
+// EnsureCredentialAuditIndexes backs the per-credential and per-actor history queries
+func EnsureCredentialAuditIndexes(client *mongo.Client, dbName string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	col := client.Database(dbName).Collection("credential_audit")
+
+	credentialIdx := mongo.IndexModel{
+		Keys:    bson.D{{Key: "credential_id", Value: 1}, {Key: "created_at", Value: -1}},
+		Options: options.Index().SetBackground(true),
+	}
+
+	actorIdx := mongo.IndexModel{
+		Keys:    bson.D{{Key: "actor_id", Value: 1}, {Key: "created_at", Value: -1}},
+		Options: options.Index().SetBackground(true),
+	}
+
+	_, err := col.Indexes().CreateMany(ctx, []mongo.IndexModel{credentialIdx, actorIdx})
+	if err != nil {
+		log.Printf("⚠️ Could not create credential audit indexes: %v", err)
+	} else {
+		log.Println("✅ Credential audit indexes ensured")
+	}
+}
+
+// EnsureVaultItemIndexes backs listing a user's vault items by name
+func EnsureVaultItemIndexes(client *mongo.Client, dbName string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	col := client.Database(dbName).Collection("vault_items")
+
+	ownerIdx := mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "name", Value: 1}},
+		Options: options.Index().SetBackground(true),
+	}
+
+	if _, err := col.Indexes().CreateOne(ctx, ownerIdx); err != nil {
+		log.Printf("⚠️ Could not create vault item indexes: %v", err)
+	} else {
+		log.Println("✅ Vault item indexes ensured")
+	}
+}
+
+// EnsureMFAChallengeIndexes looks challenges up by token hash and lets
+// MongoDB drop them once expired
+func EnsureMFAChallengeIndexes(client *mongo.Client, dbName string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	col := client.Database(dbName).Collection("mfa_challenges")
+
+	tokenIdx := mongo.IndexModel{
+		Keys:    bson.D{{Key: "token_hash", Value: 1}},
+		Options: options.Index().SetUnique(true).SetBackground(true),
+	}
+
+	expiryIdx := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0).SetBackground(true),
+	}
+
+	_, err := col.Indexes().CreateMany(ctx, []mongo.IndexModel{tokenIdx, expiryIdx})
+	if err != nil {
+		log.Printf("⚠️ Could not create MFA challenge indexes: %v", err)
+	} else {
+		log.Println("✅ MFA challenge indexes ensured")
+	}
+}
+
+// EnsureRateLimitIndexes lets MongoDB drop attempt counters once their
+// window and any lockout are over
+func EnsureRateLimitIndexes(client *mongo.Client, dbName string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	col := client.Database(dbName).Collection("rate_limits")
+
+	expiryIdx := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0).SetBackground(true),
+	}
+
+	if _, err := col.Indexes().CreateOne(ctx, expiryIdx); err != nil {
+		log.Printf("⚠️ Could not create rate limit indexes: %v", err)
+	} else {
+		log.Println("✅ Rate limit indexes ensured")
+	}
+}
+
+// EnsureUserIndexes lets MongoDB delete registrations whose email was never
+// verified, freeing their email and phone
+func EnsureUserIndexes(client *mongo.Client, dbName string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	col := client.Database(dbName).Collection("users")
+
+	pendingIdx := mongo.IndexModel{
+		Keys:    bson.D{{Key: "pending_expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0).SetBackground(true),
+	}
+
+	if _, err := col.Indexes().CreateOne(ctx, pendingIdx); err != nil {
+		log.Printf("⚠️ Could not create user indexes: %v", err)
+	} else {
+		log.Println("✅ User indexes ensured")
+	}
+}
+
+// EnsureSessionIndexes lists a user's sessions by recent use and lets MongoDB
+// drop sessions and denylisted tokens once they have expired
+func EnsureSessionIndexes(client *mongo.Client, dbName string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	sessions := client.Database(dbName).Collection("sessions")
+
+	userIdx := mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "last_used_at", Value: -1}},
+		Options: options.Index().SetBackground(true),
+	}
+
+	expiryIdx := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0).SetBackground(true),
+	}
+
+	if _, err := sessions.Indexes().CreateMany(ctx, []mongo.IndexModel{userIdx, expiryIdx}); err != nil {
+		log.Printf("⚠️ Could not create session indexes: %v", err)
+		return
+	}
+
+	revoked := client.Database(dbName).Collection("revoked_tokens")
+	if _, err := revoked.Indexes().CreateOne(ctx, expiryIdx); err != nil {
+		log.Printf("⚠️ Could not create revoked token indexes: %v", err)
+	} else {
+		log.Println("✅ Session indexes ensured")
+	}
+}
+
+// EnsurePasskeyIndexes keeps credential IDs unique, lists a user's passkeys,
+// and lets MongoDB drop unanswered passkey challenges
+func EnsurePasskeyIndexes(client *mongo.Client, dbName string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	passkeys := client.Database(dbName).Collection("passkeys")
+
+	credentialIdx := mongo.IndexModel{
+		Keys:    bson.D{{Key: "credential_id", Value: 1}},
+		Options: options.Index().SetUnique(true).SetBackground(true),
+	}
+
+	userIdx := mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}},
+		Options: options.Index().SetBackground(true),
+	}
+
+	if _, err := passkeys.Indexes().CreateMany(ctx, []mongo.IndexModel{credentialIdx, userIdx}); err != nil {
+		log.Printf("⚠️ Could not create passkey indexes: %v", err)
+		return
+	}
+
+	challenges := client.Database(dbName).Collection("passkey_challenges")
+
+	challengeIdx := mongo.IndexModel{
+		Keys:    bson.D{{Key: "challenge_hash", Value: 1}},
+		Options: options.Index().SetUnique(true).SetBackground(true),
+	}
+
+	expiryIdx := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0).SetBackground(true),
+	}
+
+	if _, err := challenges.Indexes().CreateMany(ctx, []mongo.IndexModel{challengeIdx, expiryIdx}); err != nil {
+		log.Printf("⚠️ Could not create passkey challenge indexes: %v", err)
+	} else {
+		log.Println("✅ Passkey indexes ensured")
+	}
+}
+
 // EnsureAllIndexes creates indexes for all collections
 func EnsureAllIndexes(client *mongo.Client, dbName string) {
 	// EnsureCategoryIndexes(client, dbName)
+	EnsureBookingIndexes(client, dbName)
+	EnsureCleaningTaskIndexes(client, dbName)
+	EnsureCredentialAuditIndexes(client, dbName)
+	EnsureVaultItemIndexes(client, dbName)
+	EnsureMFAChallengeIndexes(client, dbName)
+	EnsureRateLimitIndexes(client, dbName)
+	EnsureSessionIndexes(client, dbName)
+	EnsureUserIndexes(client, dbName)
+	EnsurePasskeyIndexes(client, dbName)
 }
@@ -2,8 +2,7 @@ package controllers
 
 import (
 	"context"
-	"fmt"
-	"math/rand"
+	"log"
 	"net/http"
 	"strings"
 	"time"
@@ -26,7 +25,7 @@ func Register(cfg *config.Config) gin.HandlerFunc {
 		var input struct {
 			Name  string `json:"name" binding:"required"`
 			Email string `json:"email" binding:"required,email"`
-			Role  string `json:"role" binding:"required"`
+			Role  string `json:"role" binding:"required,oneof=host manager cleaner guest"` // admins are never self-registered
 			Phone  string `json:"phone" binding:"required"`
 		}
 
@@ -39,6 +38,18 @@ func Register(cfg *config.Config) gin.HandlerFunc {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
+		if !checkThrottles(ctx, c, cfg, throttleCheck{otpIssuePerIP, c.ClientIP()}) {
+			return
+		}
+
+		// Registrations nobody verified in time no longer hold their email or phone
+		now := time.Now()
+		users.DeleteMany(ctx, bson.M{
+			"status":             models.UserPending,
+			"pending_expires_at": bson.M{"$lte": now},
+			"$or":                bson.A{bson.M{"email": input.Email}, bson.M{"phone": input.Phone}},
+		})
+
 		// Check if email already exists
 		count, _ := users.CountDocuments(ctx, bson.M{"email": input.Email})
 		if count > 0 {
@@ -53,14 +64,19 @@ func Register(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
+		// Pending until the emailed code is verified, and deleted if it never is
+		expires := now.Add(unverifiedAccountTTL)
 		user := models.User{
 			ID:        primitive.NewObjectID(),
 			Name:      input.Name,
 			Email:     input.Email,
 			Phone:     input.Phone,
 			Role:     input.Role,
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
+			CreatedAt: now,
+			UpdatedAt: now,
+
+			Status:           models.UserPending,
+			PendingExpiresAt: &expires,
 		}
 
 		// Insert new user
@@ -70,25 +86,27 @@ func Register(cfg *config.Config) gin.HandlerFunc {
 		}
 
 		// Generate OTP
-		otp := fmt.Sprintf("%06d", rand.Intn(1000000))
-		expiry := time.Now().Add(10 * time.Minute)
-		users.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"otp": otp, "otp_expiry": expiry}})
+		otp, ok := issueOTP(ctx, c, cfg, user, models.ChannelEmail)
+		if !ok {
+			return
+		}
 
 		// Send OTP
-		body := utils.BuildOtpEmail(user.Email, otp)
-		go utils.SendEmail(user.Email, "Verify your account", body)
+		sendCode(cfg, user, models.ChannelEmail, user.Email, "Verify your account", otp)
 
 
 
 		c.JSON(http.StatusCreated, gin.H{
 			"status":  200,
 			"message": "Registration successful, OTP sent to email",
+			"expires_in": int(unverifiedAccountTTL.Seconds()),
 			"user": gin.H{
 				"id":    user.ID.Hex(),
 				"name":  user.Name,
 				"email": user.Email,
 				"phone": user.Phone,
 				"role": user.Role,
+				"status": user.Status,
 			},
 		})
 	}
@@ -114,9 +132,10 @@ func Login(cfg *config.Config) gin.HandlerFunc {
 
 		var user models.User
 		filter := bson.M{}
+		byPhone := !strings.Contains(input.Email, "@")
 
 		// Decide if input is email or phone
-		if strings.Contains(input.Email, "@") {
+		if !byPhone {
 			// Treat as email
 			filter = bson.M{"email": input.Email}
 		} else {
@@ -124,31 +143,30 @@ func Login(cfg *config.Config) gin.HandlerFunc {
 			filter = bson.M{"phone": input.Email}
 		}
 
+		if !checkThrottles(ctx, c, cfg, throttleCheck{otpIssuePerIP, c.ClientIP()}) {
+			return
+		}
+
 		if err := users.FindOne(ctx, filter).Decode(&user); err != nil {
+			// Lookups count against the IP too, to slow down account enumeration
+			hitThrottles(ctx, cfg, throttleCheck{otpIssuePerIP, c.ClientIP()})
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
 			return
 		}
 
-		// Generate OTP
-		otp := fmt.Sprintf("%06d", rand.Intn(1000000))
-		expiry := time.Now().Add(10 * time.Minute)
-
-		_, err := users.UpdateOne(ctx,
-			bson.M{"_id": user.ID},
-			bson.M{"$set": bson.M{"otp": otp, "otp_expiry": expiry}},
-		)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not save OTP"})
+		// Send it the way they signed in: phone logins get it by SMS or WhatsApp
+		channel := otpChannel(cfg, user, byPhone)
+		otp, ok := issueOTP(ctx, c, cfg, user, channel)
+		if !ok {
 			return
 		}
 
-		// Send OTP (always to associated email)
-		body := utils.BuildOtpEmail(user.Email, otp)
-		go utils.SendEmail(user.Email, "Your Login OTP", body)
+		sendLoginCode(cfg, user, channel, otp)
 
 		c.JSON(http.StatusOK, gin.H{
 			"status":  200,
-			"message": "OTP sent to email",
+			"message": "OTP sent by " + channel,
+			"channel": channel,
 		})
 	}
 }
@@ -160,54 +178,100 @@ func Login(cfg *config.Config) gin.HandlerFunc {
 func VerifyOTP(cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var input struct {
-			Email string `json:"email" binding:"required,email"`
+			Email string `json:"email" binding:"omitempty,email"`
+			Phone string `json:"phone"` // for codes sent to the phone
 			OTP   string `json:"otp" binding:"required"`
 		}
 		if err := c.ShouldBindJSON(&input); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		filter := bson.M{"email": input.Email}
+		if input.Email == "" {
+			if input.Phone == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "email or phone required"})
+				return
+			}
+			filter = bson.M{"phone": input.Phone}
+		}
 
 		users := cfg.MongoClient.Database(cfg.DBName).Collection("users")
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
+		byIP := throttleCheck{otpVerifyPerIP, c.ClientIP()}
+		if !checkThrottles(ctx, c, cfg, byIP) {
+			return
+		}
+
 		var user models.User
-		if err := users.FindOne(ctx, bson.M{"email": input.Email}).Decode(&user); err != nil {
+		if err := users.FindOne(ctx, filter).Decode(&user); err != nil {
+			hitThrottles(ctx, cfg, byIP)
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid otp"})
 			return
 		}
 
+		byAccount := throttleCheck{otpVerifyPerAccount, user.ID.Hex()}
+		if !checkThrottles(ctx, c, cfg, byAccount) {
+			return
+		}
+
+		// Left for the TTL monitor to delete
+		if user.Pending() && user.PendingExpiresAt != nil && time.Now().After(*user.PendingExpiresAt) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "registration expired, please register again"})
+			return
+		}
+
 		// Check OTP
-		if user.OTP != input.OTP || time.Now().After(user.OTPExpiry) {
+		if time.Now().After(user.OTPExpiry) || !utils.CheckOTP(cfg, user.ID, user.OTP, input.OTP) {
+			hitThrottles(ctx, cfg, byIP)
+			if hitThrottles(ctx, cfg, byAccount) > 0 {
+				// Locked out: this code is spent, the user must request a new one
+				users.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$unset": bson.M{"otp": "", "otp_expiry": "", "otp_channel": ""}})
+			}
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "otp expired or invalid"})
 			return
 		}
 
-		// Clear OTP
-		users.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$unset": bson.M{"otp": "", "otp_expiry": ""}})
+		// Clear OTP; only one of several concurrent requests with it gets through
+		res, err := users.UpdateOne(ctx,
+			bson.M{"_id": user.ID, "otp": user.OTP},
+			bson.M{"$unset": bson.M{"otp": "", "otp_expiry": "", "otp_channel": ""}},
+		)
+		if err != nil || res.ModifiedCount == 0 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "otp expired or invalid"})
+			return
+		}
+		resetThrottles(ctx, cfg, byAccount)
 
-		// Create tokens
-		accessToken, refreshToken, _ := createTokensForUser(user.ID, cfg)
-		users.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"refresh_token": refreshToken}})
+		// A code that went to the user's email verifies the address
+		byEmail := user.OTPChannel == "" || user.OTPChannel == models.ChannelEmail
+		if byEmail && user.EmailVerifiedAt == nil && !activateAccount(ctx, c, cfg, &user) {
+			return
+		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"status":        200,
-			"access_token":  accessToken,
-			"refresh_token": refreshToken,
-			"user": gin.H{
-				"id":    user.ID.Hex(),
-				"name":  user.Name,
-				"email": user.Email,
-				"phone": user.Phone,
-				"role": user.Role,
-			},
-		})
+		// With an authenticator enrolled, the email code is only the first step
+		if user.TwoFactorEnabled() {
+			challenge, err := createMFAChallenge(ctx, cfg, user.ID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "could not start two-factor check"})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{
+				"status":       200,
+				"mfa_required": true,
+				"challenge":    challenge,
+				"expires_in":   int(mfaChallengeTTL.Seconds()),
+			})
+			return
+		}
+
+		issueSession(ctx, c, cfg, user, false)
 	}
 }
 
 // =============================
-// Refresh Token (unchanged)
+// Refresh Token (rotates within the session)
 // =============================
 func RefreshToken(cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -219,11 +283,12 @@ func RefreshToken(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
-		claims := jwt.MapClaims{}
-		token, err := jwt.ParseWithClaims(input.RefreshToken, claims, func(token *jwt.Token) (interface{}, error) {
-			return cfg.JWTSecret, nil
-		})
-		if err != nil || !token.Valid || claims["type"] != "refresh" {
+		claims, err := utils.ParseToken(cfg, input.RefreshToken, utils.RefreshToken)
+		if err != nil {
+			// HS256 tokens from before key-pair signing, rotated out below
+			claims, err = utils.ParseLegacyRefreshToken(cfg, input.RefreshToken)
+		}
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
 			return
 		}
@@ -245,16 +310,95 @@ func RefreshToken(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
-		if user.RefreshToken != input.RefreshToken {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token mismatch"})
+		sid, _ := claims["sid"].(string)
+		jti, _ := claims["jti"].(string)
+
+		// Tokens from before sessions existed are traded once for a session
+		if sid == "" {
+			if user.RefreshToken == "" || user.RefreshToken != input.RefreshToken {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token mismatch"})
+				return
+			}
+			// Only the request that clears the token gets the session
+			res, err := users.UpdateOne(ctx,
+				bson.M{"_id": user.ID, "refresh_token": input.RefreshToken},
+				bson.M{"$unset": bson.M{"refresh_token": ""}},
+			)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "could not refresh session"})
+				return
+			}
+			if res.ModifiedCount != 1 {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token mismatch"})
+				return
+			}
+
+			accessToken, refreshToken, err := startSession(ctx, c, cfg, user, false)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "could not create tokens"})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"access_token": accessToken, "refresh_token": refreshToken})
 			return
 		}
 
-		// Create new tokens
-		accessToken, refreshToken, _ := createTokensForUser(user.ID, cfg)
+		sessionID, err := primitive.ObjectIDFromHex(sid)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
+			return
+		}
+		sessions := cfg.MongoClient.Database(cfg.DBName).Collection("sessions")
 
-		// Rotate refresh token
-		users.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"refresh_token": refreshToken}})
+		var session models.Session
+		if err := sessions.FindOne(ctx, bson.M{"_id": sessionID, "user_id": user.ID}).Decode(&session); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
+			return
+		}
+		if session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "session has ended, sign in again"})
+			return
+		}
+
+		// ✅ Rotate; only the newest token of the family is accepted
+		newJTI, err := utils.RandomToken(16)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not create tokens"})
+			return
+		}
+		now := time.Now()
+		res, err := sessions.UpdateOne(ctx,
+			bson.M{"_id": session.ID, "refresh_jti": jti, "revoked_at": nil},
+			bson.M{"$set": bson.M{
+				"refresh_jti":  newJTI,
+				"last_used_at": now,
+				"expires_at":   now.Add(refreshTokenTTL),
+				"ip":           c.ClientIP(),
+				"user_agent":   c.Request.UserAgent(),
+			}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not refresh session"})
+			return
+		}
+		if res.ModifiedCount == 0 {
+			// An already-rotated token came back: someone else holds a copy
+			if err := revokeSession(ctx, cfg, session.ID, models.RevokedReuse); err != nil {
+				log.Printf("could not revoke session %s after refresh token reuse: %v", session.ID.Hex(), err)
+			}
+			log.Printf("refresh token reuse on session %s (user %s) from %s", session.ID.Hex(), user.ID.Hex(), c.ClientIP())
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token reuse detected, session revoked"})
+			return
+		}
+
+		session.RefreshJTI = newJTI
+		session.ExpiresAt = now.Add(refreshTokenTTL)
+		session.MFA = session.MFA && user.TwoFactorEnabled()
+
+		accessToken, refreshToken, err := createTokensForUser(user.ID, user.Role, session, cfg)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not create tokens"})
+			return
+		}
 
 		c.JSON(http.StatusOK, gin.H{
 			"access_token":  accessToken,
@@ -266,28 +410,147 @@ func RefreshToken(cfg *config.Config) gin.HandlerFunc {
 // =============================
 // Helpers
 // =============================
-func createTokensForUser(uid primitive.ObjectID, cfg *config.Config) (accessToken string, refreshToken string, err error) {
-	// Access Token (short-lived)
+
+const (
+	refreshTokenTTL = 7 * 24 * time.Hour
+
+	// How long a registration may wait for its email to be verified
+	unverifiedAccountTTL = 24 * time.Hour
+)
+
+// activateAccount marks the user's email verified, ending a pending
+// registration. It writes 500 and returns false if that can't be saved.
+func activateAccount(ctx context.Context, c *gin.Context, cfg *config.Config, user *models.User) bool {
+	now := time.Now()
+	_, err := cfg.MongoClient.Database(cfg.DBName).Collection("users").UpdateOne(ctx,
+		bson.M{"_id": user.ID},
+		bson.M{
+			"$set":   bson.M{"status": models.UserActive, "email_verified_at": now, "updated_at": now},
+			"$unset": bson.M{"pending_expires_at": ""},
+		},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not verify account"})
+		return false
+	}
+
+	wasPending := user.Pending()
+	user.Status, user.EmailVerifiedAt, user.PendingExpiresAt = models.UserActive, &now, nil
+
+	// Create their vault key now; if this fails it is created on first use
+	if wasPending {
+		if _, err := utils.UserDataKey(ctx, cfg, user.ID); err != nil {
+			log.Printf("could not create data key for user %s: %v", user.ID.Hex(), err)
+		}
+	}
+	return true
+}
+
+// issueSession starts a session for the user and writes the login response
+func issueSession(ctx context.Context, c *gin.Context, cfg *config.Config, user models.User, mfa bool) {
+	accessToken, refreshToken, err := startSession(ctx, c, cfg, user, mfa)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not create tokens"})
+		return
+	}
+
+	// Tell the client up front when the vault will turn it away until it enrols
+	required, err := utils.MFARequired(ctx, cfg, user.Role)
+	if err != nil {
+		log.Printf("could not load MFA policy: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":                  200,
+		"access_token":            accessToken,
+		"refresh_token":           refreshToken,
+		"mfa_enrollment_required": required && !user.TwoFactorEnabled(),
+		"user": gin.H{
+			"id":    user.ID.Hex(),
+			"name":  user.Name,
+			"email": user.Email,
+			"phone": user.Phone,
+			"role":  user.Role,
+		},
+	})
+}
+
+// startSession records a new device session and signs its first tokens.
+// mfa records that the sign-in passed an authenticator check.
+func startSession(ctx context.Context, c *gin.Context, cfg *config.Config, user models.User, mfa bool) (string, string, error) {
+	jti, err := utils.RandomToken(16)
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
+	session := models.Session{
+		ID:         primitive.NewObjectID(),
+		UserID:     user.ID,
+		RefreshJTI: jti,
+		MFA:        mfa,
+		UserAgent:  c.Request.UserAgent(),
+		IP:         c.ClientIP(),
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(refreshTokenTTL),
+	}
+	if _, err := cfg.MongoClient.Database(cfg.DBName).Collection("sessions").InsertOne(ctx, session); err != nil {
+		return "", "", err
+	}
+
+	return createTokensForUser(user.ID, user.Role, session, cfg)
+}
+
+// revokeSession ends a session: its refresh tokens stop working and its
+// access tokens are denylisted
+func revokeSession(ctx context.Context, cfg *config.Config, sessionID primitive.ObjectID, reason string) error {
+	now := time.Now()
+	_, err := cfg.MongoClient.Database(cfg.DBName).Collection("sessions").UpdateOne(ctx,
+		bson.M{"_id": sessionID, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": now, "revoked_reason": reason}},
+	)
+	if err != nil {
+		return err
+	}
+	return utils.RevokeSessionTokens(ctx, cfg, sessionID.Hex())
+}
+
+// createTokensForUser signs an access token and the session's current refresh
+// token. Both carry the session ID (sid); the access token's own jti lets
+// logout denylist it, and mfa is read by middleware.RequireMFA.
+func createTokensForUser(uid primitive.ObjectID, role string, session models.Session, cfg *config.Config) (accessToken string, refreshToken string, err error) {
+	accessJTI, err := utils.RandomToken(16)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Access Token (short-lived), carries the role for middleware.RequireRole
 	accessClaims := jwt.MapClaims{
 		"user_id": uid.Hex(),
-		"exp":     time.Now().Add(15 * time.Minute).Unix(),
+		"role":    role,
+		"mfa":     session.MFA,
+		"sid":     session.ID.Hex(),
+		"jti":     accessJTI,
+		"exp":     time.Now().Add(utils.AccessTokenTTL).Unix(),
 		"iat":     time.Now().Unix(),
+		"type":    utils.AccessToken,
 	}
-	access := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	accessToken, err = access.SignedString(cfg.JWTSecret)
+	accessToken, err = utils.SignToken(cfg, accessClaims)
 	if err != nil {
 		return "", "", err
 	}
 
-	// Refresh Token (long-lived)
+	// Refresh Token (long-lived), valid only while it is the session's newest
 	refreshClaims := jwt.MapClaims{
 		"user_id": uid.Hex(),
-		"exp":     time.Now().Add(7 * 24 * time.Hour).Unix(),
+		"sid":     session.ID.Hex(),
+		"jti":     session.RefreshJTI,
+		"exp":     session.ExpiresAt.Unix(),
 		"iat":     time.Now().Unix(),
-		"type":    "refresh",
+		"type":    utils.RefreshToken,
 	}
-	refresh := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	refreshToken, err = refresh.SignedString(cfg.JWTSecret)
+	refreshToken, err = utils.SignToken(cfg, refreshClaims)
 	if err != nil {
 		return "", "", err
 	}
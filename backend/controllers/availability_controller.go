@@ -0,0 +1,267 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/phillip/backend/config"
+	"github.com/phillip/backend/models"
+)
+
+const (
+	dayLayout           = "2006-01-02"
+	maxAvailabilityDays = 366
+)
+
+// GetPropertyAvailability - per-night calendar for a property, computed from
+// its bookings and blackouts. Query: from, to (YYYY-MM-DD, to is exclusive).
+func GetPropertyAvailability(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid property ID"})
+			return
+		}
+
+		from, to, err := parseDayRange(c.Query("from"), c.Query("to"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var property models.Property
+		if err := cfg.MongoClient.Database(cfg.DBName).Collection("properties").
+			FindOne(ctx, bson.M{"_id": objID}).Decode(&property); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Property not found"})
+			return
+		}
+
+		cursor, err := cfg.MongoClient.Database(cfg.DBName).Collection("bookings").Find(ctx, bson.M{
+			"property_id": objID,
+			"status":      bson.M{"$in": models.BlockingBookingStatuses},
+			"start_date":  bson.M{"$lt": to},
+			"end_date":    bson.M{"$gt": from},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch bookings"})
+			return
+		}
+		var bookings []models.Booking
+		if err := cursor.All(ctx, &bookings); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not decode bookings"})
+			return
+		}
+
+		nights := []gin.H{}
+		free, booked, blocked := 0, 0, 0
+		for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+			night := gin.H{"date": day.Format(dayLayout), "status": "free"}
+
+			if b := bookingOnNight(bookings, day); b != nil {
+				night["status"] = "booked"
+				night["booking_id"] = b.ID.Hex()
+				booked++
+			} else if bo := blackoutOnNight(property.Blackouts, day); bo != nil {
+				night["status"] = "blocked"
+				night["blackout_id"] = bo.ID.Hex()
+				blocked++
+			} else {
+				free++
+			}
+
+			nights = append(nights, night)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"property_id":    objID.Hex(),
+			"from":           from.Format(dayLayout),
+			"to":             to.Format(dayLayout),
+			"free_nights":    free,
+			"booked_nights":  booked,
+			"blocked_nights": blocked,
+			"nights":         nights,
+		})
+	}
+}
+
+// AddPropertyBlackout - owner blocks a range of nights
+func AddPropertyBlackout(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid property ID"})
+			return
+		}
+
+		var input struct {
+			StartDate string `json:"start_date" binding:"required"`
+			EndDate   string `json:"end_date" binding:"required"`
+			Reason    string `json:"reason"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		start, end, err := parseDayRange(input.StartDate, input.EndDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		col := cfg.MongoClient.Database(cfg.DBName).Collection("properties")
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if _, ok := loadOwnedProperty(ctx, c, cfg, objID); !ok {
+			return
+		}
+
+		blackout := models.Blackout{
+			ID:        primitive.NewObjectID(),
+			StartDate: start,
+			EndDate:   end,
+			Reason:    input.Reason,
+		}
+		_, err = col.UpdateOne(ctx, bson.M{"_id": objID}, bson.M{
+			"$push": bson.M{"blackouts": blackout},
+			"$set":  bson.M{"updated_at": time.Now()},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not add blackout"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, blackout)
+	}
+}
+
+// DeletePropertyBlackout - owner re-opens a blocked range
+func DeletePropertyBlackout(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid property ID"})
+			return
+		}
+		blackoutID, err := primitive.ObjectIDFromHex(c.Param("blackoutId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid blackout ID"})
+			return
+		}
+
+		col := cfg.MongoClient.Database(cfg.DBName).Collection("properties")
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if _, ok := loadOwnedProperty(ctx, c, cfg, objID); !ok {
+			return
+		}
+
+		res, err := col.UpdateOne(ctx,
+			bson.M{"_id": objID, "blackouts._id": blackoutID},
+			bson.M{
+				"$pull": bson.M{"blackouts": bson.M{"_id": blackoutID}},
+				"$set":  bson.M{"updated_at": time.Now()},
+			},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not delete blackout"})
+			return
+		}
+		if res.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Blackout not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Blackout deleted", "id": blackoutID.Hex()})
+	}
+}
+
+// =============================
+// Helpers
+// =============================
+
+// loadOwnedProperty fetches a property and checks the requester owns it (or is an admin).
+// On failure it writes the response and returns false.
+func loadOwnedProperty(ctx context.Context, c *gin.Context, cfg *config.Config, propertyID primitive.ObjectID) (models.Property, bool) {
+	var property models.Property
+	err := cfg.MongoClient.Database(cfg.DBName).Collection("properties").
+		FindOne(ctx, bson.M{"_id": propertyID}).Decode(&property)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Property not found"})
+		return property, false
+	}
+
+	if c.GetString("role") != models.RoleAdmin && property.UserID.Hex() != c.GetString("user_id") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return property, false
+	}
+	return property, true
+}
+
+// parseDayRange parses a [from, to) range of YYYY-MM-DD days. An empty from
+// defaults to today and an empty to to 30 days after from.
+func parseDayRange(fromStr, toStr string) (time.Time, time.Time, error) {
+	from := time.Now().UTC().Truncate(24 * time.Hour)
+	if fromStr != "" {
+		d, err := time.Parse(dayLayout, fromStr)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("from must be a YYYY-MM-DD date")
+		}
+		from = d
+	}
+
+	to := from.AddDate(0, 0, 30)
+	if toStr != "" {
+		d, err := time.Parse(dayLayout, toStr)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("to must be a YYYY-MM-DD date")
+		}
+		to = d
+	}
+
+	if !to.After(from) {
+		return time.Time{}, time.Time{}, errors.New("to must be after from")
+	}
+	if to.Sub(from) > maxAvailabilityDays*24*time.Hour {
+		return time.Time{}, time.Time{}, errors.New("date range is too long")
+	}
+	return from, to, nil
+}
+
+// startOfDay drops the time of day, in UTC
+func startOfDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// bookingOnNight returns the booking that occupies the night starting on day.
+// A stay occupies the nights from its check-in day up to, not including, its checkout day.
+func bookingOnNight(bookings []models.Booking, day time.Time) *models.Booking {
+	for i := range bookings {
+		b := &bookings[i]
+		if !startOfDay(b.StartDate).After(day) && startOfDay(b.EndDate).After(day) {
+			return b
+		}
+	}
+	return nil
+}
+
+func blackoutOnNight(blackouts []models.Blackout, day time.Time) *models.Blackout {
+	for i := range blackouts {
+		b := &blackouts[i]
+		if !startOfDay(b.StartDate).After(day) && startOfDay(b.EndDate).After(day) {
+			return b
+		}
+	}
+	return nil
+}
@@ -2,7 +2,9 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/phillip/backend/config"
@@ -12,9 +14,11 @@ import (
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// CreateBooking - creates a new booking and updates property availability if confirmed
+// CreateBooking - creates a new booking if the nights are free
 func CreateBooking(cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// ✅ Extract logged-in user from context (set by auth middleware)
@@ -43,6 +47,12 @@ func CreateBooking(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
+		// ✅ Validate the stay itself
+		if err := validateStayDates(input.StartDate, input.EndDate, true); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
 		// ✅ Build booking object
 		booking := models.Booking{
 			ID:         primitive.NewObjectID(),
@@ -55,42 +65,88 @@ func CreateBooking(cfg *config.Config) gin.HandlerFunc {
 			UpdatedAt:  time.Now(),
 		}
 		if booking.Status == "" {
-			booking.Status = "pending"
+			booking.Status = models.BookingPending
+		}
+		// New bookings start pending, or confirmed when the owner enters them
+		if booking.Status != models.BookingPending && booking.Status != models.BookingConfirmed {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "new bookings must be pending or confirmed"})
+			return
 		}
+		booking.StatusHistory = []models.StatusChange{{
+			To:        booking.Status,
+			ChangedBy: userID,
+			ChangedAt: booking.CreatedAt,
+		}}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
+		lookupCtx, cancelLookup := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelLookup()
 
 		bookingCol := cfg.MongoClient.Database(cfg.DBName).Collection("bookings")
 		propertyCol := cfg.MongoClient.Database(cfg.DBName).Collection("properties")
 
-		// ✅ Insert booking
-		if _, err := bookingCol.InsertOne(ctx, booking); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create booking"})
+		var property models.Property
+		if err := propertyCol.FindOne(lookupCtx, bson.M{"_id": propertyID}).Decode(&property); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Property not found"})
+			return
+		}
+
+		if booking.Status == models.BookingConfirmed && !canManageBooking(c, property) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only the property owner can confirm bookings"})
+			return
+		}
+
+		// ✅ Price the stay
+		quote, err := utils.QuoteStay(property.EffectiveRatePlan(cfg.DefaultCurrency), booking.StartDate, booking.EndDate)
+		if err != nil {
+			respondQuoteError(c, err)
+			return
+		}
+		booking.Currency = quote.Currency
+		booking.TotalAmount = quote.Total
+		booking.LineItems = quote.LineItems
+
+		// ✅ Hold the property lock so a concurrent request can't take the same nights
+		release, err := utils.LockProperty(cfg, propertyID)
+		if err != nil {
+			respondLockError(c, err)
 			return
 		}
+		defer release()
 
-		// ✅ Update property availability if confirmed
-		if booking.Status == "confirmed" {
-			_, err = propertyCol.UpdateOne(ctx, bson.M{"_id": booking.PropertyID},
-				bson.M{"$set": bson.M{"availability": false}})
+		// Waiting for the lock can take seconds; the checks and insert get their own deadline
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if booking.IsBlocking() {
+			conflicts, err := findOverlappingBookings(ctx, bookingCol, propertyID, booking.StartDate, booking.EndDate, primitive.NilObjectID)
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update property availability"})
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check availability"})
 				return
 			}
+			blackouts := findOverlappingBlackouts(property, booking.StartDate, booking.EndDate)
+			if len(conflicts) > 0 || len(blackouts) > 0 {
+				respondBookingConflict(c, conflicts, blackouts)
+				return
+			}
+		}
+
+		// ✅ Insert booking
+		if _, err := bookingCol.InsertOne(ctx, booking); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create booking"})
+			return
 		}
 
+		// ✅ Confirmed stays get their turnover clean straight away
+		syncCleaningTask(ctx, cfg, booking, property)
+
 		// ✅ Send notifications to property owner + housekeepers
-		var property models.Property
-		if err := propertyCol.FindOne(ctx, bson.M{"_id": booking.PropertyID}).Decode(&property); err == nil {
-			recipients := append([]primitive.ObjectID{property.UserID}, property.Housekeepers...)
+		recipients := append([]primitive.ObjectID{property.UserID}, property.Housekeepers...)
 
-			switch booking.Status {
-			case "confirmed":
-				_ = utils.CreateNotification(cfg, recipients, "Booking Confirmed", "A booking has been confirmed for your property.")
-			case "cancelled":
-				_ = utils.CreateNotification(cfg, recipients, "Booking Cancelled", "A booking has been cancelled for your property.")
-			}
+		switch booking.Status {
+		case models.BookingConfirmed:
+			_ = utils.CreateNotification(cfg, recipients, "Booking Confirmed", "A booking has been confirmed for your property.")
+		case models.BookingPending:
+			_ = utils.CreateNotification(cfg, recipients, "New Booking Request", "A new booking is waiting for your confirmation.")
 		}
 
 		c.JSON(http.StatusCreated, booking)
@@ -108,11 +164,16 @@ func GetBooking(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
+		scope, ok := requestScope(c)
+		if !ok {
+			return
+		}
+
 		var booking models.Booking
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		err = cfg.MongoClient.Database(cfg.DBName).Collection("bookings").FindOne(ctx, bson.M{"_id": objID}).Decode(&booking)
+		err = cfg.MongoClient.Database(cfg.DBName).Collection("bookings").FindOne(ctx, scope.Bookings(bson.M{"_id": objID})).Decode(&booking)
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
 			return
@@ -122,30 +183,67 @@ func GetBooking(cfg *config.Config) gin.HandlerFunc {
 	}
 }
 
-// ListBookings - get all bookings
+// ListBookings - paginated bookings. Filters: property_id, status (comma
+// separated), and from/to (YYYY-MM-DD) for stays overlapping that range.
 func ListBookings(cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		params, err := utils.ParseListParams(c, map[string]string{
+			"start_date": "start_date",
+			"end_date":   "end_date",
+			"created_at": "created_at",
+			"status":     "status",
+		}, "-start_date")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		filter := bson.M{}
+		if propertyID, ok, err := utils.QueryObjectID(c, "property_id"); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		} else if ok {
+			filter["property_id"] = propertyID
+		}
+		if status := c.Query("status"); status != "" {
+			filter["status"] = bson.M{"$in": strings.Split(status, ",")}
+		}
+		if c.Query("from") != "" || c.Query("to") != "" {
+			from, to, err := parseDayRange(c.Query("from"), c.Query("to"))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			filter["start_date"] = bson.M{"$lt": to}
+			filter["end_date"] = bson.M{"$gt": from}
+		}
+
+		scope, ok := requestScope(c)
+		if !ok {
+			return
+		}
+
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
-		cursor, err := cfg.MongoClient.Database(cfg.DBName).Collection("bookings").Find(ctx, bson.M{})
+		bookings := []models.Booking{}
+		page, err := utils.FindPage(ctx, cfg.MongoClient.Database(cfg.DBName).Collection("bookings"), scope.Bookings(filter), params, &bookings)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bookings"})
 			return
 		}
-		defer cursor.Close(ctx)
-
-		var bookings []models.Booking
-		if err = cursor.All(ctx, &bookings); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding bookings"})
-			return
-		}
 
-		c.JSON(http.StatusOK, bookings)
+		c.JSON(http.StatusOK, utils.PageResponse(bookings, page))
 	}
 }
 
-// UpdateBooking - update booking details or status, also updates property availability
+// UpdateBooking - move the dates and/or the status of a booking. Status changes
+// must follow the lifecycle in models.CanTransition (422 otherwise) and are
+// recorded in status_history. The guest may only cancel; the property owner
+// can confirm, decline (cancel a pending booking), check in, complete or mark a no-show.
+// Dates of cancelled, completed and no-show bookings are fixed. A guest may move
+// a pending or confirmed booking, but a confirmed one goes back to pending until
+// the owner confirms the new dates.
 func UpdateBooking(cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// ✅ Extract user from context
@@ -167,6 +265,7 @@ func UpdateBooking(cfg *config.Config) gin.HandlerFunc {
 		// ✅ Bind payload with pointers so optional fields don’t overwrite
 		var input struct {
 			Status    string     `json:"status"`
+			Reason    string     `json:"reason"`
 			StartDate *time.Time `json:"start_date,omitempty"`
 			EndDate   *time.Time `json:"end_date,omitempty"`
 		}
@@ -181,66 +280,207 @@ func UpdateBooking(cfg *config.Config) gin.HandlerFunc {
 		bookingCol := cfg.MongoClient.Database(cfg.DBName).Collection("bookings")
 		propertyCol := cfg.MongoClient.Database(cfg.DBName).Collection("properties")
 
-		// ✅ Fetch booking (to validate ownership + property info)
+		// ✅ Fetch booking + property to work out who is asking
 		var existing models.Booking
-		if err := bookingCol.FindOne(ctx, bson.M{"_id": objID, "user_id": userID}).Decode(&existing); err != nil {
+		if err := bookingCol.FindOne(ctx, bson.M{"_id": objID}).Decode(&existing); err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
 			return
 		}
 
-		// ✅ Build update document dynamically
-		updateFields := bson.M{
-			"status":     input.Status,
-			"updated_at": time.Now(),
+		var property models.Property
+		if err := propertyCol.FindOne(ctx, bson.M{"_id": existing.PropertyID}).Decode(&property); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Property not found"})
+			return
+		}
+
+		isManager := canManageBooking(c, property)
+		isGuest := existing.UserID == userID
+		if !isManager && !isGuest {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+			return
+		}
+
+		// ✅ Validate the status transition
+		statusChanged := input.Status != "" && input.Status != existing.Status
+		if statusChanged {
+			if !models.CanTransition(existing.Status, input.Status) {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{
+					"error": "cannot change booking status from " + existing.Status + " to " + input.Status,
+				})
+				return
+			}
+			if !isManager && input.Status != models.BookingCancelled {
+				c.JSON(http.StatusForbidden, gin.H{"error": "only the property owner can set this status"})
+				return
+			}
+		}
+
+		// ✅ Work out what the booking will look like after the update
+		updated := existing
+		if statusChanged {
+			updated.Status = input.Status
 		}
 		if input.StartDate != nil {
-			updateFields["start_date"] = *input.StartDate
+			updated.StartDate = *input.StartDate
 		}
 		if input.EndDate != nil {
-			updateFields["end_date"] = *input.EndDate
+			updated.EndDate = *input.EndDate
 		}
+		datesChanged := !updated.StartDate.Equal(existing.StartDate) || !updated.EndDate.Equal(existing.EndDate)
 
-		_, err = bookingCol.UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": updateFields})
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update booking"})
+		if !statusChanged && !datesChanged {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "no fields to update"})
 			return
 		}
 
-		// ✅ Update property availability if confirmed/cancelled
-		if input.Status == "confirmed" {
-			_, _ = propertyCol.UpdateOne(ctx, bson.M{"_id": existing.PropertyID},
-				bson.M{"$set": bson.M{"availability": false}})
+		if datesChanged && existing.IsTerminal() {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "cannot change the dates of a " + existing.Status + " booking"})
+			return
 		}
-		if input.Status == "cancelled" {
-			_, _ = propertyCol.UpdateOne(ctx, bson.M{"_id": existing.PropertyID},
-				bson.M{"$set": bson.M{"availability": true}})
+
+		// ✅ Guests can't move a stay the owner has confirmed without the owner agreeing again
+		reason := input.Reason
+		if datesChanged && !isManager {
+			switch updated.Status {
+			case models.BookingPending:
+			case models.BookingConfirmed:
+				updated.Status = models.BookingPending
+				statusChanged = true
+				if reason == "" {
+					reason = "dates changed by guest, awaiting owner confirmation"
+				}
+			default:
+				c.JSON(http.StatusForbidden, gin.H{"error": "only the property owner can change the dates of a " + updated.Status + " booking"})
+				return
+			}
 		}
 
-		// ✅ Notify property owner + housekeepers
-		var property models.Property
-		if err := propertyCol.FindOne(ctx, bson.M{"_id": existing.PropertyID}).Decode(&property); err == nil {
+		if datesChanged {
+			// A stay already under way keeps its start date, so only a moved start must be in the future
+			if err := validateStayDates(updated.StartDate, updated.EndDate, !updated.StartDate.Equal(existing.StartDate)); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		// ✅ Re-check overlaps when the booking moves or starts holding dates again
+		if updated.IsBlocking() && (datesChanged || !existing.IsBlocking()) {
+			release, err := utils.LockProperty(cfg, existing.PropertyID)
+			if err != nil {
+				respondLockError(c, err)
+				return
+			}
+			defer release()
+
+			// Waiting for the lock can take seconds; what follows gets a fresh deadline
+			var cancelLocked context.CancelFunc
+			ctx, cancelLocked = context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancelLocked()
+
+			conflicts, err := findOverlappingBookings(ctx, bookingCol, existing.PropertyID, updated.StartDate, updated.EndDate, existing.ID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check availability"})
+				return
+			}
+			blackouts := findOverlappingBlackouts(property, updated.StartDate, updated.EndDate)
+			if len(conflicts) > 0 || len(blackouts) > 0 {
+				respondBookingConflict(c, conflicts, blackouts)
+				return
+			}
+		}
+
+		// ✅ Build update document dynamically
+		now := time.Now()
+		updateFields := bson.M{"updated_at": now}
+		if datesChanged {
+			updateFields["start_date"] = updated.StartDate
+			updateFields["end_date"] = updated.EndDate
+		}
+
+		// ✅ Re-price stays that moved (imported bookings carry no price)
+		if datesChanged && existing.Source == "" {
+			quote, err := utils.QuoteStay(property.EffectiveRatePlan(cfg.DefaultCurrency), updated.StartDate, updated.EndDate)
+			if err != nil {
+				respondQuoteError(c, err)
+				return
+			}
+			updateFields["currency"] = quote.Currency
+			updateFields["total_amount"] = quote.Total
+			updateFields["line_items"] = quote.LineItems
+		}
+
+		update := bson.M{"$set": updateFields}
+		if statusChanged {
+			updateFields["status"] = updated.Status
+			update["$push"] = bson.M{"status_history": models.StatusChange{
+				From:      existing.Status,
+				To:        updated.Status,
+				ChangedBy: userID,
+				ChangedAt: now,
+				Reason:    reason,
+			}}
+		}
+
+		// Only apply if nobody changed the status in the meantime
+		res, err := bookingCol.UpdateOne(ctx, bson.M{"_id": objID, "status": existing.Status}, update)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update booking"})
+			return
+		}
+		if res.MatchedCount == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Booking was changed by someone else, please reload"})
+			return
+		}
+
+		// ✅ Schedule, move or cancel the turnover clean
+		syncCleaningTask(ctx, cfg, updated, property)
+
+		// ✅ Notify property owner + housekeepers, and the guest when the owner acts
+		if statusChanged {
 			recipients := append([]primitive.ObjectID{property.UserID}, property.Housekeepers...)
+			if existing.UserID != property.UserID {
+				recipients = append(recipients, existing.UserID)
+			}
 
-			switch input.Status {
-			case "confirmed":
+			switch {
+			case updated.Status == models.BookingPending:
+				_ = utils.CreateNotification(cfg, recipients, "Booking Changed", "A guest changed their dates; the booking is waiting for your confirmation.")
+			case updated.Status == models.BookingConfirmed:
 				_ = utils.CreateNotification(cfg, recipients, "Booking Confirmed", "A booking has been confirmed for your property.")
-			case "cancelled":
+			case updated.Status == models.BookingCancelled && existing.Status == models.BookingPending && isManager && !isGuest:
+				_ = utils.CreateNotification(cfg, recipients, "Booking Declined", "A booking request has been declined.")
+			case updated.Status == models.BookingCancelled:
 				_ = utils.CreateNotification(cfg, recipients, "Booking Cancelled", "A booking has been cancelled for your property.")
-			case "completed":
+			case updated.Status == models.BookingCompleted:
 				_ = utils.CreateNotification(cfg, recipients, "Booking Completed", "A booking has been completed for your property.")
+			case updated.Status == models.BookingNoShow:
+				_ = utils.CreateNotification(cfg, recipients, "Guest No-Show", "A guest did not show up for their booking.")
 			}
 		}
 
 		// ✅ Return updated booking
-		c.JSON(http.StatusOK, gin.H{"message": "Booking updated successfully"})
+		var fresh models.Booking
+		if err := bookingCol.FindOne(ctx, bson.M{"_id": objID}).Decode(&fresh); err != nil {
+			c.JSON(http.StatusOK, gin.H{"message": "Booking updated successfully"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Booking updated successfully", "booking": fresh})
 	}
 }
 
-
-
-// DeleteBooking - deletes a booking and resets property availability if necessary
+// DeleteBooking - removes a booking, which frees its nights. The property owner
+// (or an admin) may delete a pending or cancelled booking outright. Any other
+// removal, including a guest withdrawing their own booking, cancels it through
+// the lifecycle so it is recorded in status_history; bookings that can no
+// longer be cancelled (checked in, completed, no-show) are kept (422).
 func DeleteBooking(cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		userID, err := primitive.ObjectIDFromHex(c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user id"})
+			return
+		}
+
 		id := c.Param("id")
 		objID, err := primitive.ObjectIDFromHex(id)
 		if err != nil {
@@ -260,18 +500,160 @@ func DeleteBooking(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
-		_, err = bookingCol.DeleteOne(ctx, bson.M{"_id": objID})
+		var property models.Property
+		if err := propertyCol.FindOne(ctx, bson.M{"_id": existing.PropertyID}).Decode(&property); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Property not found"})
+			return
+		}
+
+		isManager := canManageBooking(c, property)
+		isGuest := existing.UserID == userID
+		if !isManager && !isGuest {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+			return
+		}
+
+		// ✅ The owner may clear away requests and cancelled stays
+		if isManager && canDeleteBooking(existing.Status) {
+			// Only if nobody changed the status in the meantime
+			res, err := bookingCol.DeleteOne(ctx, bson.M{"_id": objID, "status": existing.Status})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete booking"})
+				return
+			}
+			if res.DeletedCount == 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": "Booking was changed by someone else, please reload"})
+				return
+			}
+
+			cancelCleaningTasks(ctx, cfg, []primitive.ObjectID{objID})
+			c.JSON(http.StatusOK, gin.H{"message": "Booking deleted successfully"})
+			return
+		}
+
+		// ✅ Everything else is a cancellation
+		if !models.CanTransition(existing.Status, models.BookingCancelled) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "cannot remove a " + existing.Status + " booking"})
+			return
+		}
+
+		now := time.Now()
+		res, err := bookingCol.UpdateOne(ctx, bson.M{"_id": objID, "status": existing.Status}, bson.M{
+			"$set": bson.M{"status": models.BookingCancelled, "updated_at": now},
+			"$push": bson.M{"status_history": models.StatusChange{
+				From:      existing.Status,
+				To:        models.BookingCancelled,
+				ChangedBy: userID,
+				ChangedAt: now,
+				Reason:    "removed",
+			}},
+		})
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete booking"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not cancel booking"})
+			return
+		}
+		if res.MatchedCount == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Booking was changed by someone else, please reload"})
 			return
 		}
 
-		// Free up property if booking was confirmed
-		if existing.Status == "confirmed" {
-			_, _ = propertyCol.UpdateOne(ctx, bson.M{"_id": existing.PropertyID},
-				bson.M{"$set": bson.M{"availability": true}})
+		cancelCleaningTasks(ctx, cfg, []primitive.ObjectID{objID})
+
+		recipients := append([]primitive.ObjectID{property.UserID}, property.Housekeepers...)
+		if existing.UserID != property.UserID {
+			recipients = append(recipients, existing.UserID)
 		}
+		_ = utils.CreateNotification(cfg, recipients, "Booking Cancelled", "A booking has been cancelled for your property.")
+
+		c.JSON(http.StatusOK, gin.H{"message": "Booking cancelled", "status": models.BookingCancelled})
+	}
+}
+
+// =============================
+// Helpers
+// =============================
+
+// canManageBooking reports whether the requester runs the property: its owner or an admin
+func canManageBooking(c *gin.Context, property models.Property) bool {
+	return c.GetString("role") == models.RoleAdmin || property.UserID.Hex() == c.GetString("user_id")
+}
+
+// canDeleteBooking reports whether a booking in status may be deleted rather
+// than cancelled: requests nobody accepted yet, and stays already cancelled
+func canDeleteBooking(status string) bool {
+	return status == "" || status == models.BookingPending || status == models.BookingCancelled
+}
+
+// validateStayDates rejects empty and reversed date ranges, and with
+// checkPast a start date before today
+func validateStayDates(start, end time.Time, checkPast bool) error {
+	if !end.After(start) {
+		return errors.New("end_date must be after start_date")
+	}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	if checkPast && start.Before(today) {
+		return errors.New("start_date cannot be in the past")
+	}
+	return nil
+}
+
+// findOverlappingBookings returns the IDs of blocking bookings on the property
+// that share at least one night with [start, end). exclude is skipped (use
+// primitive.NilObjectID for none) so a booking never conflicts with itself.
+func findOverlappingBookings(ctx context.Context, col *mongo.Collection, propertyID primitive.ObjectID, start, end time.Time, exclude primitive.ObjectID) ([]string, error) {
+	filter := bson.M{
+		"property_id": propertyID,
+		"status":      bson.M{"$in": models.BlockingBookingStatuses},
+		"start_date":  bson.M{"$lt": end},
+		"end_date":    bson.M{"$gt": start},
+	}
+	if !exclude.IsZero() {
+		filter["_id"] = bson.M{"$ne": exclude}
+	}
+
+	cursor, err := col.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var docs []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(docs))
+	for _, d := range docs {
+		ids = append(ids, d.ID.Hex())
+	}
+	return ids, nil
+}
+
+// findOverlappingBlackouts returns the IDs of the property's blackouts that share a night with [start, end)
+func findOverlappingBlackouts(property models.Property, start, end time.Time) []string {
+	ids := []string{}
+	for _, b := range property.Blackouts {
+		if b.StartDate.Before(end) && b.EndDate.After(start) {
+			ids = append(ids, b.ID.Hex())
+		}
+	}
+	return ids
+}
+
+func respondBookingConflict(c *gin.Context, bookings, blackouts []string) {
+	c.JSON(http.StatusConflict, gin.H{
+		"error":                    "Property is not available for these dates",
+		"conflicting_booking_ids":  bookings,
+		"conflicting_blackout_ids": blackouts,
+	})
+}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Booking deleted successfully"})
+func respondLockError(c *gin.Context, err error) {
+	if errors.Is(err, utils.ErrPropertyLocked) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Another booking for this property is in progress, please retry"})
+		return
 	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not lock property"})
 }
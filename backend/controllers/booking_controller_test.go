@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/phillip/backend/models"
+)
+
+func TestValidateStayDates(t *testing.T) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	lastWeek, tomorrow, nextWeek := today.AddDate(0, 0, -7), today.AddDate(0, 0, 1), today.AddDate(0, 0, 7)
+
+	cases := []struct {
+		name       string
+		start, end time.Time
+		checkPast  bool
+		wantErr    bool
+	}{
+		{"future stay", tomorrow, nextWeek, true, false},
+		{"starts today", today, tomorrow, true, false},
+		{"reversed", nextWeek, tomorrow, true, true},
+		{"empty", tomorrow, tomorrow, false, true},
+		{"new stay in the past", lastWeek, tomorrow, true, true},
+		{"extending a stay under way", lastWeek, nextWeek, false, false},
+	}
+	for _, tc := range cases {
+		err := validateStayDates(tc.start, tc.end, tc.checkPast)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tc.name, err, tc.wantErr)
+		}
+	}
+}
+
+func TestCanDeleteBooking(t *testing.T) {
+	cases := map[string]bool{
+		"":                      true,
+		models.BookingPending:   true,
+		models.BookingCancelled: true,
+		models.BookingConfirmed: false,
+		models.BookingCheckedIn: false,
+		models.BookingCompleted: false,
+		models.BookingNoShow:    false,
+	}
+	for status, want := range cases {
+		if got := canDeleteBooking(status); got != want {
+			t.Errorf("canDeleteBooking(%q) = %v, want %v", status, got, want)
+		}
+	}
+}
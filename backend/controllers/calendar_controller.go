@@ -0,0 +1,610 @@
+package controllers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/phillip/backend/config"
+	"github.com/phillip/backend/models"
+	"github.com/phillip/backend/utils"
+)
+
+const maxICSSize = 5 << 20 // 5 MB
+
+// icsClient refuses internal addresses: feed URLs are user-supplied and fetched server-side
+var icsClient = utils.NewPublicHTTPClient(20 * time.Second)
+
+// ExportPropertyCalendar - public iCal feed of a property's bookings, guarded by ?token=
+func ExportPropertyCalendar(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "calendar not found"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var property models.Property
+		err = cfg.MongoClient.Database(cfg.DBName).Collection("properties").
+			FindOne(ctx, bson.M{"_id": objID}).Decode(&property)
+
+		token := c.Query("token")
+		if err != nil || property.CalendarToken == "" ||
+			subtle.ConstantTimeCompare([]byte(token), []byte(property.CalendarToken)) != 1 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "calendar not found"})
+			return
+		}
+
+		// Recent and upcoming stays only, feeds don't need the full history
+		cursor, err := cfg.MongoClient.Database(cfg.DBName).Collection("bookings").Find(ctx, bson.M{
+			"property_id": objID,
+			"status":      bson.M{"$in": models.BlockingBookingStatuses},
+			"end_date":    bson.M{"$gte": time.Now().AddDate(0, 0, -30)},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch bookings"})
+			return
+		}
+		var bookings []models.Booking
+		if err := cursor.All(ctx, &bookings); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not decode bookings"})
+			return
+		}
+
+		events := make([]utils.ICSEvent, 0, len(bookings))
+		for _, b := range bookings {
+			events = append(events, utils.ICSEvent{
+				UID:     b.ID.Hex() + "@unit-wise",
+				Summary: "Reserved",
+				Start:   startOfDay(b.StartDate),
+				End:     startOfDay(b.EndDate),
+				AllDay:  true,
+			})
+		}
+
+		c.Header("Cache-Control", "no-cache")
+		c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(utils.BuildICS(property.Title, events)))
+	}
+}
+
+// RotateCalendarToken - issue (or replace) the token for the property's .ics export
+func RotateCalendarToken(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid property id"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if _, ok := loadOwnedProperty(ctx, c, cfg, objID); !ok {
+			return
+		}
+
+		token, err := utils.RandomToken(24)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not generate token"})
+			return
+		}
+
+		_, err = cfg.MongoClient.Database(cfg.DBName).Collection("properties").UpdateOne(ctx,
+			bson.M{"_id": objID},
+			bson.M{"$set": bson.M{"calendar_token": token, "updated_at": time.Now()}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not save token"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"token": token,
+			"path":  fmt.Sprintf("/properties/%s/calendar.ics?token=%s", objID.Hex(), token),
+		})
+	}
+}
+
+// CreateCalendarFeed - register an external iCal URL and import it straight away
+func CreateCalendarFeed(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid property id"})
+			return
+		}
+
+		var input struct {
+			Name string `json:"name" binding:"required"`
+			URL  string `json:"url" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		feedURL, err := normalizeFeedURL(input.URL)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		property, ok := loadOwnedProperty(ctx, c, cfg, objID)
+		if !ok {
+			return
+		}
+
+		if err := checkFeedHost(ctx, feedURL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		feed := models.CalendarFeed{
+			ID:         primitive.NewObjectID(),
+			PropertyID: property.ID,
+			UserID:     property.UserID,
+			Name:       input.Name,
+			URL:        feedURL,
+			CreatedAt:  time.Now(),
+			UpdatedAt:  time.Now(),
+		}
+		if _, err := cfg.MongoClient.Database(cfg.DBName).Collection("calendar_feeds").InsertOne(ctx, feed); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not save feed"})
+			return
+		}
+
+		imported, conflicts, removed, syncErr := syncCalendarFeed(ctx, cfg, feed)
+		resp := gin.H{"feed": feed, "imported": imported, "conflicts": conflicts, "removed": removed}
+		if syncErr != nil {
+			resp["sync_error"] = syncErr.Error()
+		}
+		c.JSON(http.StatusCreated, resp)
+	}
+}
+
+// ListCalendarFeeds - external feeds registered on a property
+func ListCalendarFeeds(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid property id"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if _, ok := loadOwnedProperty(ctx, c, cfg, objID); !ok {
+			return
+		}
+
+		cursor, err := cfg.MongoClient.Database(cfg.DBName).Collection("calendar_feeds").Find(ctx, bson.M{"property_id": objID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch feeds"})
+			return
+		}
+		feeds := []models.CalendarFeed{}
+		if err := cursor.All(ctx, &feeds); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not decode feeds"})
+			return
+		}
+
+		c.JSON(http.StatusOK, feeds)
+	}
+}
+
+// SyncCalendarFeed - re-import one feed on demand
+func SyncCalendarFeed(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		feed, ok := loadOwnedFeed(c, cfg)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		imported, conflicts, removed, err := syncCalendarFeed(ctx, cfg, feed)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "could not sync feed", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"imported": imported, "conflicts": conflicts, "removed": removed})
+	}
+}
+
+// DeleteCalendarFeed - remove a feed along with the bookings it imported
+func DeleteCalendarFeed(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		feed, ok := loadOwnedFeed(c, cfg)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		db := cfg.MongoClient.Database(cfg.DBName)
+		if _, err := db.Collection("calendar_feeds").DeleteOne(ctx, bson.M{"_id": feed.ID}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not delete feed"})
+			return
+		}
+		importedIDs, err := bookingIDs(ctx, db.Collection("bookings"), bson.M{"feed_id": feed.ID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch imported bookings"})
+			return
+		}
+		res, err := db.Collection("bookings").DeleteMany(ctx, bson.M{"feed_id": feed.ID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not delete imported bookings"})
+			return
+		}
+		cancelCleaningTasks(ctx, cfg, importedIDs)
+
+		c.JSON(http.StatusOK, gin.H{"message": "feed deleted", "id": feed.ID.Hex(), "removed": res.DeletedCount})
+	}
+}
+
+// ImportCalendarFile - import an uploaded .ics file (form field "file") as blocking bookings
+func ImportCalendarFile(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid property id"})
+			return
+		}
+
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+			return
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to open file"})
+			return
+		}
+		defer file.Close()
+
+		events, err := utils.ParseICS(io.LimitReader(file, maxICSSize))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid calendar file", "details": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		property, ok := loadOwnedProperty(ctx, c, cfg, objID)
+		if !ok {
+			return
+		}
+
+		imported, conflicts, err := upsertImportedEvents(ctx, cfg, property, nil, models.BookingSourceICalUpload, events)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not import events"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"imported": imported, "conflicts": conflicts})
+	}
+}
+
+// StartCalendarFeedSync re-imports every registered feed on a fixed interval
+func StartCalendarFeedSync(cfg *config.Config, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		syncAllCalendarFeeds(cfg)
+	}
+}
+
+// =============================
+// Helpers
+// =============================
+
+func syncAllCalendarFeeds(cfg *config.Config) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cursor, err := cfg.MongoClient.Database(cfg.DBName).Collection("calendar_feeds").Find(ctx, bson.M{})
+	if err != nil {
+		log.Printf("calendar sync: could not list feeds: %v", err)
+		return
+	}
+	var feeds []models.CalendarFeed
+	if err := cursor.All(ctx, &feeds); err != nil {
+		log.Printf("calendar sync: could not decode feeds: %v", err)
+		return
+	}
+
+	for _, feed := range feeds {
+		feedCtx, feedCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		_, conflicts, _, err := syncCalendarFeed(feedCtx, cfg, feed)
+		if err != nil {
+			log.Printf("calendar sync: feed %s: %v", feed.ID.Hex(), err)
+		} else if len(conflicts) > 0 {
+			log.Printf("calendar sync: feed %s: %d event(s) clash with existing stays", feed.ID.Hex(), len(conflicts))
+		}
+		feedCancel()
+	}
+}
+
+// syncCalendarFeed downloads the feed, upserts its events and drops bookings
+// for events that have disappeared from it. The outcome is stored on the feed.
+func syncCalendarFeed(ctx context.Context, cfg *config.Config, feed models.CalendarFeed) (imported int, conflicts []importConflict, removed int64, err error) {
+	db := cfg.MongoClient.Database(cfg.DBName)
+
+	defer func() {
+		now := time.Now()
+		set := bson.M{"last_synced_at": now, "updated_at": now, "last_error": ""}
+		if err != nil {
+			set["last_error"] = err.Error()
+		}
+		_, _ = db.Collection("calendar_feeds").UpdateOne(ctx, bson.M{"_id": feed.ID}, bson.M{"$set": set})
+	}()
+
+	events, err := fetchICS(ctx, feed.URL)
+	if err != nil {
+		return 0, nil, 0, err
+	}
+
+	var property models.Property
+	if err := db.Collection("properties").FindOne(ctx, bson.M{"_id": feed.PropertyID}).Decode(&property); err != nil {
+		return 0, nil, 0, fmt.Errorf("property not found: %v", err)
+	}
+
+	imported, conflicts, err = upsertImportedEvents(ctx, cfg, property, &feed.ID, models.BookingSourceICalFeed, events)
+	if err != nil {
+		return imported, conflicts, 0, err
+	}
+
+	uids := make([]string, 0, len(events))
+	for _, e := range events {
+		uids = append(uids, e.UID)
+	}
+	gone := bson.M{
+		"feed_id":      feed.ID,
+		"external_uid": bson.M{"$nin": uids},
+	}
+	goneIDs, err := bookingIDs(ctx, db.Collection("bookings"), gone)
+	if err != nil {
+		return imported, conflicts, 0, err
+	}
+	res, err := db.Collection("bookings").DeleteMany(ctx, gone)
+	if err != nil {
+		return imported, conflicts, 0, err
+	}
+	cancelCleaningTasks(ctx, cfg, goneIDs)
+
+	return imported, conflicts, res.DeletedCount, nil
+}
+
+// importConflict is an imported event that was not written because its nights
+// are already held by another booking or a blackout
+type importConflict struct {
+	UID                    string    `json:"uid"`
+	Summary                string    `json:"summary,omitempty"`
+	StartDate              time.Time `json:"start_date"`
+	EndDate                time.Time `json:"end_date"`
+	ConflictingBookingIDs  []string  `json:"conflicting_booking_ids"`
+	ConflictingBlackoutIDs []string  `json:"conflicting_blackout_ids"`
+}
+
+// upsertImportedEvents stores events as confirmed bookings owned by the property
+// owner, keyed by (property, source, feed, UID) so re-imports update in place.
+// Events that have already ended are skipped. Each write holds the property
+// lock and goes through the same overlap and blackout checks as CreateBooking;
+// events that would double-book are returned as conflicts instead.
+func upsertImportedEvents(ctx context.Context, cfg *config.Config, property models.Property, feedID *primitive.ObjectID, source string, events []utils.ICSEvent) (int, []importConflict, error) {
+	col := cfg.MongoClient.Database(cfg.DBName).Collection("bookings")
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	imported := 0
+	conflicts := []importConflict{}
+	for _, e := range events {
+		if e.UID == "" || !e.End.After(e.Start) || e.End.Before(today) {
+			continue
+		}
+
+		conflict, err := upsertImportedEvent(ctx, cfg, col, property, feedID, source, e)
+		if err != nil {
+			return imported, conflicts, err
+		}
+		if conflict != nil {
+			conflicts = append(conflicts, *conflict)
+			continue
+		}
+		imported++
+	}
+	return imported, conflicts, nil
+}
+
+// upsertImportedEvent writes one event under the property lock
+func upsertImportedEvent(ctx context.Context, cfg *config.Config, col *mongo.Collection, property models.Property, feedID *primitive.ObjectID, source string, e utils.ICSEvent) (*importConflict, error) {
+	release, err := utils.LockProperty(cfg, property.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	filter := bson.M{
+		"property_id":  property.ID,
+		"source":       source,
+		"external_uid": e.UID,
+	}
+	if feedID != nil {
+		filter["feed_id"] = *feedID
+	}
+
+	// A re-import must not conflict with the booking it is updating
+	existingID := primitive.NilObjectID
+	var existing models.Booking
+	err = col.FindOne(ctx, filter, options.FindOne().SetProjection(bson.M{"_id": 1})).Decode(&existing)
+	switch {
+	case err == nil:
+		existingID = existing.ID
+	case !errors.Is(err, mongo.ErrNoDocuments):
+		return nil, err
+	}
+
+	bookings, err := findOverlappingBookings(ctx, col, property.ID, e.Start, e.End, existingID)
+	if err != nil {
+		return nil, err
+	}
+	blackouts := findOverlappingBlackouts(property, e.Start, e.End)
+	if len(bookings) > 0 || len(blackouts) > 0 {
+		return &importConflict{
+			UID:                    e.UID,
+			Summary:                e.Summary,
+			StartDate:              e.Start,
+			EndDate:                e.End,
+			ConflictingBookingIDs:  bookings,
+			ConflictingBlackoutIDs: blackouts,
+		}, nil
+	}
+
+	now := time.Now()
+	setOnInsert := bson.M{
+		"user_id":    property.UserID,
+		"created_at": now,
+	}
+	if feedID != nil {
+		setOnInsert["feed_id"] = *feedID
+	}
+
+	var booking models.Booking
+	err = col.FindOneAndUpdate(ctx, filter, bson.M{
+		"$set": bson.M{
+			"start_date": e.Start,
+			"end_date":   e.End,
+			"status":     models.BookingConfirmed,
+			"summary":    e.Summary,
+			"updated_at": now,
+		},
+		"$setOnInsert": setOnInsert,
+	}, options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&booking)
+	if err != nil {
+		return nil, err
+	}
+	syncCleaningTask(ctx, cfg, booking, property)
+	return nil, nil
+}
+
+// bookingIDs returns the IDs of the bookings matching filter
+func bookingIDs(ctx context.Context, col *mongo.Collection, filter bson.M) ([]primitive.ObjectID, error) {
+	vals, err := col.Distinct(ctx, "_id", filter)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(vals))
+	for _, v := range vals {
+		if id, ok := v.(primitive.ObjectID); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func fetchICS(ctx context.Context, feedURL string) ([]utils.ICSEvent, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/calendar")
+
+	resp, err := icsClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("feed returned %s", resp.Status)
+	}
+	return utils.ParseICS(io.LimitReader(resp.Body, maxICSSize))
+}
+
+// normalizeFeedURL accepts http(s) and webcal URLs, the latter rewritten to https
+func normalizeFeedURL(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid feed url")
+	}
+	switch u.Scheme {
+	case "webcal":
+		u.Scheme = "https"
+	case "http", "https":
+	default:
+		return "", fmt.Errorf("feed url must be http, https or webcal")
+	}
+	return u.String(), nil
+}
+
+// checkFeedHost rejects feeds on internal hosts at registration time, so the
+// owner gets a clear error rather than a failed sync. icsClient re-checks on every dial.
+func checkFeedHost(ctx context.Context, feedURL string) error {
+	u, err := url.Parse(feedURL)
+	if err != nil {
+		return fmt.Errorf("invalid feed url")
+	}
+	if err := utils.CheckPublicHost(ctx, u.Hostname()); err != nil {
+		if errors.Is(err, utils.ErrNonPublicAddress) {
+			return fmt.Errorf("feed url must point to a public host")
+		}
+		return err
+	}
+	return nil
+}
+
+// loadOwnedFeed resolves :id/:feedId and checks the requester owns the property
+func loadOwnedFeed(c *gin.Context, cfg *config.Config) (models.CalendarFeed, bool) {
+	var feed models.CalendarFeed
+
+	propertyID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid property id"})
+		return feed, false
+	}
+	feedID, err := primitive.ObjectIDFromHex(c.Param("feedId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid feed id"})
+		return feed, false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, ok := loadOwnedProperty(ctx, c, cfg, propertyID); !ok {
+		return feed, false
+	}
+
+	err = cfg.MongoClient.Database(cfg.DBName).Collection("calendar_feeds").
+		FindOne(ctx, bson.M{"_id": feedID, "property_id": propertyID}).Decode(&feed)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "feed not found"})
+		return feed, false
+	}
+	return feed, true
+}
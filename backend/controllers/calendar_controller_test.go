@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/phillip/backend/utils"
+)
+
+func feedServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	body, err := os.ReadFile("testdata/feed.ics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/calendar.ics":
+			w.Header().Set("Content-Type", "text/calendar")
+			_, _ = w.Write(body)
+		case "/moved":
+			http.Redirect(w, r, "/calendar.ics", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// withICSClient lets a test reach its loopback httptest server
+func withICSClient(t *testing.T, client *http.Client) {
+	t.Helper()
+	prev := icsClient
+	icsClient = client
+	t.Cleanup(func() { icsClient = prev })
+}
+
+func TestFetchICSFromFeed(t *testing.T) {
+	srv := feedServer(t)
+	withICSClient(t, srv.Client())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, path := range []string{"/calendar.ics", "/moved"} {
+		events, err := fetchICS(ctx, srv.URL+path)
+		if err != nil {
+			t.Fatalf("fetchICS(%s): %v", path, err)
+		}
+		if len(events) != 2 {
+			t.Fatalf("fetchICS(%s) returned %d events, want 2", path, len(events))
+		}
+		if events[0].UID != "1418fb94e984-f4dbd4d2c5dcb0b2a9b2@airbnb.com" || !events[0].AllDay {
+			t.Errorf("unexpected first event: %+v", events[0])
+		}
+	}
+
+	if _, err := fetchICS(ctx, srv.URL+"/missing.ics"); err == nil {
+		t.Error("expected an error for a 404 feed")
+	}
+}
+
+func TestFetchICSRefusesInternalHosts(t *testing.T) {
+	srv := feedServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The production client must not reach the loopback test server
+	_, err := fetchICS(ctx, srv.URL+"/calendar.ics")
+	if !errors.Is(err, utils.ErrNonPublicAddress) {
+		t.Fatalf("got %v, want ErrNonPublicAddress", err)
+	}
+
+	if err := checkFeedHost(ctx, "http://169.254.169.254/latest/meta-data/"); err == nil {
+		t.Error("checkFeedHost accepted the metadata service")
+	}
+	if err := checkFeedHost(ctx, srv.URL+"/calendar.ics"); err == nil {
+		t.Error("checkFeedHost accepted a loopback feed")
+	}
+}
+
+func TestNormalizeFeedURL(t *testing.T) {
+	cases := map[string]string{
+		"webcal://example.com/cal.ics":  "https://example.com/cal.ics",
+		" https://example.com/cal.ics ": "https://example.com/cal.ics",
+		"http://example.com/cal.ics":    "http://example.com/cal.ics",
+	}
+	for in, want := range cases {
+		got, err := normalizeFeedURL(in)
+		if err != nil || got != want {
+			t.Errorf("normalizeFeedURL(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"file:///etc/passwd", "gopher://example.com", "not a url"} {
+		if _, err := normalizeFeedURL(bad); err == nil {
+			t.Errorf("normalizeFeedURL(%q) accepted", bad)
+		}
+	}
+}
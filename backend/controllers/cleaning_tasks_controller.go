@@ -0,0 +1,364 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/phillip/backend/config"
+	"github.com/phillip/backend/models"
+	"github.com/phillip/backend/utils"
+)
+
+// ListCleaningTasks - agenda for one day (?date=YYYY-MM-DD, default today),
+// optionally filtered by property_id and status (comma-separated)
+func ListCleaningTasks(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scope, ok := requestScope(c)
+		if !ok {
+			return
+		}
+
+		params, err := utils.ParseListParams(c, map[string]string{
+			"scheduled_for": "scheduled_for",
+			"status":        "status",
+		}, "scheduled_for")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		day := startOfDay(time.Now())
+		if d := c.Query("date"); d != "" {
+			day, err = time.Parse(dayLayout, d)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "date must be YYYY-MM-DD"})
+				return
+			}
+		}
+
+		filter := bson.M{"scheduled_for": day}
+		propertyID, ok, err := utils.QueryObjectID(c, "property_id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if ok {
+			filter["property_id"] = propertyID
+		}
+		if s := c.Query("status"); s != "" {
+			filter["status"] = bson.M{"$in": strings.Split(s, ",")}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		tasks := []models.CleaningTask{}
+		col := cfg.MongoClient.Database(cfg.DBName).Collection("cleaning_tasks")
+		info, err := utils.FindPage(ctx, col, scope.Tasks(filter), params, &tasks)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch tasks"})
+			return
+		}
+
+		c.JSON(http.StatusOK, utils.PageResponse(tasks, info))
+	}
+}
+
+// UpdateCleaningTaskStatus - move a task along its lifecycle. The assigned
+// housekeeper may start or finish it; only the property owner (or an admin)
+// may cancel it or mark it missed.
+func UpdateCleaningTaskStatus(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		taskID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task id"})
+			return
+		}
+
+		var input struct {
+			Status string `json:"status" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		scope, ok := requestScope(c)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		col := cfg.MongoClient.Database(cfg.DBName).Collection("cleaning_tasks")
+
+		var task models.CleaningTask
+		if err := col.FindOne(ctx, scope.Tasks(bson.M{"_id": taskID})).Decode(&task); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "task not found"})
+			return
+		}
+
+		ownsProperty := scope.IsAdmin() || containsID(scope.Owned, task.PropertyID)
+		if !ownsProperty && !slices.Contains(models.HousekeeperTaskStatuses, input.Status) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only the property owner can set a task to " + input.Status})
+			return
+		}
+
+		if !models.CanTransitionTask(task.Status, input.Status) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error": "cannot change task status from " + task.Status + " to " + input.Status,
+			})
+			return
+		}
+
+		now := time.Now()
+		set := bson.M{"status": input.Status, "updated_at": now}
+		if input.Status == models.TaskDone {
+			set["completed_at"] = now
+		}
+
+		// Only apply if nobody changed the status in the meantime
+		res, err := col.UpdateOne(ctx, bson.M{"_id": taskID, "status": task.Status}, bson.M{"$set": set})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not update task"})
+			return
+		}
+		if res.MatchedCount == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "task was changed by someone else, please reload"})
+			return
+		}
+
+		task.Status = input.Status
+		task.UpdatedAt = now
+		if input.Status == models.TaskDone {
+			task.CompletedAt = &now
+		}
+		c.JSON(http.StatusOK, task)
+	}
+}
+
+// AssignCleaningTask - owner hands a task to another of the property's housekeepers
+func AssignCleaningTask(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		taskID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task id"})
+			return
+		}
+
+		var input struct {
+			HousekeeperID string `json:"housekeeper_id" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		housekeeperID, err := primitive.ObjectIDFromHex(input.HousekeeperID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid housekeeper id"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		col := cfg.MongoClient.Database(cfg.DBName).Collection("cleaning_tasks")
+
+		var task models.CleaningTask
+		if err := col.FindOne(ctx, bson.M{"_id": taskID}).Decode(&task); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "task not found"})
+			return
+		}
+
+		property, ok := loadOwnedProperty(ctx, c, cfg, task.PropertyID)
+		if !ok {
+			return
+		}
+		if !containsID(property.Housekeepers, housekeeperID) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "user is not a housekeeper on this property"})
+			return
+		}
+
+		res, err := col.UpdateOne(ctx,
+			bson.M{"_id": taskID, "status": bson.M{"$in": models.OpenTaskStatuses}},
+			bson.M{"$set": bson.M{"housekeeper_id": housekeeperID, "updated_at": time.Now()}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not assign task"})
+			return
+		}
+		if res.MatchedCount == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "only open tasks can be reassigned"})
+			return
+		}
+
+		_ = utils.CreateNotification(cfg, []primitive.ObjectID{housekeeperID}, "Cleaning Assigned", "You have a turnover clean at "+property.Title+" on "+task.ScheduledFor.Format(dayLayout)+".")
+
+		task.HousekeeperID = &housekeeperID
+		c.JSON(http.StatusOK, task)
+	}
+}
+
+// StartCleaningTaskSweeper marks tasks as missed once their day has passed.
+// It blocks, so run it in its own goroutine.
+func StartCleaningTaskSweeper(cfg *config.Config, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		_, err := cfg.MongoClient.Database(cfg.DBName).Collection("cleaning_tasks").UpdateMany(ctx,
+			bson.M{
+				"status":        models.TaskScheduled,
+				"scheduled_for": bson.M{"$lt": startOfDay(time.Now())},
+			},
+			bson.M{"$set": bson.M{"status": models.TaskMissed, "updated_at": time.Now()}},
+		)
+		if err != nil {
+			log.Printf("cleaning tasks: could not mark missed tasks: %v", err)
+		}
+		cancel()
+	}
+}
+
+// =============================
+// Helpers
+// =============================
+
+// syncCleaningTask keeps a booking's turnover task in line with the booking:
+// confirmed bookings get a task on their checkout day (moved if the dates
+// changed), cancelled and no-show bookings have theirs cancelled. Errors are
+// logged, never surfaced, since the booking itself has already been saved.
+func syncCleaningTask(ctx context.Context, cfg *config.Config, booking models.Booking, property models.Property) {
+	col := cfg.MongoClient.Database(cfg.DBName).Collection("cleaning_tasks")
+
+	switch booking.Status {
+	case models.BookingCancelled, models.BookingNoShow:
+		cancelCleaningTasks(ctx, cfg, []primitive.ObjectID{booking.ID})
+		return
+	case models.BookingConfirmed, models.BookingCheckedIn:
+	default:
+		return
+	}
+
+	checkout := startOfDay(booking.EndDate)
+
+	live := bson.M{"booking_id": booking.ID, "status": bson.M{"$in": models.LiveTaskStatuses}}
+
+	var task models.CleaningTask
+	err := col.FindOne(ctx, live).Decode(&task)
+	switch {
+	case err == nil:
+		if task.ScheduledFor.Equal(checkout) || task.Status != models.TaskScheduled {
+			return
+		}
+		if _, err := col.UpdateOne(ctx, bson.M{"_id": task.ID}, bson.M{"$set": bson.M{
+			"scheduled_for": checkout,
+			"updated_at":    time.Now(),
+		}}); err != nil {
+			log.Printf("cleaning tasks: could not move task %s: %v", task.ID.Hex(), err)
+			return
+		}
+		if task.HousekeeperID != nil {
+			_ = utils.CreateNotification(cfg, []primitive.ObjectID{*task.HousekeeperID}, "Cleaning Rescheduled", "The turnover clean at "+property.Title+" moved to "+checkout.Format(dayLayout)+".")
+		}
+		return
+	case err != mongo.ErrNoDocuments:
+		log.Printf("cleaning tasks: could not load task for booking %s: %v", booking.ID.Hex(), err)
+		return
+	}
+
+	housekeeper, err := leastBusyHousekeeper(ctx, cfg, property.Housekeepers, checkout)
+	if err != nil {
+		log.Printf("cleaning tasks: could not pick housekeeper for %s: %v", property.ID.Hex(), err)
+	}
+
+	now := time.Now()
+	task = models.CleaningTask{
+		ID:            primitive.NewObjectID(),
+		PropertyID:    property.ID,
+		BookingID:     booking.ID,
+		HousekeeperID: housekeeper,
+		ScheduledFor:  checkout,
+		Status:        models.TaskScheduled,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	// Upsert against the unique live-task index: if a concurrent update of the
+	// same booking got there first, its task stands and this one is dropped
+	res, err := col.UpdateOne(ctx, live, bson.M{"$setOnInsert": task}, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return
+	}
+	if err != nil {
+		log.Printf("cleaning tasks: could not create task for booking %s: %v", booking.ID.Hex(), err)
+		return
+	}
+	if res.UpsertedCount == 0 {
+		return
+	}
+
+	if housekeeper != nil {
+		_ = utils.CreateNotification(cfg, []primitive.ObjectID{*housekeeper}, "Cleaning Scheduled", "You have a turnover clean at "+property.Title+" on "+checkout.Format(dayLayout)+".")
+	}
+}
+
+// cancelCleaningTasks cancels the open tasks of the given bookings
+func cancelCleaningTasks(ctx context.Context, cfg *config.Config, bookingIDs []primitive.ObjectID) {
+	if len(bookingIDs) == 0 {
+		return
+	}
+	_, err := cfg.MongoClient.Database(cfg.DBName).Collection("cleaning_tasks").UpdateMany(ctx,
+		bson.M{"booking_id": bson.M{"$in": bookingIDs}, "status": bson.M{"$in": models.OpenTaskStatuses}},
+		bson.M{"$set": bson.M{"status": models.TaskCancelled, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		log.Printf("cleaning tasks: could not cancel tasks: %v", err)
+	}
+}
+
+// leastBusyHousekeeper picks the housekeeper with the fewest open tasks on
+// day, keeping the property's order on ties. It returns nil if there are none.
+func leastBusyHousekeeper(ctx context.Context, cfg *config.Config, housekeepers []primitive.ObjectID, day time.Time) (*primitive.ObjectID, error) {
+	if len(housekeepers) == 0 {
+		return nil, nil
+	}
+
+	col := cfg.MongoClient.Database(cfg.DBName).Collection("cleaning_tasks")
+	best := housekeepers[0]
+	bestLoad := int64(-1)
+	for _, id := range housekeepers {
+		load, err := col.CountDocuments(ctx, bson.M{
+			"housekeeper_id": id,
+			"scheduled_for":  day,
+			"status":         bson.M{"$in": models.OpenTaskStatuses},
+		})
+		if err != nil {
+			return &best, err
+		}
+		if bestLoad < 0 || load < bestLoad {
+			best, bestLoad = id, load
+		}
+	}
+	return &best, nil
+}
+
+func containsID(ids []primitive.ObjectID, id primitive.ObjectID) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
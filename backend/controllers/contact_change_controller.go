@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/phillip/backend/config"
+	"github.com/phillip/backend/models"
+	"github.com/phillip/backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ConfirmContactChange - commits the new email or phone started through
+// UpdateUser once the code sent for it is confirmed
+func ConfirmContactChange(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Code string `json:"code" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		user, ok := loadRequester(ctx, c, cfg)
+		if !ok {
+			return
+		}
+		change := user.ContactChange
+		if change == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "no email or phone change is pending"})
+			return
+		}
+
+		byAccount := throttleCheck{otpVerifyPerAccount, user.ID.Hex()}
+		if !checkThrottles(ctx, c, cfg, byAccount) {
+			return
+		}
+		if time.Now().After(change.ExpiresAt) || !utils.CheckOTP(cfg, user.ID, change.Code, input.Code) {
+			hitThrottles(ctx, cfg, byAccount)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "code expired or invalid"})
+			return
+		}
+
+		// Someone may have claimed the address since the code was sent
+		taken, err := contactTaken(ctx, cfg, change.Field, change.Value, user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not update " + change.Field})
+			return
+		}
+		if taken {
+			c.JSON(http.StatusConflict, gin.H{"error": change.Field + " already registered"})
+			return
+		}
+
+		now := time.Now()
+		set := bson.M{change.Field: change.Value, "updated_at": now}
+		unset := bson.M{"contact_change": ""}
+		switch {
+		case change.Field == "email":
+			set["email_verified_at"] = now
+		case change.Channel == models.ChannelSMS || change.Channel == models.ChannelWhatsApp:
+			set["phone_verified_at"] = now
+		default:
+			// The code went to the email, which says nothing about the phone
+			unset["phone_verified_at"] = ""
+		}
+
+		// Conditional on the code, so the change is committed once
+		res, err := cfg.MongoClient.Database(cfg.DBName).Collection("users").UpdateOne(ctx,
+			bson.M{"_id": user.ID, "contact_change.code": change.Code},
+			bson.M{"$set": set, "$unset": unset},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not update " + change.Field})
+			return
+		}
+		if res.ModifiedCount == 0 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "code expired or invalid"})
+			return
+		}
+		resetThrottles(ctx, cfg, byAccount)
+
+		// Let the old address know, in case this wasn't the owner
+		if change.Field == "email" {
+			sendMessage(cfg, models.ChannelEmail, utils.Message{
+				To:      user.Email,
+				Subject: "Your email address was changed",
+				Text:    "The email on your account was changed to " + change.Value + ".",
+				HTML:    utils.BuildContactChangedEmail(user.Name, change.Field, change.Value),
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":    change.Field + " updated",
+			change.Field: change.Value,
+		})
+	}
+}
+
+// CancelContactChange - drops a pending email or phone change
+func CancelContactChange(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := primitive.ObjectIDFromHex(c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "not authorized"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		_, err = cfg.MongoClient.Database(cfg.DBName).Collection("users").UpdateOne(ctx,
+			bson.M{"_id": userID},
+			bson.M{"$unset": bson.M{"contact_change": ""}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not cancel change"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "change cancelled"})
+	}
+}
+
+// =============================
+// Helpers
+// =============================
+
+// startContactChange parks a new email or phone on the user and sends a code
+// for it; false means a response was already written. Codes go to the new
+// address, which proves it, except phone codes when neither SMS nor WhatsApp
+// is configured: those go to the email and leave the phone unverified.
+func startContactChange(ctx context.Context, c *gin.Context, cfg *config.Config, user models.User, field, value string) bool {
+	byAccount := throttleCheck{otpIssuePerAccount, user.ID.Hex()}
+	if !checkThrottles(ctx, c, cfg, byAccount) {
+		return false
+	}
+
+	taken, err := contactTaken(ctx, cfg, field, value, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not update " + field})
+		return false
+	}
+	if taken {
+		c.JSON(http.StatusConflict, gin.H{"error": field + " already registered"})
+		return false
+	}
+
+	code, err := utils.NewOTP()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not create code"})
+		return false
+	}
+	channel, to := models.ChannelEmail, value
+	if field == "phone" {
+		channel = user.PhoneMessageChannel()
+		if _, err := utils.MessengerFor(cfg, channel); err != nil {
+			channel, to = models.ChannelEmail, user.Email
+		}
+	}
+
+	change := models.ContactChange{
+		Field:     field,
+		Value:     value,
+		Code:      utils.HashOTP(cfg, user.ID, code),
+		Channel:   channel,
+		ExpiresAt: time.Now().Add(otpTTL),
+	}
+	_, err = cfg.MongoClient.Database(cfg.DBName).Collection("users").UpdateOne(ctx,
+		bson.M{"_id": user.ID},
+		bson.M{"$set": bson.M{"contact_change": change}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not save code"})
+		return false
+	}
+	hitThrottles(ctx, cfg, byAccount)
+
+	sendCode(cfg, user, channel, to, "Confirm your new "+field, code)
+	return true
+}
+
+// contactTaken reports whether another account holds the email or phone.
+// Registrations left unverified past their deadline don't count.
+func contactTaken(ctx context.Context, cfg *config.Config, field, value string, except primitive.ObjectID) (bool, error) {
+	n, err := cfg.MongoClient.Database(cfg.DBName).Collection("users").CountDocuments(ctx, bson.M{
+		field: value,
+		"_id": bson.M{"$ne": except},
+		"$or": bson.A{
+			bson.M{"status": bson.M{"$ne": models.UserPending}},
+			bson.M{"pending_expires_at": bson.M{"$gt": time.Now()}},
+		},
+	})
+	return n > 0, err
+}
@@ -0,0 +1,262 @@
+package controllers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/phillip/backend/config"
+	"github.com/phillip/backend/models"
+	"github.com/phillip/backend/utils"
+)
+
+const defaultRotationDays = 180
+
+// CredentialHealth - score every credential the requester can read (or those
+// in ?property_id= / ?vault_id=). Passwords are decrypted server-side and never
+// returned: reused ones are only grouped, weak ones only flagged. ?max_age_days
+// (default 180) sets when an unchanged password counts as stale.
+func CredentialHealth(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		maxAgeDays := defaultRotationDays
+		if v := c.Query("max_age_days"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "max_age_days must be a positive integer"})
+				return
+			}
+			maxAgeDays = n
+		}
+
+		scope, ok := requestScope(c)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		vaults, err := accessibleVaults(ctx, cfg, scope.UserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch vaults"})
+			return
+		}
+		conditions := bson.A{reachableCredentials(scope, vaults)}
+		for _, field := range []string{"property_id", "vault_id"} {
+			id, ok, err := utils.QueryObjectID(c, field)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if ok {
+				conditions = append(conditions, bson.M{field: id})
+			}
+		}
+
+		cursor, err := cfg.MongoClient.Database(cfg.DBName).Collection("credentials").Find(ctx, bson.M{"$and": conditions})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch credentials"})
+			return
+		}
+		var creds []models.Credential
+		if err := cursor.All(ctx, &creds); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not decode credentials"})
+			return
+		}
+
+		// Reuse is found by comparing keyed hashes. The key lives only for this
+		// request, so the fingerprints can't be matched against anything else.
+		hashKey := make([]byte, 32)
+		if _, err := rand.Read(hashKey); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not analyse credentials"})
+			return
+		}
+
+		type analysed struct {
+			cred        models.Credential
+			strength    utils.PasswordStrength
+			fingerprint string
+		}
+		keys := utils.NewDataKeys(cfg)
+		results := make([]analysed, 0, len(creds))
+		undecryptable := []string{}
+		byFingerprint := map[string][]string{}
+		for _, cr := range creds {
+			owner, ownerType := cr.KeyOwner()
+			key, err := keys.For(ctx, owner, ownerType)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "could not load encryption key"})
+				return
+			}
+			password, err := key.Open(cr.PasswordEncrypted)
+			if err != nil {
+				undecryptable = append(undecryptable, cr.ID.Hex())
+				continue
+			}
+
+			mac := hmac.New(sha256.New, hashKey)
+			mac.Write([]byte(password))
+			fp := string(mac.Sum(nil))
+
+			results = append(results, analysed{cred: cr, strength: utils.CheckPasswordStrength(password), fingerprint: fp})
+			byFingerprint[fp] = append(byFingerprint[fp], cr.ID.Hex())
+		}
+
+		// ✅ Number the reuse groups in a stable order
+		groupOf := map[string]int{}
+		var reuseGroups [][]string
+		for _, r := range results {
+			ids := byFingerprint[r.fingerprint]
+			if len(ids) < 2 {
+				continue
+			}
+			if _, seen := groupOf[r.fingerprint]; !seen {
+				groupOf[r.fingerprint] = len(reuseGroups) + 1
+				reuseGroups = append(reuseGroups, ids)
+			}
+		}
+
+		now := time.Now()
+		staleAfter := time.Duration(maxAgeDays) * 24 * time.Hour
+		summary := gin.H{"total": len(creds), "analysed": len(results), "undecryptable": len(undecryptable)}
+		weak, short, reused, stale, scoreSum := 0, 0, 0, 0, 0
+
+		out := make([]gin.H, 0, len(results))
+		for _, r := range results {
+			changed := r.cred.UpdatedAt
+			if changed.IsZero() {
+				changed = r.cred.CreatedAt
+			}
+			isStale := now.Sub(changed) > staleAfter
+			group := groupOf[r.fingerprint]
+
+			score := 100
+			issues := []string{}
+			if r.strength.Common {
+				issues = append(issues, "common")
+			}
+			if r.strength.Weak {
+				score -= 40
+				issues = append(issues, "weak")
+				weak++
+			}
+			if r.strength.Short {
+				score -= 20
+				issues = append(issues, "short")
+				short++
+			}
+			if group > 0 {
+				score -= 30
+				issues = append(issues, "reused")
+				reused++
+			}
+			if isStale {
+				score -= 10
+				issues = append(issues, "stale")
+				stale++
+			}
+			if score < 0 {
+				score = 0
+			}
+			scoreSum += score
+
+			entry := gin.H{
+				"id":                r.cred.ID.Hex(),
+				"site_name":         r.cred.SiteName,
+				"username":          r.cred.Username,
+				"property_id":       r.cred.PropertyID,
+				"vault_id":          r.cred.VaultID,
+				"score":             score,
+				"issues":            issues,
+				"length":            r.strength.Length,
+				"entropy_bits":      r.strength.Entropy,
+				"days_since_update": int(now.Sub(changed).Hours() / 24),
+				"updated_at":        r.cred.UpdatedAt,
+			}
+			if group > 0 {
+				entry["reuse_group"] = group
+			}
+			out = append(out, entry)
+		}
+
+		// Worst first
+		sort.SliceStable(out, func(i, j int) bool { return out[i]["score"].(int) < out[j]["score"].(int) })
+
+		groups := make([]gin.H, 0, len(reuseGroups))
+		for i, ids := range reuseGroups {
+			groups = append(groups, gin.H{"group": i + 1, "credential_ids": ids})
+		}
+
+		summary["weak"] = weak
+		summary["short"] = short
+		summary["reused"] = reused
+		summary["reuse_groups"] = len(reuseGroups)
+		summary["stale"] = stale
+		summary["max_age_days"] = maxAgeDays
+		summary["average_score"] = 0.0
+		if len(results) > 0 {
+			summary["average_score"] = math.Round(float64(scoreSum)/float64(len(results))*10) / 10
+		}
+
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, gin.H{
+			"summary":       summary,
+			"credentials":   out,
+			"reuse_groups":  groups,
+			"undecryptable": undecryptable,
+		})
+	}
+}
+
+// GeneratePassword - return a random password. Body (all optional): length
+// (default 20), lowercase, uppercase, digits, symbols (default true) and
+// exclude_ambiguous (default false).
+func GeneratePassword() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		input := struct {
+			Length           int   `json:"length"`
+			Lowercase        *bool `json:"lowercase"`
+			Uppercase        *bool `json:"uppercase"`
+			Digits           *bool `json:"digits"`
+			Symbols          *bool `json:"symbols"`
+			ExcludeAmbiguous bool  `json:"exclude_ambiguous"`
+		}{Length: 20}
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&input); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		on := func(b *bool) bool { return b == nil || *b }
+		password, err := utils.GeneratePassword(utils.PasswordOptions{
+			Length:           input.Length,
+			Lowercase:        on(input.Lowercase),
+			Uppercase:        on(input.Uppercase),
+			Digits:           on(input.Digits),
+			Symbols:          on(input.Symbols),
+			ExcludeAmbiguous: input.ExcludeAmbiguous,
+		})
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		strength := utils.CheckPasswordStrength(password)
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, gin.H{
+			"password":     password,
+			"length":       strength.Length,
+			"entropy_bits": strength.Entropy,
+		})
+	}
+}
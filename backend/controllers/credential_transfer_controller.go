@@ -0,0 +1,466 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/phillip/backend/config"
+	"github.com/phillip/backend/models"
+	"github.com/phillip/backend/utils"
+)
+
+const (
+	maxImportFileSize = 5 << 20 // 5 MB
+	maxImportRows     = 5000
+)
+
+// Each backup import runs the passphrase KDF, so an account gets a few tries
+// before it has to wait
+var backupImportPerAccount = utils.Throttle{Name: "backup-import:user", Limit: 5, Window: 15 * time.Minute, Lockout: 15 * time.Minute, MaxLockout: time.Hour}
+
+// backupPayload is what an encrypted export decrypts to
+type backupPayload struct {
+	ExportedAt  time.Time          `json:"exported_at"`
+	Credentials []backupCredential `json:"credentials"`
+}
+
+type backupCredential struct {
+	SiteName string `json:"site_name"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	LoginURL string `json:"login_url,omitempty"`
+	Notes    string `json:"notes,omitempty"`
+	Category string `json:"category,omitempty"`
+	TOTP     string `json:"totp,omitempty"` // otpauth:// URI
+}
+
+// ImportCredentialsCSV - bulk import a Chrome, Firefox or Bitwarden CSV export
+// (form field "file"). Form fields: dry_run=true to only report, and
+// property_id or vault_id to import into a shared container.
+func ImportCredentialsCSV(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw, ok := readImportFile(c)
+		if !ok {
+			return
+		}
+
+		format, entries, skipped, err := utils.ParseCredentialCSV(bytes.NewReader(raw), maxImportRows)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		importCredentialEntries(c, cfg, format, entries, skipped)
+	}
+}
+
+// ImportCredentialsBackup - restore an archive made by ExportCredentials
+// (form fields "file" and "passphrase", plus dry_run, property_id, vault_id).
+// Attempts are limited per account, since each one derives the archive key.
+func ImportCredentialsBackup(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		passphrase := c.PostForm("passphrase")
+		if passphrase == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "passphrase is required"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		limit := throttleCheck{backupImportPerAccount, c.GetString("user_id")}
+		if !checkThrottles(ctx, c, cfg, limit) {
+			return
+		}
+
+		raw, ok := readImportFile(c)
+		if !ok {
+			return
+		}
+
+		hitThrottles(ctx, cfg, limit)
+		plain, err := utils.OpenBackup(passphrase, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var payload backupPayload
+		if err := json.Unmarshal(plain, &payload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "backup contents are not valid"})
+			return
+		}
+		if len(payload.Credentials) > maxImportRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "backup has more than " + strconv.Itoa(maxImportRows) + " credentials"})
+			return
+		}
+
+		var entries []utils.ImportedCredential
+		var skipped []utils.SkippedRow
+		for i, b := range payload.Credentials {
+			if b.SiteName == "" || b.Password == "" {
+				skipped = append(skipped, utils.SkippedRow{Row: i + 1, Reason: "missing site name or password"})
+				continue
+			}
+			entries = append(entries, utils.ImportedCredential{
+				Row:      i + 1,
+				SiteName: b.SiteName,
+				Username: b.Username,
+				Password: b.Password,
+				LoginURL: b.LoginURL,
+				Notes:    b.Notes,
+				Category: b.Category,
+				TOTP:     b.TOTP,
+			})
+		}
+
+		importCredentialEntries(c, cfg, "backup", entries, skipped)
+	}
+}
+
+// ExportCredentials - download the credentials in one container (personal by
+// default, or property_id / vault_id) as a passphrase-encrypted JSON archive
+func ExportCredentials(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := primitive.ObjectIDFromHex(c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user id"})
+			return
+		}
+
+		var input struct {
+			Passphrase string `json:"passphrase" binding:"required"`
+			PropertyID string `json:"property_id"`
+			VaultID    string `json:"vault_id"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if len(input.Passphrase) < utils.MinBackupPassphrase {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "passphrase must be at least " + strconv.Itoa(utils.MinBackupPassphrase) + " characters"})
+			return
+		}
+
+		scope, ok := requestScope(c)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		container := models.Credential{UserID: userID}
+		if !resolveContainer(ctx, c, cfg, scope, &container, input.PropertyID, input.VaultID, models.VaultAccessRead) {
+			return
+		}
+
+		cursor, err := cfg.MongoClient.Database(cfg.DBName).Collection("credentials").Find(ctx, containerFilter(container))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch credentials"})
+			return
+		}
+		var creds []models.Credential
+		if err := cursor.All(ctx, &creds); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not decode credentials"})
+			return
+		}
+
+		owner, ownerType := container.KeyOwner()
+		key, err := utils.NewDataKeys(cfg).For(ctx, owner, ownerType)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not load encryption key"})
+			return
+		}
+
+		payload := backupPayload{ExportedAt: time.Now().UTC(), Credentials: make([]backupCredential, 0, len(creds))}
+		for _, cr := range creds {
+			pass, err := key.Open(cr.PasswordEncrypted)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "could not decrypt credential " + cr.ID.Hex()})
+				return
+			}
+			var totp string
+			if cr.TOTPEncrypted != "" {
+				if totp, err = key.Open(cr.TOTPEncrypted); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "could not decrypt credential " + cr.ID.Hex()})
+					return
+				}
+			}
+			payload.Credentials = append(payload.Credentials, backupCredential{
+				SiteName: cr.SiteName,
+				Username: cr.Username,
+				Password: pass,
+				LoginURL: cr.LoginURL,
+				Notes:    cr.Notes,
+				Category: cr.Category,
+				TOTP:     totp,
+			})
+		}
+
+		plain, err := json.Marshal(payload)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not build export"})
+			return
+		}
+		archive, err := utils.SealBackup(input.Passphrase, plain)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not encrypt export"})
+			return
+		}
+
+		// An export reveals every password in it, so it is audited like a reveal
+		if err := utils.RecordCredentialEvents(cfg, c, creds, models.AuditExport); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not record access"})
+			return
+		}
+
+		c.Header("Cache-Control", "no-store")
+		c.Header("Content-Disposition", `attachment; filename="credentials-`+time.Now().UTC().Format("20060102")+`.json"`)
+		c.JSON(http.StatusOK, archive)
+	}
+}
+
+// =============================
+// Helpers
+// =============================
+
+// importCredentialEntries saves parsed entries into the container named by the
+// form's property_id / vault_id, skipping ones that duplicate an existing
+// credential (or an earlier row) by site and username. With dry_run it only
+// reports what would happen.
+func importCredentialEntries(c *gin.Context, cfg *config.Config, format string, entries []utils.ImportedCredential, skipped []utils.SkippedRow) {
+	userID, err := primitive.ObjectIDFromHex(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user id"})
+		return
+	}
+	dryRun, _ := strconv.ParseBool(c.PostForm("dry_run"))
+
+	scope, ok := requestScope(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	container := models.Credential{UserID: userID}
+	if !resolveContainer(ctx, c, cfg, scope, &container, c.PostForm("property_id"), c.PostForm("vault_id"), models.VaultAccessWrite) {
+		return
+	}
+
+	col := cfg.MongoClient.Database(cfg.DBName).Collection("credentials")
+
+	// ✅ Index what is already in the container
+	cursor, err := col.Find(ctx, containerFilter(container),
+		options.Find().SetProjection(bson.M{"site_name": 1, "login_url": 1, "username": 1}))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch existing credentials"})
+		return
+	}
+	var existing []models.Credential
+	if err := cursor.All(ctx, &existing); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not decode existing credentials"})
+		return
+	}
+	seen := map[string]string{} // dedupe key → existing credential id, or "row N"
+	for _, cr := range existing {
+		seen[dedupeKey(cr.SiteName, cr.LoginURL, cr.Username)] = cr.ID.Hex()
+	}
+
+	owner, ownerType := container.KeyOwner()
+	key, err := utils.NewDataKeys(cfg).For(ctx, owner, ownerType)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not load encryption key"})
+		return
+	}
+
+	total := len(entries) + len(skipped)
+	duplicates := []gin.H{}
+	var toCreate []models.Credential
+	now := time.Now()
+	for _, e := range entries {
+		k := dedupeKey(e.SiteName, e.LoginURL, e.Username)
+		if match, ok := seen[k]; ok {
+			duplicates = append(duplicates, gin.H{"row": e.Row, "site_name": e.SiteName, "username": e.Username, "duplicate_of": match})
+			continue
+		}
+
+		var totpEnc string
+		if e.TOTP != "" {
+			totp, err := utils.ParseTOTP(e.TOTP)
+			if err != nil {
+				skipped = append(skipped, utils.SkippedRow{Row: e.Row, Reason: err.Error()})
+				continue
+			}
+			if totpEnc, err = key.Seal(totp.URI()); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "encryption failed"})
+				return
+			}
+		}
+		seen[k] = "row " + strconv.Itoa(e.Row)
+
+		enc, err := key.Seal(e.Password)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "encryption failed"})
+			return
+		}
+		toCreate = append(toCreate, models.Credential{
+			ID:                primitive.NewObjectID(),
+			UserID:            userID,
+			PropertyID:        container.PropertyID,
+			VaultID:           container.VaultID,
+			SiteName:          e.SiteName,
+			Username:          e.Username,
+			PasswordEncrypted: enc,
+			TOTPEncrypted:     totpEnc,
+			LoginURL:          e.LoginURL,
+			Notes:             e.Notes,
+			Category:          e.Category,
+			CreatedAt:         now,
+			UpdatedAt:         now,
+		})
+	}
+
+	if skipped == nil {
+		skipped = []utils.SkippedRow{}
+	}
+	report := gin.H{
+		"format":     format,
+		"dry_run":    dryRun,
+		"total":      total,
+		"duplicates": duplicates,
+		"skipped":    skipped,
+	}
+
+	if dryRun {
+		report["would_create"] = len(toCreate)
+		c.JSON(http.StatusOK, report)
+		return
+	}
+
+	if len(toCreate) > 0 {
+		docs := make([]interface{}, 0, len(toCreate))
+		for _, cr := range toCreate {
+			docs = append(docs, cr)
+		}
+		if _, err := col.InsertMany(ctx, docs); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not save credentials"})
+			return
+		}
+		if vaultRemoved(ctx, cfg, container.VaultID) {
+			ids := make([]primitive.ObjectID, 0, len(toCreate))
+			for _, cr := range toCreate {
+				ids = append(ids, cr.ID)
+			}
+			_, _ = col.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+			c.JSON(http.StatusNotFound, gin.H{"error": "vault not found"})
+			return
+		}
+		if err := utils.RecordCredentialEvents(cfg, c, toCreate, models.AuditImport); err != nil {
+			log.Printf("credential audit: could not record import of %d credentials: %v", len(toCreate), err)
+		}
+	}
+
+	report["created"] = len(toCreate)
+	c.JSON(http.StatusOK, report)
+}
+
+// readImportFile reads the uploaded "file" form field, capped at maxImportFileSize
+func readImportFile(c *gin.Context) ([]byte, bool) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return nil, false
+	}
+	if fileHeader.Size > maxImportFileSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file is larger than 5 MB"})
+		return nil, false
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to open file"})
+		return nil, false
+	}
+	defer file.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(file, maxImportFileSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "could not read file"})
+		return nil, false
+	}
+	return raw, true
+}
+
+// resolveContainer points container at the property or vault named (or leaves
+// it personal), checking the requester has the wanted access there
+func resolveContainer(ctx context.Context, c *gin.Context, cfg *config.Config, scope *utils.Scope, container *models.Credential, propertyHex, vaultHex, want string) bool {
+	if want == models.VaultAccessWrite {
+		return attachCredential(ctx, c, cfg, scope, container, propertyHex, vaultHex)
+	}
+
+	if propertyHex != "" && vaultHex != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "choose a property or a vault, not both"})
+		return false
+	}
+	if propertyHex != "" {
+		id, err := primitive.ObjectIDFromHex(propertyHex)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid property id"})
+			return false
+		}
+		if propertyCredentialAccess(scope, id) == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "property not found"})
+			return false
+		}
+		container.PropertyID = &id
+	}
+	if vaultHex != "" {
+		id, err := primitive.ObjectIDFromHex(vaultHex)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid vault id"})
+			return false
+		}
+		var vault models.SharedVault
+		err = cfg.MongoClient.Database(cfg.DBName).Collection("shared_vaults").
+			FindOne(ctx, bson.M{"_id": id}).Decode(&vault)
+		if err != nil || vault.AccessFor(scope.UserID) == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "vault not found"})
+			return false
+		}
+		container.VaultID = &id
+	}
+	return true
+}
+
+// containerFilter matches the credentials stored alongside container
+func containerFilter(container models.Credential) bson.M {
+	switch {
+	case container.VaultID != nil:
+		return bson.M{"vault_id": *container.VaultID}
+	case container.PropertyID != nil:
+		return bson.M{"property_id": *container.PropertyID}
+	}
+	return bson.M{"user_id": container.UserID, "property_id": nil, "vault_id": nil}
+}
+
+// dedupeKey identifies a login by site (the URL's host when there is one) and username
+func dedupeKey(siteName, loginURL, username string) string {
+	site := utils.SiteFromURL(loginURL)
+	if site == "" {
+		site = strings.ToLower(strings.TrimSpace(siteName))
+	}
+	return site + "\x00" + strings.ToLower(strings.TrimSpace(username))
+}
@@ -0,0 +1,40 @@
+package controllers
+
+import "testing"
+
+func TestDedupeKey(t *testing.T) {
+	base := dedupeKey("Airbnb", "https://www.airbnb.com/login", "Host@Example.com")
+
+	same := map[string][3]string{
+		"other path and www":         {"Airbnb", "https://airbnb.com/account", "host@example.com"},
+		"name ignored with a url":    {"My Airbnb", "https://www.airbnb.com/", "host@example.com"},
+		"username case and spaces":   {"Airbnb", "https://airbnb.com", "  HOST@example.com "},
+		"host case and default port": {"Airbnb", "https://WWW.AIRBNB.COM:443/", "host@example.com"},
+	}
+	for name, in := range same {
+		if got := dedupeKey(in[0], in[1], in[2]); got != base {
+			t.Errorf("%s: %q, want %q", name, got, base)
+		}
+	}
+
+	different := map[string][3]string{
+		"other username":  {"Airbnb", "https://airbnb.com", "cohost@example.com"},
+		"other site":      {"Airbnb", "https://vrbo.com", "host@example.com"},
+		"subdomain":       {"Airbnb", "https://admin.airbnb.com", "host@example.com"},
+		"name but no url": {"Airbnb", "", "host@example.com"},
+	}
+	for name, in := range different {
+		if got := dedupeKey(in[0], in[1], in[2]); got == base {
+			t.Errorf("%s: collides with %q", name, base)
+		}
+	}
+
+	// Without a URL the site name identifies the login, ignoring case
+	if dedupeKey(" Router ", "", "admin") != dedupeKey("router", "", "ADMIN") {
+		t.Error("site names differing only in case and spaces don't match")
+	}
+	// The separator keeps site and username apart
+	if dedupeKey("ab", "", "c") == dedupeKey("a", "", "bc") {
+		t.Error("site and username run together")
+	}
+}
@@ -5,7 +5,10 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"fmt"
+	"log"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -17,7 +20,8 @@ import (
 	"github.com/phillip/backend/utils"
 )
 
-// CreateCredential - Add new password/credential
+// CreateCredential - Add new password/credential. It is personal unless
+// property_id or vault_id shares it with that property's team or vault.
 func CreateCredential(cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		uid := c.GetString("user_id")
@@ -29,12 +33,15 @@ func CreateCredential(cfg *config.Config) gin.HandlerFunc {
 
 
 		var input struct {
-			SiteName string `json:"site_name" binding:"required"`
-			Username string `json:"username" binding:"required"`
-			Password string `json:"password" binding:"required"`
-			LoginURL string `json:"login_url"`
-			Notes    string `json:"notes"`
-			Category string `json:"category"`
+			SiteName   string `json:"site_name" binding:"required"`
+			Username   string `json:"username" binding:"required"`
+			Password   string `json:"password" binding:"required"`
+			LoginURL   string `json:"login_url"`
+			Notes      string `json:"notes"`
+			Category   string `json:"category"`
+			PropertyID string `json:"property_id"`
+			VaultID    string `json:"vault_id"`
+			TOTP       string `json:"totp"` // otpauth:// URI or base32 key
 		}
 
 		if err := c.ShouldBindJSON(&input); err != nil {
@@ -42,39 +49,76 @@ func CreateCredential(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
-		enc, err := utils.Encrypt(cfg.AESKey, input.Password)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "encryption failed"})
-			return
+		var totp *utils.TOTP
+		if input.TOTP != "" {
+			t, err := utils.ParseTOTP(input.TOTP)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			totp = &t
 		}
 
-		cred := models.Credential{
-			ID:                primitive.NewObjectID(),
-			UserID:            userID,
-			SiteName:          input.SiteName,
-			Username:          input.Username,
-			PasswordEncrypted: enc,
-			LoginURL:          input.LoginURL,
-			Notes:             input.Notes,
-			Category:          input.Category,
-			CreatedAt:         time.Now(),
-			UpdatedAt:         time.Now(),
+		scope, ok := requestScope(c)
+		if !ok {
+			return
 		}
 
 		col := cfg.MongoClient.Database(cfg.DBName).Collection("credentials")
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
+		cred := models.Credential{
+			ID:        primitive.NewObjectID(),
+			UserID:    userID,
+			SiteName:  input.SiteName,
+			Username:  input.Username,
+			LoginURL:  input.LoginURL,
+			Notes:     input.Notes,
+			Category:  input.Category,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		if !attachCredential(ctx, c, cfg, scope, &cred, input.PropertyID, input.VaultID) {
+			return
+		}
+
+		owner, ownerType := cred.KeyOwner()
+		key, err := utils.NewDataKeys(cfg).For(ctx, owner, ownerType)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not load encryption key"})
+			return
+		}
+		cred.PasswordEncrypted, err = key.Seal(input.Password)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "encryption failed"})
+			return
+		}
+		if totp != nil {
+			if cred.TOTPEncrypted, err = key.Seal(totp.URI()); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "encryption failed"})
+				return
+			}
+		}
+
 		if _, err := col.InsertOne(ctx, cred); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not save credential"})
 			return
 		}
+		if vaultRemoved(ctx, cfg, cred.VaultID) {
+			_, _ = col.DeleteOne(ctx, bson.M{"_id": cred.ID})
+			c.JSON(http.StatusNotFound, gin.H{"error": "vault not found"})
+			return
+		}
+		auditCredential(cfg, c, cred, models.AuditCreate)
 
 		c.JSON(http.StatusCreated, gin.H{"id": cred.ID.Hex(), "message": "credential created"})
 	}
 }
 
-// ListCredentials - Show all credentials for logged-in user
+// ListCredentials - Show the logged-in user's personal credentials plus those
+// shared with them through a property or vault (?property_id=, ?vault_id= narrow it).
+// Metadata only: passwords are fetched one at a time with RevealCredential.
 func ListCredentials(cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		uid := c.GetString("user_id")
@@ -84,20 +128,39 @@ func ListCredentials(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
+		scope, ok := requestScope(c)
+		if !ok {
+			return
+		}
 
 		col := cfg.MongoClient.Database(cfg.DBName).Collection("credentials")
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
-		filter := bson.M{"user_id": userID}
+		vaults, err := accessibleVaults(ctx, cfg, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch vaults"})
+			return
+		}
+		conditions := bson.A{reachableCredentials(scope, vaults)}
 		if q := c.Query("q"); q != "" {
-			filter["$or"] = bson.A{
+			conditions = append(conditions, bson.M{"$or": bson.A{
 				bson.M{"site_name": bson.M{"$regex": q, "$options": "i"}},
 				bson.M{"username": bson.M{"$regex": q, "$options": "i"}},
+			}})
+		}
+		for _, field := range []string{"property_id", "vault_id"} {
+			id, ok, err := utils.QueryObjectID(c, field)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if ok {
+				conditions = append(conditions, bson.M{field: id})
 			}
 		}
 
-		cursor, err := col.Find(ctx, filter)
+		cursor, err := col.Find(ctx, bson.M{"$and": conditions})
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch credentials"})
 			return
@@ -110,16 +173,13 @@ func ListCredentials(cfg *config.Config) gin.HandlerFunc {
 		}
 
 		// --- Build ETag for collection ---
-		combined := ""
+		collectionETag := credentialListETag(scope, vaults, creds)
 		var lastModified time.Time
 		for _, cr := range creds {
-			combined += fmt.Sprintf("%s-%d", cr.ID.Hex(), cr.UpdatedAt.UnixNano())
 			if cr.UpdatedAt.After(lastModified) {
 				lastModified = cr.UpdatedAt
 			}
 		}
-		hash := md5.Sum([]byte(combined))
-		collectionETag := `"` + hex.EncodeToString(hash[:]) + `"`
 
 		// Handle If-None-Match
 		if match := c.GetHeader("If-None-Match"); match != "" && match == collectionETag {
@@ -133,24 +193,21 @@ func ListCredentials(cfg *config.Config) gin.HandlerFunc {
 			c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
 		}
 
-		// Decrypt passwords for output
 		out := make([]gin.H, 0, len(creds))
 		for _, cr := range creds {
-			pass, err := utils.Decrypt(cfg.AESKey, cr.PasswordEncrypted)
-			if err != nil {
-				pass = "" // or skip the record, or log it
-			}
-
 			out = append(out, gin.H{
-				"id":         cr.ID.Hex(),
-				"site_name":  cr.SiteName,
-				"username":   cr.Username,
-				"password":   pass,
-				"login_url":  cr.LoginURL,
-				"notes":      cr.Notes,
-				"category":   cr.Category,
-				"created_at": cr.CreatedAt,
-				"updated_at": cr.UpdatedAt,
+				"id":          cr.ID.Hex(),
+				"site_name":   cr.SiteName,
+				"username":    cr.Username,
+				"login_url":   cr.LoginURL,
+				"notes":       cr.Notes,
+				"category":    cr.Category,
+				"has_totp":    cr.TOTPEncrypted != "",
+				"property_id": cr.PropertyID,
+				"vault_id":    cr.VaultID,
+				"access":      accessFromList(scope, vaults, cr),
+				"created_at":  cr.CreatedAt,
+				"updated_at":  cr.UpdatedAt,
 			})
 		}
 
@@ -158,33 +215,25 @@ func ListCredentials(cfg *config.Config) gin.HandlerFunc {
 	}
 }
 
-// GetCredential - Fetch single credential
+// GetCredential - Fetch single credential's metadata (see RevealCredential for the password)
 func GetCredential(cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		uid := c.GetString("user_id")
-		userID, err := primitive.ObjectIDFromHex(uid)
+		credID, err := primitive.ObjectIDFromHex(c.Param("id"))
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user id"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid credential id"})
 			return
 		}
 
-
-		credID, err := primitive.ObjectIDFromHex(c.Param("id"))
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid credential id"})
+		scope, ok := requestScope(c)
+		if !ok {
 			return
 		}
 
-		var credential models.Credential
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		err = cfg.MongoClient.Database(cfg.DBName).
-			Collection("credentials").
-			FindOne(ctx, bson.M{"_id": credID, "user_id": userID}).
-			Decode(&credential)
-		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "credential not found or not owned"})
+		credential, access, ok := loadAccessibleCredential(ctx, c, cfg, scope, credID, models.VaultAccessRead)
+		if !ok {
 			return
 		}
 
@@ -199,30 +248,230 @@ func GetCredential(cfg *config.Config) gin.HandlerFunc {
 		// --- Add Last-Modified ---
 		c.Header("Last-Modified", credential.UpdatedAt.UTC().Format(http.TimeFormat))
 
-		// Decrypt before sending
-		pass, _ := utils.Decrypt(cfg.AESKey, credential.PasswordEncrypted)
-		credential.PasswordEncrypted = pass
+		c.JSON(http.StatusOK, gin.H{
+			"id":          credential.ID.Hex(),
+			"user_id":     credential.UserID.Hex(),
+			"site_name":   credential.SiteName,
+			"username":    credential.Username,
+			"login_url":   credential.LoginURL,
+			"notes":       credential.Notes,
+			"category":    credential.Category,
+			"has_totp":    credential.TOTPEncrypted != "",
+			"property_id": credential.PropertyID,
+			"vault_id":    credential.VaultID,
+			"access":      access,
+			"created_at":  credential.CreatedAt,
+			"updated_at":  credential.UpdatedAt,
+		})
+	}
+}
+
+// RevealCredential - decrypt one credential's password. Every reveal is
+// audited; if the audit entry can't be written the password is not returned.
+func RevealCredential(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		credID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid credential id"})
+			return
+		}
+
+		scope, ok := requestScope(c)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		credential, _, ok := loadAccessibleCredential(ctx, c, cfg, scope, credID, models.VaultAccessRead)
+		if !ok {
+			return
+		}
+
+		owner, ownerType := credential.KeyOwner()
+		key, err := utils.NewDataKeys(cfg).For(ctx, owner, ownerType)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not load encryption key"})
+			return
+		}
+		pass, err := key.Open(credential.PasswordEncrypted)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not decrypt credential"})
+			return
+		}
+
+		if err := utils.RecordCredentialEvent(cfg, c, credential, models.AuditReveal); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not record access"})
+			return
+		}
 
-		c.JSON(http.StatusOK, credential)
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, gin.H{"id": credential.ID.Hex(), "password": pass})
 	}
 }
 
-// UpdateCredential - Edit credential
-func UpdateCredential(cfg *config.Config) gin.HandlerFunc {
+// CredentialTOTP - the credential's current one-time code and the seconds
+// until it changes. Like a reveal, every call is audited and fails closed.
+func CredentialTOTP(cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// ✅ Get and validate user ID
-		uid := c.GetString("user_id")
-		if uid == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		credID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid credential id"})
 			return
 		}
 
-		userID, err := primitive.ObjectIDFromHex(uid)
+		scope, ok := requestScope(c)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		credential, _, ok := loadAccessibleCredential(ctx, c, cfg, scope, credID, models.VaultAccessRead)
+		if !ok {
+			return
+		}
+		if credential.TOTPEncrypted == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "credential has no TOTP secret"})
+			return
+		}
+
+		owner, ownerType := credential.KeyOwner()
+		key, err := utils.NewDataKeys(cfg).For(ctx, owner, ownerType)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user id"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not load encryption key"})
+			return
+		}
+		uri, err := key.Open(credential.TOTPEncrypted)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not decrypt TOTP secret"})
+			return
+		}
+		totp, err := utils.ParseTOTP(uri)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "stored TOTP secret is invalid"})
+			return
+		}
+
+		if err := utils.RecordCredentialEvent(cfg, c, credential, models.AuditTOTP); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not record access"})
+			return
+		}
+
+		code, remaining := totp.Code(time.Now())
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, gin.H{
+			"id":                credential.ID.Hex(),
+			"code":              code,
+			"remaining_seconds": remaining,
+			"period":            totp.Period,
+			"digits":            totp.Digits,
+		})
+	}
+}
+
+// ListCredentialAudit - access history of one credential, newest first.
+// Anyone with write access to the credential may read it.
+func ListCredentialAudit(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		credID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid credential id"})
+			return
+		}
+
+		params, err := utils.ParseListParams(c, map[string]string{"created_at": "created_at"}, "-created_at")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		scope, ok := requestScope(c)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if _, _, ok := loadAccessibleCredential(ctx, c, cfg, scope, credID, models.VaultAccessWrite); !ok {
+			return
+		}
+
+		entries := []models.CredentialAudit{}
+		col := cfg.MongoClient.Database(cfg.DBName).Collection("credential_audit")
+		info, err := utils.FindPage(ctx, col, bson.M{"credential_id": credID}, params, &entries)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch audit history"})
+			return
+		}
+
+		c.JSON(http.StatusOK, utils.PageResponse(entries, info))
+	}
+}
+
+// QueryCredentialAudit - admin: audit entries across all credentials, including
+// deleted ones, filtered by credential_id, actor_id, owner_id, action and from/to (RFC 3339)
+func QueryCredentialAudit(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		params, err := utils.ParseListParams(c, map[string]string{"created_at": "created_at"}, "-created_at")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
+		filter := bson.M{}
+		for _, field := range []string{"credential_id", "actor_id", "owner_id"} {
+			id, ok, err := utils.QueryObjectID(c, field)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if ok {
+				filter[field] = id
+			}
+		}
+		if action := c.Query("action"); action != "" {
+			filter["action"] = action
+		}
+
+		createdAt := bson.M{}
+		for param, op := range map[string]string{"from": "$gte", "to": "$lt"} {
+			v := c.Query(param)
+			if v == "" {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": param + " must be an RFC 3339 timestamp"})
+				return
+			}
+			createdAt[op] = t
+		}
+		if len(createdAt) > 0 {
+			filter["created_at"] = createdAt
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		entries := []models.CredentialAudit{}
+		col := cfg.MongoClient.Database(cfg.DBName).Collection("credential_audit")
+		info, err := utils.FindPage(ctx, col, filter, params, &entries)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch audit history"})
+			return
+		}
+
+		c.JSON(http.StatusOK, utils.PageResponse(entries, info))
+	}
+}
+
+// UpdateCredential - Edit credential (needs write access)
+func UpdateCredential(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
 		// ✅ Get and validate credential ID
 		oid, err := primitive.ObjectIDFromHex(c.Param("id"))
 		if err != nil {
@@ -232,12 +481,14 @@ func UpdateCredential(cfg *config.Config) gin.HandlerFunc {
 
 		// ✅ Bind input
 		var input struct {
-			SiteName string `json:"site_name"`
-			Username string `json:"username"`
-			Password string `json:"password"`
-			LoginURL string `json:"login_url"`
-			Notes    string `json:"notes"`
-			Category string `json:"category"`
+			SiteName   string `json:"site_name"`
+			Username   string `json:"username"`
+			Password   string `json:"password"`
+			LoginURL   string `json:"login_url"`
+			Notes      string `json:"notes"`
+			Category   string `json:"category"`
+			TOTP       string `json:"totp"` // otpauth:// URI or base32 key
+			RemoveTOTP bool   `json:"remove_totp"`
 		}
 
 		if err := c.ShouldBindJSON(&input); err != nil {
@@ -245,20 +496,38 @@ func UpdateCredential(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
-		// ✅ Find the credential and ensure ownership
+		scope, ok := requestScope(c)
+		if !ok {
+			return
+		}
+
+		// ✅ Find the credential and ensure write access
 		col := cfg.MongoClient.Database(cfg.DBName).Collection("credentials")
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		var existing models.Credential
-		err = col.FindOne(ctx, bson.M{"_id": oid, "user_id": userID}).Decode(&existing)
-		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "credential not found or not owned"})
+		existing, _, ok := loadAccessibleCredential(ctx, c, cfg, scope, oid, models.VaultAccessWrite)
+		if !ok {
 			return
 		}
 
+		if input.TOTP != "" && input.RemoveTOTP {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "send totp or remove_totp, not both"})
+			return
+		}
+		var totp *utils.TOTP
+		if input.TOTP != "" {
+			t, err := utils.ParseTOTP(input.TOTP)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			totp = &t
+		}
+
 		// ✅ Build update document
 		update := bson.M{"updated_at": time.Now()}
+		unset := bson.M{}
 
 		if input.SiteName != "" {
 			update["site_name"] = input.SiteName
@@ -266,13 +535,32 @@ func UpdateCredential(cfg *config.Config) gin.HandlerFunc {
 		if input.Username != "" {
 			update["username"] = input.Username
 		}
-		if input.Password != "" {
-			enc, err := utils.Encrypt(cfg.AESKey, input.Password)
+		if input.Password != "" || totp != nil {
+			owner, ownerType := existing.KeyOwner()
+			key, err := utils.NewDataKeys(cfg).For(ctx, owner, ownerType)
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to encrypt password"})
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "could not load encryption key"})
 				return
 			}
-			update["password_encrypted"] = enc
+			if input.Password != "" {
+				enc, err := key.Seal(input.Password)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to encrypt password"})
+					return
+				}
+				update["password_encrypted"] = enc
+			}
+			if totp != nil {
+				enc, err := key.Seal(totp.URI())
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to encrypt TOTP secret"})
+					return
+				}
+				update["totp_encrypted"] = enc
+			}
+		}
+		if input.RemoveTOTP {
+			unset["totp_encrypted"] = ""
 		}
 		if input.LoginURL != "" {
 			update["login_url"] = input.LoginURL
@@ -285,13 +573,17 @@ func UpdateCredential(cfg *config.Config) gin.HandlerFunc {
 		}
 
 		// ❗ Ensure at least one field is being updated (besides updated_at)
-		if len(update) == 1 {
+		if len(update) == 1 && len(unset) == 0 {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "no fields to update"})
 			return
 		}
 
 		// ✅ Perform update
-		res, err := col.UpdateOne(ctx, bson.M{"_id": oid, "user_id": userID}, bson.M{"$set": update})
+		changes := bson.M{"$set": update}
+		if len(unset) > 0 {
+			changes["$unset"] = unset
+		}
+		res, err := col.UpdateOne(ctx, bson.M{"_id": oid}, changes)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update credential"})
 			return
@@ -301,25 +593,15 @@ func UpdateCredential(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
+		auditCredential(cfg, c, existing, models.AuditUpdate)
+
 		c.JSON(http.StatusOK, gin.H{"message": "credential updated", "id": oid.Hex()})
 	}
 }
 
-// DeleteCredential - Remove credential
+// DeleteCredential - Remove credential (needs write access)
 func DeleteCredential(cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// ✅ Extract and validate user ID
-		uid := c.GetString("user_id")
-		if uid == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
-			return
-		}
-		userID, err := primitive.ObjectIDFromHex(uid)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user id"})
-			return
-		}
-
 		// ✅ Extract and validate credential ID
 		idParam := c.Param("id")
 		oid, err := primitive.ObjectIDFromHex(idParam)
@@ -328,12 +610,22 @@ func DeleteCredential(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
+		scope, ok := requestScope(c)
+		if !ok {
+			return
+		}
+
 		col := cfg.MongoClient.Database(cfg.DBName).Collection("credentials")
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		// ✅ Delete only if the user owns the credential
-		res, err := col.DeleteOne(ctx, bson.M{"_id": oid, "user_id": userID})
+		// ✅ Delete only with write access to the credential
+		existing, _, ok := loadAccessibleCredential(ctx, c, cfg, scope, oid, models.VaultAccessWrite)
+		if !ok {
+			return
+		}
+
+		res, err := col.DeleteOne(ctx, bson.M{"_id": oid})
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete credential"})
 			return
@@ -343,8 +635,205 @@ func DeleteCredential(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
+		auditCredential(cfg, c, existing, models.AuditDelete)
+
 		c.JSON(http.StatusOK, gin.H{"message": "credential deleted", "id": oid.Hex()})
 	}
 }
 
+// =============================
+// Helpers
+// =============================
+
+// auditCredential records a change that has already been saved. A failed
+// write is logged rather than undoing the change.
+func auditCredential(cfg *config.Config, c *gin.Context, cred models.Credential, action string) {
+	if err := utils.RecordCredentialEvent(cfg, c, cred, action); err != nil {
+		log.Printf("credential audit: could not record %s of %s: %v", action, cred.ID.Hex(), err)
+	}
+}
+
+// Access to a credential follows where it lives:
+//   - personal: its creator has write access
+//   - property: the owner has write access, assigned housekeepers read access
+//     (so removing a housekeeper revokes it immediately)
+//   - vault: the vault owner has write access, members their grant
+
+// loadAccessibleCredential fetches a credential the requester can reach with
+// at least the wanted access, writing 404 (no access) or 403 (read-only) otherwise
+func loadAccessibleCredential(ctx context.Context, c *gin.Context, cfg *config.Config, scope *utils.Scope, id primitive.ObjectID, want string) (models.Credential, string, bool) {
+	var cred models.Credential
+	err := cfg.MongoClient.Database(cfg.DBName).Collection("credentials").
+		FindOne(ctx, bson.M{"_id": id}).Decode(&cred)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "credential not found or not owned"})
+		return cred, "", false
+	}
+
+	access, err := credentialAccess(ctx, cfg, scope, cred)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not check access"})
+		return cred, "", false
+	}
+	switch {
+	case access == "":
+		c.JSON(http.StatusNotFound, gin.H{"error": "credential not found or not owned"})
+		return cred, "", false
+	case want == models.VaultAccessWrite && access != models.VaultAccessWrite:
+		c.JSON(http.StatusForbidden, gin.H{"error": "you have read-only access to this credential"})
+		return cred, access, false
+	}
+	return cred, access, true
+}
+
+// credentialAccess returns "write", "read" or "" for the requester
+func credentialAccess(ctx context.Context, cfg *config.Config, scope *utils.Scope, cred models.Credential) (string, error) {
+	switch {
+	case cred.VaultID != nil:
+		var vault models.SharedVault
+		err := cfg.MongoClient.Database(cfg.DBName).Collection("shared_vaults").
+			FindOne(ctx, bson.M{"_id": *cred.VaultID}).Decode(&vault)
+		if err != nil {
+			return "", nil
+		}
+		return vault.AccessFor(scope.UserID), nil
+	case cred.PropertyID != nil:
+		return propertyCredentialAccess(scope, *cred.PropertyID), nil
+	case cred.UserID == scope.UserID:
+		return models.VaultAccessWrite, nil
+	}
+	return "", nil
+}
+
+// accessFromList is credentialAccess for list results, using vaults already loaded
+func accessFromList(scope *utils.Scope, vaults []models.SharedVault, cred models.Credential) string {
+	switch {
+	case cred.VaultID != nil:
+		for _, v := range vaults {
+			if v.ID == *cred.VaultID {
+				return v.AccessFor(scope.UserID)
+			}
+		}
+		return ""
+	case cred.PropertyID != nil:
+		return propertyCredentialAccess(scope, *cred.PropertyID)
+	}
+	return models.VaultAccessWrite
+}
+
+// credentialListETag covers the credentials listed and the vaults and
+// properties the requester reaches, with access levels, so a changed grant
+// changes the tag even when no credential was edited
+func credentialListETag(scope *utils.Scope, vaults []models.SharedVault, creds []models.Credential) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "user:%s|role:%s\n", scope.UserID.Hex(), scope.Role)
 
+	reach := make([]string, 0, len(vaults)+len(scope.Owned)+len(scope.Assigned))
+	for _, v := range vaults {
+		reach = append(reach, "vault:"+v.ID.Hex()+":"+v.AccessFor(scope.UserID))
+	}
+	for _, id := range scope.Owned {
+		reach = append(reach, "property:"+id.Hex()+":"+models.VaultAccessWrite)
+	}
+	for _, id := range scope.Assigned {
+		reach = append(reach, "property:"+id.Hex()+":"+models.VaultAccessRead)
+	}
+	sort.Strings(reach)
+	b.WriteString(strings.Join(reach, ","))
+	b.WriteString("\n")
+
+	for _, cr := range creds {
+		fmt.Fprintf(&b, "%s-%d-%s;", cr.ID.Hex(), cr.UpdatedAt.UnixNano(), accessFromList(scope, vaults, cr))
+	}
+
+	hash := md5.Sum([]byte(b.String()))
+	return `"` + hex.EncodeToString(hash[:]) + `"`
+}
+
+func propertyCredentialAccess(scope *utils.Scope, propertyID primitive.ObjectID) string {
+	switch {
+	case containsID(scope.Owned, propertyID):
+		return models.VaultAccessWrite
+	case containsID(scope.Assigned, propertyID):
+		return models.VaultAccessRead
+	}
+	return ""
+}
+
+// reachableCredentials matches every credential the requester can read:
+// personal ones, those on their owned or assigned properties, and those in vaults
+func reachableCredentials(scope *utils.Scope, vaults []models.SharedVault) bson.M {
+	vaultIDs := make([]primitive.ObjectID, 0, len(vaults))
+	for _, v := range vaults {
+		vaultIDs = append(vaultIDs, v.ID)
+	}
+	propertyIDs := append(append([]primitive.ObjectID{}, scope.Owned...), scope.Assigned...)
+
+	return bson.M{"$or": bson.A{
+		bson.M{"user_id": scope.UserID, "property_id": nil, "vault_id": nil},
+		bson.M{"property_id": bson.M{"$in": propertyIDs}},
+		bson.M{"vault_id": bson.M{"$in": vaultIDs}},
+	}}
+}
+
+// accessibleVaults lists the shared vaults the user owns or is a member of
+func accessibleVaults(ctx context.Context, cfg *config.Config, userID primitive.ObjectID) ([]models.SharedVault, error) {
+	cursor, err := cfg.MongoClient.Database(cfg.DBName).Collection("shared_vaults").Find(ctx, bson.M{
+		"$or": bson.A{
+			bson.M{"owner_id": userID},
+			bson.M{"members.user_id": userID},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	vaults := []models.SharedVault{}
+	if err := cursor.All(ctx, &vaults); err != nil {
+		return nil, err
+	}
+	return vaults, nil
+}
+
+// attachCredential places a new credential on a property or in a shared vault,
+// which needs write access there. Neither means it stays personal.
+func attachCredential(ctx context.Context, c *gin.Context, cfg *config.Config, scope *utils.Scope, cred *models.Credential, propertyHex, vaultHex string) bool {
+	if propertyHex != "" && vaultHex != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a credential can belong to a property or a vault, not both"})
+		return false
+	}
+
+	if propertyHex != "" {
+		id, err := primitive.ObjectIDFromHex(propertyHex)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid property id"})
+			return false
+		}
+		if propertyCredentialAccess(scope, id) != models.VaultAccessWrite {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only the property owner can add its credentials"})
+			return false
+		}
+		cred.PropertyID = &id
+	}
+
+	if vaultHex != "" {
+		id, err := primitive.ObjectIDFromHex(vaultHex)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid vault id"})
+			return false
+		}
+		var vault models.SharedVault
+		err = cfg.MongoClient.Database(cfg.DBName).Collection("shared_vaults").
+			FindOne(ctx, bson.M{"_id": id}).Decode(&vault)
+		if err != nil || vault.AccessFor(scope.UserID) == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "vault not found"})
+			return false
+		}
+		if vault.AccessFor(scope.UserID) != models.VaultAccessWrite {
+			c.JSON(http.StatusForbidden, gin.H{"error": "you have read-only access to this vault"})
+			return false
+		}
+		cred.VaultID = &id
+	}
+
+	return true
+}
@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/phillip/backend/models"
+	"github.com/phillip/backend/utils"
+)
+
+func TestCredentialListETagFollowsAccess(t *testing.T) {
+	user, owner := primitive.NewObjectID(), primitive.NewObjectID()
+	property, vaultID := primitive.NewObjectID(), primitive.NewObjectID()
+	vault := models.SharedVault{ID: vaultID, OwnerID: owner, Members: []models.VaultMember{{UserID: user, Access: models.VaultAccessRead}}}
+	creds := []models.Credential{{ID: primitive.NewObjectID(), VaultID: &vaultID, UpdatedAt: time.Unix(1700000000, 0)}}
+
+	scope := &utils.Scope{UserID: user, Role: models.RoleHost, Owned: []primitive.ObjectID{property}}
+	base := credentialListETag(scope, []models.SharedVault{vault}, creds)
+
+	if again := credentialListETag(scope, []models.SharedVault{vault}, creds); again != base {
+		t.Fatalf("same input gave %s and %s", base, again)
+	}
+
+	upgraded := vault
+	upgraded.Members = []models.VaultMember{{UserID: user, Access: models.VaultAccessWrite}}
+	other := models.SharedVault{ID: primitive.NewObjectID(), OwnerID: user}
+
+	cases := map[string]string{
+		"vault grant changed":  credentialListETag(scope, []models.SharedVault{upgraded}, creds),
+		"vault shared with me": credentialListETag(scope, []models.SharedVault{vault, other}, creds),
+		"vault removed":        credentialListETag(scope, nil, creds),
+		"property lost":        credentialListETag(&utils.Scope{UserID: user, Role: models.RoleHost}, []models.SharedVault{vault}, creds),
+		"another user":         credentialListETag(&utils.Scope{UserID: owner, Role: models.RoleHost, Owned: scope.Owned}, []models.SharedVault{vault}, creds),
+		"credential edited": credentialListETag(scope, []models.SharedVault{vault},
+			[]models.Credential{{ID: creds[0].ID, VaultID: &vaultID, UpdatedAt: time.Unix(1700000001, 0)}}),
+	}
+	for name, tag := range cases {
+		if tag == base {
+			t.Errorf("%s: ETag unchanged", name)
+		}
+	}
+
+	// Order of the reachable set doesn't matter
+	second := primitive.NewObjectID()
+	a := credentialListETag(&utils.Scope{UserID: user, Role: models.RoleHost, Owned: []primitive.ObjectID{property, second}}, nil, nil)
+	b := credentialListETag(&utils.Scope{UserID: user, Role: models.RoleHost, Owned: []primitive.ObjectID{second, property}}, nil, nil)
+	if a != b {
+		t.Error("ETag depends on property order")
+	}
+}
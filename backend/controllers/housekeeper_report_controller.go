@@ -28,6 +28,7 @@ func CreateHousekeeperReport(cfg *config.Config) gin.HandlerFunc {
 		// Bind form data
 		var input struct {
 			PropertyID string `form:"property_id" binding:"required"`
+			TaskID     string `form:"task_id"` // optional cleaning task this report closes out
 			Notes      string `form:"notes"`
 		}
 		if err := c.ShouldBind(&input); err != nil {
@@ -41,6 +42,39 @@ func CreateHousekeeperReport(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
+		// Only report on properties you clean (or own)
+		scope, ok := requestScope(c)
+		if !ok {
+			return
+		}
+		if !scope.CanAccessProperty(propertyID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "you are not assigned to this property"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		// The task must be an open one on this property that the reporter can see
+		taskCol := cfg.MongoClient.Database(cfg.DBName).Collection("cleaning_tasks")
+		var taskID primitive.ObjectID
+		if input.TaskID != "" {
+			taskID, err = primitive.ObjectIDFromHex(input.TaskID)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+				return
+			}
+			var task models.CleaningTask
+			if err := taskCol.FindOne(ctx, scope.Tasks(bson.M{"_id": taskID, "property_id": propertyID})).Decode(&task); err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "task not found"})
+				return
+			}
+			if !models.CanTransitionTask(task.Status, models.TaskDone) {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "task is already " + task.Status})
+				return
+			}
+		}
+
 		// Handle damage images
 		var imageURLs []string
 		form, _ := c.MultipartForm()
@@ -78,14 +112,30 @@ func CreateHousekeeperReport(cfg *config.Config) gin.HandlerFunc {
 		}
 
 		col := cfg.MongoClient.Database(cfg.DBName).Collection("housekeeper_reports")
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
 
 		if _, err := col.InsertOne(ctx, report); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create report"})
 			return
 		}
 
+		// ✅ Close out the cleaning task
+		if !taskID.IsZero() {
+			now := time.Now()
+			_, err := taskCol.UpdateOne(ctx,
+				bson.M{"_id": taskID, "status": bson.M{"$in": models.OpenTaskStatuses}},
+				bson.M{"$set": bson.M{
+					"status":       models.TaskDone,
+					"report_id":    report.ID,
+					"completed_at": now,
+					"updated_at":   now,
+				}},
+			)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Report saved but could not close task"})
+				return
+			}
+		}
+
 		// ✅ Fetch related property to notify owner + housekeepers
 		propertyCol := cfg.MongoClient.Database(cfg.DBName).Collection("properties")
 		var property models.Property
@@ -133,7 +183,7 @@ func UpdateHousekeeperReport(cfg *config.Config) gin.HandlerFunc {
 		}
 
 		// ✅ Ownership enforcement
-		if role != "admin" && existing.HousekeeperID.Hex() != requesterID {
+		if role != models.RoleAdmin && existing.HousekeeperID.Hex() != requesterID {
 			c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
 			return
 		}
@@ -210,14 +260,36 @@ func UpdateHousekeeperReport(cfg *config.Config) gin.HandlerFunc {
 }
 
 
-// List all Housekeeper Reports with property details
+// List Housekeeper Reports with property details, paginated.
+// Filters: property_id, housekeeper_id.
 func ListHousekeeperReports(cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		params, err := utils.ParseListParams(c, map[string]string{
+			"created_at": "created_at",
+			"updated_at": "updated_at",
+		}, "-created_at")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		filter := bson.M{}
+		for _, name := range []string{"property_id", "housekeeper_id"} {
+			id, ok, err := utils.QueryObjectID(c, name)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if ok {
+				filter[name] = id
+			}
+		}
+
 		col := cfg.MongoClient.Database(cfg.DBName).Collection("housekeeper_reports")
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
-		pipeline := mongo.Pipeline{
+		lookup := mongo.Pipeline{
 			{{Key: "$lookup", Value: bson.M{
 				"from":         "properties",
 				"localField":   "property_id",
@@ -230,19 +302,19 @@ func ListHousekeeperReports(cfg *config.Config) gin.HandlerFunc {
 			}}},
 		}
 
-		cursor, err := col.Aggregate(ctx, pipeline)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch reports"})
+		scope, ok := requestScope(c)
+		if !ok {
 			return
 		}
 
-		var reports []bson.M
-		if err := cursor.All(ctx, &reports); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not decode reports"})
+		reports := []bson.M{}
+		page, err := utils.AggregatePage(ctx, col, scope.Reports(filter), params, lookup, &reports)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch reports"})
 			return
 		}
 
-		c.JSON(http.StatusOK, reports)
+		c.JSON(http.StatusOK, utils.PageResponse(reports, page))
 	}
 }
 
@@ -256,12 +328,17 @@ func GetHousekeeperReport(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
+		scope, ok := requestScope(c)
+		if !ok {
+			return
+		}
+
 		col := cfg.MongoClient.Database(cfg.DBName).Collection("housekeeper_reports")
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
 		pipeline := mongo.Pipeline{
-			{{Key: "$match", Value: bson.M{"_id": objID}}},
+			{{Key: "$match", Value: scope.Reports(bson.M{"_id": objID})}},
 			{{Key: "$lookup", Value: bson.M{
 				"from":         "properties",
 				"localField":   "property_id",
@@ -333,7 +410,7 @@ func DeleteHousekeeperReport(cfg *config.Config) gin.HandlerFunc {
 		}
 
 		// ✅ Enforce permissions
-		if role != "admin" && existing.HousekeeperID != userID {
+		if role != models.RoleAdmin && existing.HousekeeperID != userID {
 			c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
 			return
 		}
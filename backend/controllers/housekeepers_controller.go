@@ -0,0 +1,264 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/phillip/backend/config"
+	"github.com/phillip/backend/models"
+	"github.com/phillip/backend/utils"
+)
+
+const inviteTTL = 7 * 24 * time.Hour
+
+// InviteHousekeeper - owner invites a cleaner to a property. The cleaner is
+// emailed and only added to the property once they accept.
+func InviteHousekeeper(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		propertyID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid property id"})
+			return
+		}
+		housekeeperID, err := primitive.ObjectIDFromHex(c.Param("userId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+			return
+		}
+		inviterID, _ := primitive.ObjectIDFromHex(c.GetString("user_id"))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		property, ok := loadOwnedProperty(ctx, c, cfg, propertyID)
+		if !ok {
+			return
+		}
+
+		db := cfg.MongoClient.Database(cfg.DBName)
+
+		// ✅ Target must exist and be a cleaner
+		var housekeeper models.User
+		if err := db.Collection("users").FindOne(ctx, bson.M{"_id": housekeeperID}).Decode(&housekeeper); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		if housekeeper.Role != models.RoleCleaner {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "only users with the cleaner role can be housekeepers"})
+			return
+		}
+		if housekeeper.Pending() {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "user has not verified their email yet"})
+			return
+		}
+
+		if containsID(property.Housekeepers, housekeeperID) {
+			c.JSON(http.StatusConflict, gin.H{"error": "user is already a housekeeper on this property"})
+			return
+		}
+
+		invites := db.Collection("housekeeper_invites")
+		var existing models.HousekeeperInvite
+		err = invites.FindOne(ctx, bson.M{
+			"property_id":    propertyID,
+			"housekeeper_id": housekeeperID,
+			"status":         models.InvitePending,
+			"expires_at":     bson.M{"$gt": time.Now()},
+		}).Decode(&existing)
+		if err == nil {
+			c.JSON(http.StatusOK, existing)
+			return
+		}
+
+		invite := models.HousekeeperInvite{
+			ID:            primitive.NewObjectID(),
+			PropertyID:    propertyID,
+			InvitedBy:     inviterID,
+			HousekeeperID: housekeeperID,
+			Status:        models.InvitePending,
+			ExpiresAt:     time.Now().Add(inviteTTL),
+			CreatedAt:     time.Now(),
+			UpdatedAt:     time.Now(),
+		}
+		if _, err := invites.InsertOne(ctx, invite); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not create invite"})
+			return
+		}
+
+		// ✅ Let the housekeeper know
+		var inviter models.User
+		_ = db.Collection("users").FindOne(ctx, bson.M{"_id": inviterID}).Decode(&inviter)
+
+		body := utils.BuildHousekeeperInviteEmail(housekeeper.Name, property.Title, inviter.Name)
+		go utils.SendEmail(housekeeper.Email, "You've been invited to "+property.Title, body)
+		_ = utils.CreateNotification(cfg, []primitive.ObjectID{housekeeperID}, "Housekeeping Invite", "You have been invited to look after "+property.Title+".")
+
+		c.JSON(http.StatusAccepted, invite)
+	}
+}
+
+// RemoveHousekeeper - owner removes a cleaner from a property and cancels any pending invite
+func RemoveHousekeeper(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		propertyID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid property id"})
+			return
+		}
+		housekeeperID, err := primitive.ObjectIDFromHex(c.Param("userId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if _, ok := loadOwnedProperty(ctx, c, cfg, propertyID); !ok {
+			return
+		}
+
+		db := cfg.MongoClient.Database(cfg.DBName)
+		res, err := db.Collection("properties").UpdateOne(ctx,
+			bson.M{"_id": propertyID},
+			bson.M{
+				"$pull": bson.M{"housekeepers": housekeeperID},
+				"$set":  bson.M{"updated_at": time.Now()},
+			},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not remove housekeeper"})
+			return
+		}
+
+		cancelled, err := db.Collection("housekeeper_invites").UpdateMany(ctx,
+			bson.M{"property_id": propertyID, "housekeeper_id": housekeeperID, "status": models.InvitePending},
+			bson.M{"$set": bson.M{"status": models.InviteCancelled, "updated_at": time.Now()}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not cancel invites"})
+			return
+		}
+
+		if res.ModifiedCount == 0 && cancelled.ModifiedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user is not a housekeeper on this property"})
+			return
+		}
+
+		// Their open cleans go back to the owner to reassign
+		_, err = db.Collection("cleaning_tasks").UpdateMany(ctx,
+			bson.M{"property_id": propertyID, "housekeeper_id": housekeeperID, "status": bson.M{"$in": models.OpenTaskStatuses}},
+			bson.M{"$set": bson.M{"housekeeper_id": nil, "updated_at": time.Now()}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not unassign cleaning tasks"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "housekeeper removed", "id": housekeeperID.Hex()})
+	}
+}
+
+// ListHousekeeperInvites - pending invites addressed to the requester
+func ListHousekeeperInvites(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := primitive.ObjectIDFromHex(c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user id"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		cursor, err := cfg.MongoClient.Database(cfg.DBName).Collection("housekeeper_invites").Find(ctx, bson.M{
+			"housekeeper_id": userID,
+			"status":         models.InvitePending,
+			"expires_at":     bson.M{"$gt": time.Now()},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch invites"})
+			return
+		}
+		invites := []models.HousekeeperInvite{}
+		if err := cursor.All(ctx, &invites); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not decode invites"})
+			return
+		}
+
+		c.JSON(http.StatusOK, invites)
+	}
+}
+
+// AcceptHousekeeperInvite - invitee accepts and is added to the property
+func AcceptHousekeeperInvite(cfg *config.Config) gin.HandlerFunc {
+	return respondToInvite(cfg, models.InviteAccepted)
+}
+
+// DeclineHousekeeperInvite - invitee declines
+func DeclineHousekeeperInvite(cfg *config.Config) gin.HandlerFunc {
+	return respondToInvite(cfg, models.InviteDeclined)
+}
+
+func respondToInvite(cfg *config.Config, status string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := primitive.ObjectIDFromHex(c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user id"})
+			return
+		}
+		inviteID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid invite id"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		db := cfg.MongoClient.Database(cfg.DBName)
+
+		// ✅ Claim the pending invite so it can only be answered once
+		var invite models.HousekeeperInvite
+		err = db.Collection("housekeeper_invites").FindOneAndUpdate(ctx,
+			bson.M{
+				"_id":            inviteID,
+				"housekeeper_id": userID,
+				"status":         models.InvitePending,
+				"expires_at":     bson.M{"$gt": time.Now()},
+			},
+			bson.M{"$set": bson.M{"status": status, "updated_at": time.Now()}},
+		).Decode(&invite)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "invite not found or expired"})
+			return
+		}
+
+		var property models.Property
+		_ = db.Collection("properties").FindOne(ctx, bson.M{"_id": invite.PropertyID}).Decode(&property)
+
+		if status == models.InviteAccepted {
+			_, err = db.Collection("properties").UpdateOne(ctx,
+				bson.M{"_id": invite.PropertyID},
+				bson.M{
+					"$addToSet": bson.M{"housekeepers": userID},
+					"$set":      bson.M{"updated_at": time.Now()},
+				},
+			)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "could not add housekeeper"})
+				return
+			}
+			_ = utils.CreateNotification(cfg, []primitive.ObjectID{invite.InvitedBy}, "Housekeeper Joined", "Your housekeeper invite for "+property.Title+" was accepted.")
+		} else {
+			_ = utils.CreateNotification(cfg, []primitive.ObjectID{invite.InvitedBy}, "Housekeeper Declined", "Your housekeeper invite for "+property.Title+" was declined.")
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "invite " + status, "id": invite.ID.Hex()})
+	}
+}
@@ -0,0 +1,26 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/phillip/backend/config"
+	"github.com/phillip/backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKS - the public keys tokens are signed with, for services that verify
+// them without the API's secrets. Verifiers should cache it briefly and
+// refetch when they meet an unknown kid.
+func JWKS(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		set, err := utils.JWKS(cfg)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not load signing keys"})
+			return
+		}
+
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, set)
+	}
+}
@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/phillip/backend/config"
+	"github.com/phillip/backend/models"
+	"github.com/phillip/backend/utils"
+)
+
+// reencryptErrorField marks a record the re-encryption could not move, so
+// later batches skip it instead of fetching it again
+const reencryptErrorField = "reencrypt_error"
+
+// reencryptTarget is a collection holding values sealed with a data key
+type reencryptTarget struct {
+	collection string
+	fields     []string
+	keyOwner   func(bson.Raw) (primitive.ObjectID, string, error)
+}
+
+var reencryptTargets = []reencryptTarget{
+	{
+		collection: "credentials",
+		fields:     []string{"password_encrypted", "totp_encrypted"},
+		keyOwner: func(raw bson.Raw) (primitive.ObjectID, string, error) {
+			var cred models.Credential
+			if err := bson.Unmarshal(raw, &cred); err != nil {
+				return primitive.NilObjectID, "", err
+			}
+			owner, ownerType := cred.KeyOwner()
+			return owner, ownerType, nil
+		},
+	},
+	{
+		collection: "vault_items",
+		fields:     []string{"secret_encrypted", "notes_encrypted"},
+		keyOwner: func(raw bson.Raw) (primitive.ObjectID, string, error) {
+			var item models.VaultItem
+			if err := bson.Unmarshal(raw, &item); err != nil {
+				return primitive.NilObjectID, "", err
+			}
+			return item.UserID, models.DataKeyOwnerUser, nil
+		},
+	},
+}
+
+// ReencryptCredentials - admin: re-wrap data keys held under a retired master
+// key, then move stored secrets still in the shared-key formats (legacy CFB
+// or v2) onto their data key: credential passwords and TOTP seeds, and vault
+// item secrets and notes. ?batch= records at a time (default 500). Safe to run
+// while the app is serving: every record stays readable, and a record edited
+// mid-run is left to the edit. A record that can't be moved is marked with
+// reencrypt_error and skipped by later calls; ?retry_failed=true clears the
+// marks first. Call until "remaining" is 0.
+func ReencryptCredentials(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		batch := int64(500)
+		if b := c.Query("batch"); b != "" {
+			n, err := strconv.ParseInt(b, 10, 64)
+			if err != nil || n < 1 || n > 5000 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "batch must be between 1 and 5000"})
+				return
+			}
+			batch = n
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+
+		rewrapped, err := utils.RewrapDataKeys(ctx, cfg, batch)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not rewrap data keys", "details": err.Error()})
+			return
+		}
+
+		db := cfg.MongoClient.Database(cfg.DBName)
+		if c.Query("retry_failed") == "true" {
+			for _, t := range reencryptTargets {
+				_, err := db.Collection(t.collection).UpdateMany(ctx,
+					bson.M{reencryptErrorField: bson.M{"$exists": true}},
+					bson.M{"$unset": bson.M{reencryptErrorField: ""}},
+				)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "could not clear failures"})
+					return
+				}
+			}
+		}
+
+		migrated, skipped := 0, 0
+		failed := []string{}
+		var remaining, failedTotal int64
+		keys := utils.NewDataKeys(cfg)
+		budget := batch
+
+		for _, t := range reencryptTargets {
+			col := db.Collection(t.collection)
+			stale := staleSecretsFilter(t.fields)
+
+			if budget > 0 {
+				m, s, f, err := reencryptBatch(ctx, col, keys, t, stale, budget)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch " + t.collection})
+					return
+				}
+				migrated, skipped, failed = migrated+m, skipped+s, append(failed, f...)
+				budget -= int64(m + s + len(f))
+			}
+
+			if remaining >= 0 {
+				if n, err := col.CountDocuments(ctx, stale); err != nil {
+					remaining = -1
+				} else {
+					remaining += n
+				}
+			}
+			if n, err := col.CountDocuments(ctx, bson.M{reencryptErrorField: bson.M{"$exists": true}}); err == nil {
+				failedTotal += n
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"active_key_id": cfg.AESActiveKeyID,
+			"rewrapped":     rewrapped,
+			"migrated":      migrated,
+			"skipped":       skipped,
+			"failed":        failed,
+			"failed_total":  failedTotal,
+			"remaining":     remaining,
+		})
+	}
+}
+
+// staleSecretsFilter matches records with any of fields still outside the
+// data-key format, leaving out records already marked as failed
+func staleSecretsFilter(fields []string) bson.M {
+	or := bson.A{}
+	for _, f := range fields {
+		or = append(or, bson.M{f: bson.M{"$type": "string", "$ne": "", "$not": bson.M{"$regex": "^u1:"}}})
+	}
+	return bson.M{"$or": or, reencryptErrorField: bson.M{"$exists": false}}
+}
+
+// reencryptBatch moves up to limit stale records of one collection onto their
+// data key, in _id order. Records that fail are marked so the next batch moves on.
+func reencryptBatch(ctx context.Context, col *mongo.Collection, keys *utils.DataKeys, t reencryptTarget, stale bson.M, limit int64) (migrated, skipped int, failed []string, err error) {
+	cursor, err := col.Find(ctx, stale, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(limit))
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		raw := cursor.Current
+		id, ok := raw.Lookup("_id").ObjectIDOK()
+		if !ok {
+			continue
+		}
+
+		modified, err := reencryptRecord(ctx, col, keys, t, id, raw)
+		if err != nil {
+			failed = append(failed, id.Hex())
+			_, _ = col.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{reencryptErrorField: err.Error()}})
+			continue
+		}
+		if !modified {
+			skipped++
+			continue
+		}
+		migrated++
+	}
+	return migrated, skipped, failed, cursor.Err()
+}
+
+// reencryptRecord re-seals the record's stale fields. It reports false when
+// the record changed since it was read, which is left to that edit.
+func reencryptRecord(ctx context.Context, col *mongo.Collection, keys *utils.DataKeys, t reencryptTarget, id primitive.ObjectID, raw bson.Raw) (bool, error) {
+	owner, ownerType, err := t.keyOwner(raw)
+	if err != nil {
+		return false, err
+	}
+	key, err := keys.For(ctx, owner, ownerType)
+	if err != nil {
+		return false, err
+	}
+
+	// Only swap if the stored values are still the ones we decrypted
+	filter := bson.M{"_id": id}
+	set := bson.M{}
+	for _, f := range t.fields {
+		value, ok := raw.Lookup(f).StringValueOK()
+		if !ok || value == "" || strings.HasPrefix(value, "u1:") {
+			continue
+		}
+		plain, err := key.Open(value)
+		if err != nil {
+			return false, errors.New(f + ": " + err.Error())
+		}
+		sealed, err := key.Seal(plain)
+		if err != nil {
+			return false, err
+		}
+		filter[f] = value
+		set[f] = sealed
+	}
+	if len(set) == 0 {
+		return false, nil
+	}
+
+	res, err := col.UpdateOne(ctx, filter, bson.M{"$set": set})
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount > 0, nil
+}
@@ -7,20 +7,31 @@ import (
 
 	"github.com/phillip/backend/config"
 	"github.com/phillip/backend/models"
+	"github.com/phillip/backend/utils"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// ListNotifications - the requester's notifications, newest first. Filter: unread=true.
 func ListNotifications(cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userID, ok := c.Get("user_id")
-		if !ok {
+		// Notifications are stored against the ObjectID, not the hex string
+		userID, err := primitive.ObjectIDFromHex(c.GetString("user_id"))
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "not authorized"})
 			return
 		}
 
+		params, err := utils.ParseListParams(c, map[string]string{
+			"created_at": "created_at",
+		}, "-created_at")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
 		filter := bson.M{"user_id": userID}
 		if c.Query("unread") == "true" {
 			filter["read"] = false
@@ -30,19 +41,14 @@ func ListNotifications(cfg *config.Config) gin.HandlerFunc {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		cursor, err := col.Find(ctx, filter)
+		notifs := []models.Notification{}
+		page, err := utils.FindPage(ctx, col, filter, params, &notifs)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch notifications"})
 			return
 		}
 
-		var notifs []models.Notification
-		if err := cursor.All(ctx, &notifs); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "decode error"})
-			return
-		}
-
-		c.JSON(http.StatusOK, notifs)
+		c.JSON(http.StatusOK, utils.PageResponse(notifs, page))
 	}
 }
 
@@ -59,8 +65,11 @@ func MarkNotificationRead(cfg *config.Config) gin.HandlerFunc {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		_, err = col.UpdateOne(ctx,
-			bson.M{"_id": objID},
+		userID, _ := primitive.ObjectIDFromHex(c.GetString("user_id"))
+
+		// Users can only mark their own notifications
+		res, err := col.UpdateOne(ctx,
+			bson.M{"_id": objID, "user_id": userID},
 			bson.M{"$set": bson.M{"read": true}},
 		)
 
@@ -68,6 +77,10 @@ func MarkNotificationRead(cfg *config.Config) gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not update"})
 			return
 		}
+		if res.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "notification not found"})
+			return
+		}
 
 		c.JSON(http.StatusOK, gin.H{"message": "notification marked as read"})
 	}
@@ -2,9 +2,10 @@ package controllers
 
 import (
 	"context"
-	"fmt"
-	"math/rand"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -29,25 +30,24 @@ func RequestOTP(cfg *config.Config) gin.HandlerFunc {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
+		byIP := throttleCheck{otpIssuePerIP, c.ClientIP()}
+		if !checkThrottles(ctx, c, cfg, byIP) {
+			return
+		}
+
 		var user models.User
 		if err := users.FindOne(ctx, bson.M{"email": input.Email}).Decode(&user); err != nil {
+			hitThrottles(ctx, cfg, byIP)
 			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
 			return
 		}
 
-		otp := fmt.Sprintf("%06d", rand.Intn(1000000))
-		expiry := time.Now().Add(10 * time.Minute)
-
-		_, err := users.UpdateOne(ctx,
-			bson.M{"_id": user.ID},
-			bson.M{"$set": bson.M{"otp": otp, "otp_expiry": expiry}},
-		)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not save otp"})
+		otp, ok := issueOTP(ctx, c, cfg, user, models.ChannelEmail)
+		if !ok {
 			return
 		}
 
-		go utils.SendEmail(input.Email, "Your OTP Code", "Your OTP is: "+otp)
+		sendCode(cfg, user, models.ChannelEmail, user.Email, "Your OTP Code", otp)
 
 		c.JSON(http.StatusOK, gin.H{"message": "OTP sent to email"})
 	}
@@ -95,3 +95,188 @@ func RequestOTP(cfg *config.Config) gin.HandlerFunc {
 // 		})
 // 	}
 // }
+
+// =============================
+// Attempt limits
+// =============================
+
+const otpTTL = 10 * time.Minute
+
+var (
+	// Issuing codes: a few per account, more per IP (shared networks)
+	otpIssuePerAccount = utils.Throttle{Name: "otp-issue:user", Limit: 3, Window: 15 * time.Minute, Lockout: 15 * time.Minute, MaxLockout: time.Hour}
+	otpIssuePerIP      = utils.Throttle{Name: "otp-issue:ip", Limit: 20, Window: 15 * time.Minute, Lockout: 15 * time.Minute, MaxLockout: time.Hour}
+
+	// Failed codes: the lockout doubles with each further failure, so at most
+	// a handful of guesses fit in one code's lifetime
+	otpVerifyPerAccount = utils.Throttle{Name: "otp-verify:user", Limit: 5, Window: time.Hour, Lockout: time.Minute, MaxLockout: time.Hour}
+	otpVerifyPerIP      = utils.Throttle{Name: "otp-verify:ip", Limit: 20, Window: time.Hour, Lockout: 5 * time.Minute, MaxLockout: time.Hour}
+
+	// Failed authenticator or recovery codes
+	secondFactorPerAccount = utils.Throttle{Name: "2fa-verify:user", Limit: 5, Window: time.Hour, Lockout: time.Minute, MaxLockout: time.Hour}
+
+	// Failed passkey sign-ins, which name no account until they succeed
+	passkeyLoginPerIP = utils.Throttle{Name: "passkey-login:ip", Limit: 20, Window: time.Hour, Lockout: 5 * time.Minute, MaxLockout: time.Hour}
+)
+
+// throttleCheck pairs a throttle with the subject it counts (a user ID or IP)
+type throttleCheck struct {
+	throttle utils.Throttle
+	subject  string
+}
+
+// issueOTP creates a login code for user, stores its hash and the channel it
+// will be sent on and returns it for sending, or writes 429 when the account
+// or IP has asked for too many
+func issueOTP(ctx context.Context, c *gin.Context, cfg *config.Config, user models.User, channel string) (string, bool) {
+	limits := []throttleCheck{{otpIssuePerAccount, user.ID.Hex()}, {otpIssuePerIP, c.ClientIP()}}
+	if !checkThrottles(ctx, c, cfg, limits...) {
+		return "", false
+	}
+
+	otp, err := utils.NewOTP()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not create OTP"})
+		return "", false
+	}
+	_, err = cfg.MongoClient.Database(cfg.DBName).Collection("users").UpdateOne(ctx,
+		bson.M{"_id": user.ID},
+		bson.M{"$set": bson.M{
+			"otp":         utils.HashOTP(cfg, user.ID, otp),
+			"otp_expiry":  time.Now().Add(otpTTL),
+			"otp_channel": channel,
+		}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not save OTP"})
+		return "", false
+	}
+
+	hitThrottles(ctx, cfg, limits...)
+	return otp, true
+}
+
+// otpChannel picks where a login code goes: the user's phone channel when
+// they signed in with a verified phone, otherwise (or when that channel isn't
+// configured) their email. Pending accounts always use email, to verify it.
+func otpChannel(cfg *config.Config, user models.User, byPhone bool) string {
+	if !byPhone || user.Pending() || user.PhoneVerifiedAt == nil {
+		return models.ChannelEmail
+	}
+	channel := user.PhoneMessageChannel()
+	if _, err := utils.MessengerFor(cfg, channel); err != nil {
+		log.Printf("cannot send codes by %s, using email: %v", channel, err)
+		return models.ChannelEmail
+	}
+	return channel
+}
+
+// sendLoginCode delivers a login code on the channel otpChannel picked: to
+// the phone for SMS and WhatsApp, otherwise to the email address
+func sendLoginCode(cfg *config.Config, user models.User, channel, code string) {
+	to, subject := user.Email, "Your Login OTP"
+	if channel != models.ChannelEmail {
+		to = user.Phone
+	}
+	if user.Pending() {
+		subject = "Verify your account"
+	}
+	sendCode(cfg, user, channel, to, subject, code)
+}
+
+// sendCode delivers a one-time code in the background
+func sendCode(cfg *config.Config, user models.User, channel, to, subject, code string) {
+	sendMessage(cfg, channel, utils.Message{
+		To:      to,
+		Subject: subject,
+		Text:    "Your " + utils.TOTPIssuer + " code is " + code + ". It expires in " + strconv.Itoa(int(otpTTL.Minutes())) + " minutes.",
+		HTML:    utils.BuildOtpEmail(user.Name, code),
+	})
+}
+
+// sendMessage sends msg on channel in the background, logging failures
+func sendMessage(cfg *config.Config, channel string, msg utils.Message) {
+	messenger, err := utils.MessengerFor(cfg, channel)
+	if err != nil {
+		log.Printf("could not send message by %s: %v", channel, err)
+		return
+	}
+	go func() {
+		if err := messenger.Send(context.Background(), msg); err != nil {
+			log.Printf("could not send message by %s: %v", channel, err)
+		}
+	}()
+}
+
+// checkThrottles writes 429 with Retry-After if any subject is locked out.
+// If the counters can't be read it fails open, logging why.
+func checkThrottles(ctx context.Context, c *gin.Context, cfg *config.Config, checks ...throttleCheck) bool {
+	store, err := utils.Attempts(cfg)
+	if err != nil {
+		log.Printf("attempt store: %v", err)
+		return true
+	}
+
+	var wait time.Duration
+	for _, ch := range checks {
+		w, err := ch.throttle.Check(ctx, store, ch.subject)
+		if err != nil {
+			log.Printf("attempt store: could not check %s: %v", ch.throttle.Name, err)
+			continue
+		}
+		if w > wait {
+			wait = w
+		}
+	}
+	if wait > 0 {
+		writeTooManyAttempts(c, wait)
+		return false
+	}
+	return true
+}
+
+// hitThrottles counts an attempt against each subject and returns the
+// longest lockout that triggered
+func hitThrottles(ctx context.Context, cfg *config.Config, checks ...throttleCheck) time.Duration {
+	store, err := utils.Attempts(cfg)
+	if err != nil {
+		log.Printf("attempt store: %v", err)
+		return 0
+	}
+
+	var lockout time.Duration
+	for _, ch := range checks {
+		l, err := ch.throttle.Hit(ctx, store, ch.subject)
+		if err != nil {
+			log.Printf("attempt store: could not count %s: %v", ch.throttle.Name, err)
+			continue
+		}
+		if l > lockout {
+			lockout = l
+		}
+	}
+	return lockout
+}
+
+// resetThrottles clears the subjects' counters after a success
+func resetThrottles(ctx context.Context, cfg *config.Config, checks ...throttleCheck) {
+	store, err := utils.Attempts(cfg)
+	if err != nil {
+		log.Printf("attempt store: %v", err)
+		return
+	}
+	for _, ch := range checks {
+		if err := ch.throttle.Reset(ctx, store, ch.subject); err != nil {
+			log.Printf("attempt store: could not reset %s: %v", ch.throttle.Name, err)
+		}
+	}
+}
+
+func writeTooManyAttempts(c *gin.Context, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "too many attempts, try again later",
+		"retry_after": seconds,
+	})
+}
@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/phillip/backend/config"
+	"github.com/phillip/backend/models"
+	"github.com/phillip/backend/utils"
+)
+
+// waitForMessage polls the fake outbox, since codes are sent in the background
+func waitForMessage(t *testing.T, to string) utils.SentMessage {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if msg, ok := utils.FakeMessages().Last(to); ok {
+			return msg
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("nothing was sent to %s", to)
+	return utils.SentMessage{}
+}
+
+func TestLoginCodeDelivery(t *testing.T) {
+	cfg := &config.Config{MessageProvider: "fake"}
+	verified := time.Now().Add(-time.Hour)
+
+	cases := []struct {
+		name        string
+		user        models.User
+		byPhone     bool
+		wantChannel string
+		wantTo      string
+		wantSubject string
+	}{
+		{
+			name:        "verified phone gets sms",
+			user:        models.User{Status: models.UserActive, PhoneVerifiedAt: &verified},
+			byPhone:     true,
+			wantChannel: models.ChannelSMS,
+			wantTo:      "phone",
+		},
+		{
+			name:        "verified phone on whatsapp",
+			user:        models.User{Status: models.UserActive, PhoneVerifiedAt: &verified, PhoneChannel: models.ChannelWhatsApp},
+			byPhone:     true,
+			wantChannel: models.ChannelWhatsApp,
+			wantTo:      "phone",
+		},
+		{
+			name:        "unverified phone falls back to email",
+			user:        models.User{Status: models.UserActive},
+			byPhone:     true,
+			wantChannel: models.ChannelEmail,
+			wantTo:      "email",
+			wantSubject: "Your Login OTP",
+		},
+		{
+			name:        "pending account verifies its email",
+			user:        models.User{Status: models.UserPending, PhoneVerifiedAt: &verified},
+			byPhone:     true,
+			wantChannel: models.ChannelEmail,
+			wantTo:      "email",
+			wantSubject: "Verify your account",
+		},
+		{
+			name:        "email login stays on email",
+			user:        models.User{Status: models.UserActive, PhoneVerifiedAt: &verified, PhoneChannel: models.ChannelWhatsApp},
+			byPhone:     false,
+			wantChannel: models.ChannelEmail,
+			wantTo:      "email",
+			wantSubject: "Your Login OTP",
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			user := tc.user
+			user.ID = primitive.NewObjectID()
+			user.Name = "Wanjiru"
+			user.Email = user.ID.Hex() + "@example.com"
+			user.Phone = fmt.Sprintf("+25470000000%d", i)
+
+			channel := otpChannel(cfg, user, tc.byPhone)
+			if channel != tc.wantChannel {
+				t.Fatalf("otpChannel = %s, want %s", channel, tc.wantChannel)
+			}
+			sendLoginCode(cfg, user, channel, "482913")
+
+			to := user.Email
+			if tc.wantTo == "phone" {
+				to = user.Phone
+			}
+			msg := waitForMessage(t, to)
+			if msg.Channel != tc.wantChannel {
+				t.Errorf("sent by %s, want %s", msg.Channel, tc.wantChannel)
+			}
+			if !strings.Contains(msg.Text, "482913") {
+				t.Errorf("code missing from message text %q", msg.Text)
+			}
+			if tc.wantSubject != "" && msg.Subject != tc.wantSubject {
+				t.Errorf("subject = %q, want %q", msg.Subject, tc.wantSubject)
+			}
+		})
+	}
+}
+
+func TestLoginCodeFallsBackWhenChannelUnconfigured(t *testing.T) {
+	// Twilio without credentials can't send SMS, so the code goes by email
+	cfg := &config.Config{MessageProvider: "twilio"}
+	verified := time.Now()
+	user := models.User{Status: models.UserActive, PhoneVerifiedAt: &verified}
+
+	if channel := otpChannel(cfg, user, true); channel != models.ChannelEmail {
+		t.Fatalf("otpChannel = %s, want email", channel)
+	}
+}
@@ -0,0 +1,449 @@
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/phillip/backend/config"
+	"github.com/phillip/backend/models"
+	"github.com/phillip/backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const passkeyChallengeTTL = 5 * time.Minute
+
+// passkeyCredential is a PublicKeyCredential as browsers serialise it with
+// toJSON(): binary fields are base64url
+type passkeyCredential struct {
+	RawID    string `json:"rawId" binding:"required"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string   `json:"clientDataJSON" binding:"required"`
+		AttestationObject string   `json:"attestationObject"` // registration
+		Transports        []string `json:"transports"`        // registration
+		AuthenticatorData string   `json:"authenticatorData"` // login
+		Signature         string   `json:"signature"`         // login
+		UserHandle        string   `json:"userHandle"`        // login
+	} `json:"response" binding:"required"`
+}
+
+// BeginPasskeyRegistration - options for navigator.credentials.create().
+// When the user has two-factor enabled, only a session that passed it may
+// add a passkey, since a passkey signs in on its own.
+func BeginPasskeyRegistration(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		user, ok := loadRequester(ctx, c, cfg)
+		if !ok {
+			return
+		}
+		if user.TwoFactorEnabled() && !c.GetBool("mfa") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "two-factor authentication required", "mfa_required": true})
+			return
+		}
+
+		passkeys, err := userPasskeys(ctx, cfg, user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not load passkeys"})
+			return
+		}
+
+		challenge, ok := createPasskeyChallenge(ctx, c, cfg, models.PasskeyRegister, &user.ID)
+		if !ok {
+			return
+		}
+
+		params := []gin.H{}
+		for _, alg := range utils.PasskeyAlgorithms {
+			params = append(params, gin.H{"type": "public-key", "alg": alg})
+		}
+		rp := utils.WebAuthnRP(cfg)
+
+		c.JSON(http.StatusOK, gin.H{"publicKey": gin.H{
+			"challenge":        challenge,
+			"rp":               gin.H{"id": rp.ID, "name": rp.Name},
+			"user":             gin.H{"id": base64.RawURLEncoding.EncodeToString(user.ID[:]), "name": user.Email, "displayName": user.Name},
+			"pubKeyCredParams": params,
+			"timeout":          passkeyChallengeTTL.Milliseconds(),
+			"attestation":      "none",
+			"authenticatorSelection": gin.H{
+				"residentKey":        "required",
+				"requireResidentKey": true,
+				"userVerification":   "required",
+			},
+			"excludeCredentials": passkeyDescriptors(passkeys),
+		}})
+	}
+}
+
+// FinishPasskeyRegistration - verifies the authenticator's response and
+// stores the new passkey
+func FinishPasskeyRegistration(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Name       string            `json:"name"`
+			Credential passkeyCredential `json:"credential" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		clientData, err1 := decodeBase64URL(input.Credential.Response.ClientDataJSON)
+		attestation, err2 := decodeBase64URL(input.Credential.Response.AttestationObject)
+		if err1 != nil || err2 != nil || len(attestation) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "malformed credential"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		user, ok := loadRequester(ctx, c, cfg)
+		if !ok {
+			return
+		}
+
+		challenge, ok := takePasskeyChallenge(ctx, cfg, clientData, models.PasskeyRegister)
+		if !ok || challenge.UserID == nil || *challenge.UserID != user.ID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "registration expired or invalid, start again"})
+			return
+		}
+
+		cred, err := utils.VerifyPasskeyRegistration(utils.WebAuthnRP(cfg), challengeOf(clientData), clientData, attestation)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		name := strings.TrimSpace(input.Name)
+		if name == "" {
+			name = "Passkey"
+		}
+		passkey := models.Passkey{
+			ID:             primitive.NewObjectID(),
+			UserID:         user.ID,
+			Name:           name,
+			CredentialID:   base64.RawURLEncoding.EncodeToString(cred.ID),
+			PublicKey:      cred.PublicKey,
+			Algorithm:      cred.Algorithm,
+			SignCount:      cred.SignCount,
+			Transports:     input.Credential.Response.Transports,
+			BackupEligible: cred.BackupEligible,
+			CreatedAt:      time.Now(),
+		}
+		if _, err := cfg.MongoClient.Database(cfg.DBName).Collection("passkeys").InsertOne(ctx, passkey); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				c.JSON(http.StatusConflict, gin.H{"error": "passkey already registered"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not save passkey"})
+			return
+		}
+
+		// Tell the owner, in case this wasn't them
+		sendMessage(cfg, models.ChannelEmail, utils.Message{
+			To:      user.Email,
+			Subject: "A passkey was added to your account",
+			Text:    "The passkey \"" + passkey.Name + "\" can now sign in to your account. If you didn't add it, remove it and sign out your other sessions.",
+		})
+
+		c.JSON(http.StatusCreated, passkey)
+	}
+}
+
+// ListPasskeys - the requester's passkeys
+func ListPasskeys(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := primitive.ObjectIDFromHex(c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "not authorized"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		passkeys, err := userPasskeys(ctx, cfg, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not load passkeys"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": passkeys})
+	}
+}
+
+// DeletePasskey - removes one of the requester's passkeys
+func DeletePasskey(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		userID, err := primitive.ObjectIDFromHex(c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "not authorized"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		// Users can only remove their own passkeys
+		res, err := cfg.MongoClient.Database(cfg.DBName).Collection("passkeys").
+			DeleteOne(ctx, bson.M{"_id": id, "user_id": userID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not delete passkey"})
+			return
+		}
+		if res.DeletedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "passkey not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "passkey deleted"})
+	}
+}
+
+// BeginPasskeyLogin - options for navigator.credentials.get(). With an email
+// the user's passkeys are listed; without one the browser offers whichever
+// passkeys it holds for the site. Email OTP remains available as a fallback.
+func BeginPasskeyLogin(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Email string `json:"email" binding:"omitempty,email"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) { // the body is optional
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if !checkThrottles(ctx, c, cfg, throttleCheck{passkeyLoginPerIP, c.ClientIP()}) {
+			return
+		}
+
+		// Unknown emails, and accounts without passkeys, get the same response
+		// as no email, so this can't be used to find out who has an account
+		var userID *primitive.ObjectID
+		passkeys := []models.Passkey{}
+		if input.Email != "" {
+			var user models.User
+			err := cfg.MongoClient.Database(cfg.DBName).Collection("users").
+				FindOne(ctx, bson.M{"email": input.Email}).Decode(&user)
+			if err == nil {
+				if passkeys, err = userPasskeys(ctx, cfg, user.ID); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "could not load passkeys"})
+					return
+				}
+				if len(passkeys) > 0 {
+					userID = &user.ID
+				}
+			}
+		}
+
+		challenge, ok := createPasskeyChallenge(ctx, c, cfg, models.PasskeyLogin, userID)
+		if !ok {
+			return
+		}
+
+		opts := gin.H{
+			"challenge":        challenge,
+			"rpId":             utils.WebAuthnRP(cfg).ID,
+			"timeout":          passkeyChallengeTTL.Milliseconds(),
+			"userVerification": "required",
+		}
+		if userID != nil {
+			opts["allowCredentials"] = passkeyDescriptors(passkeys)
+		}
+		c.JSON(http.StatusOK, gin.H{"publicKey": opts})
+	}
+}
+
+// FinishPasskeyLogin - verifies the passkey's signature and signs the user
+// in. The passkey verified the user itself (PIN or biometric), so the session
+// counts as having passed two-factor.
+func FinishPasskeyLogin(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Credential passkeyCredential `json:"credential" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		resp := input.Credential.Response
+		rawID, err1 := decodeBase64URL(input.Credential.RawID)
+		clientData, err2 := decodeBase64URL(resp.ClientDataJSON)
+		authData, err3 := decodeBase64URL(resp.AuthenticatorData)
+		signature, err4 := decodeBase64URL(resp.Signature)
+		userHandle, err5 := decodeBase64URL(resp.UserHandle)
+		if err1 != nil || err2 != nil || err3 != nil || err4 != nil || err5 != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "malformed credential"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		byIP := throttleCheck{passkeyLoginPerIP, c.ClientIP()}
+		if !checkThrottles(ctx, c, cfg, byIP) {
+			return
+		}
+		fail := func() {
+			hitThrottles(ctx, cfg, byIP)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "passkey sign-in failed"})
+		}
+
+		// Single use, whatever the outcome
+		challenge, ok := takePasskeyChallenge(ctx, cfg, clientData, models.PasskeyLogin)
+		if !ok {
+			fail()
+			return
+		}
+
+		db := cfg.MongoClient.Database(cfg.DBName)
+		passkeys := db.Collection("passkeys")
+
+		var passkey models.Passkey
+		if err := passkeys.FindOne(ctx, bson.M{"credential_id": base64.RawURLEncoding.EncodeToString(rawID)}).Decode(&passkey); err != nil {
+			fail()
+			return
+		}
+		if challenge.UserID != nil && *challenge.UserID != passkey.UserID {
+			fail()
+			return
+		}
+		if len(userHandle) > 0 && string(userHandle) != string(passkey.UserID[:]) {
+			fail()
+			return
+		}
+
+		count, err := utils.VerifyPasskeyAssertion(utils.WebAuthnRP(cfg), challengeOf(clientData),
+			passkey.PublicKey, passkey.SignCount, clientData, authData, signature)
+		if err != nil {
+			fail()
+			return
+		}
+
+		// Conditional on the counter we checked against, so a cloned
+		// authenticator racing the real one can't both get in
+		now := time.Now()
+		res, err := passkeys.UpdateOne(ctx,
+			bson.M{"_id": passkey.ID, "sign_count": passkey.SignCount},
+			bson.M{"$set": bson.M{"sign_count": count, "last_used_at": now}},
+		)
+		if err != nil || res.ModifiedCount == 0 {
+			fail()
+			return
+		}
+
+		var user models.User
+		if err := db.Collection("users").FindOne(ctx, bson.M{"_id": passkey.UserID}).Decode(&user); err != nil || user.Pending() {
+			fail()
+			return
+		}
+
+		issueSession(ctx, c, cfg, user, true)
+	}
+}
+
+// =============================
+// Helpers
+// =============================
+
+// createPasskeyChallenge stores a challenge for a ceremony and returns it
+func createPasskeyChallenge(ctx context.Context, c *gin.Context, cfg *config.Config, ceremony string, userID *primitive.ObjectID) (string, bool) {
+	challenge, err := utils.NewPasskeyChallenge()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not start passkey ceremony"})
+		return "", false
+	}
+
+	now := time.Now()
+	_, err = cfg.MongoClient.Database(cfg.DBName).Collection("passkey_challenges").InsertOne(ctx, models.PasskeyChallenge{
+		ID:            primitive.NewObjectID(),
+		ChallengeHash: utils.HashToken(challenge),
+		Ceremony:      ceremony,
+		UserID:        userID,
+		ExpiresAt:     now.Add(passkeyChallengeTTL),
+		CreatedAt:     now,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not start passkey ceremony"})
+		return "", false
+	}
+	return challenge, true
+}
+
+// takePasskeyChallenge finds and deletes the unexpired challenge that
+// clientDataJSON answers
+func takePasskeyChallenge(ctx context.Context, cfg *config.Config, clientData []byte, ceremony string) (models.PasskeyChallenge, bool) {
+	var challenge models.PasskeyChallenge
+	value := challengeOf(clientData)
+	if value == "" {
+		return challenge, false
+	}
+	err := cfg.MongoClient.Database(cfg.DBName).Collection("passkey_challenges").FindOneAndDelete(ctx, bson.M{
+		"challenge_hash": utils.HashToken(value),
+		"ceremony":       ceremony,
+		"expires_at":     bson.M{"$gt": time.Now()},
+	}).Decode(&challenge)
+	return challenge, err == nil
+}
+
+// challengeOf is the challenge clientDataJSON answers, or "" if unreadable
+func challengeOf(clientData []byte) string {
+	challenge, err := utils.PasskeyChallengeOf(clientData)
+	if err != nil {
+		return ""
+	}
+	return challenge
+}
+
+func userPasskeys(ctx context.Context, cfg *config.Config, userID primitive.ObjectID) ([]models.Passkey, error) {
+	cursor, err := cfg.MongoClient.Database(cfg.DBName).Collection("passkeys").Find(ctx,
+		bson.M{"user_id": userID},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	passkeys := []models.Passkey{}
+	err = cursor.All(ctx, &passkeys)
+	return passkeys, err
+}
+
+// passkeyDescriptors lists passkeys as PublicKeyCredentialDescriptors
+func passkeyDescriptors(passkeys []models.Passkey) []gin.H {
+	out := []gin.H{}
+	for _, p := range passkeys {
+		d := gin.H{"type": "public-key", "id": p.CredentialID}
+		if len(p.Transports) > 0 {
+			d["transports"] = p.Transports
+		}
+		out = append(out, d)
+	}
+	return out
+}
+
+// decodeBase64URL accepts base64url with or without padding
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/phillip/backend/config"
+	"github.com/phillip/backend/models"
+	"github.com/phillip/backend/utils"
+)
+
+// SetRatePlan - replace a property's rate plan (amounts in minor units)
+func SetRatePlan(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid property ID"})
+			return
+		}
+
+		var plan models.RatePlan
+		if err := c.ShouldBindJSON(&plan); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		plan.Currency = strings.ToUpper(plan.Currency)
+		if err := utils.ValidateRatePlan(plan); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if _, ok := loadOwnedProperty(ctx, c, cfg, objID); !ok {
+			return
+		}
+
+		// Keep the legacy price (major units) in step for older clients
+		_, err = cfg.MongoClient.Database(cfg.DBName).Collection("properties").UpdateOne(ctx,
+			bson.M{"_id": objID},
+			bson.M{"$set": bson.M{
+				"rate_plan":  plan,
+				"price":      float64(plan.BaseNightly) / 100,
+				"updated_at": time.Now(),
+			}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not save rate plan"})
+			return
+		}
+
+		c.JSON(http.StatusOK, plan)
+	}
+}
+
+// QuoteProperty - price a stay without booking it
+func QuoteProperty(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid property ID"})
+			return
+		}
+
+		var input struct {
+			StartDate time.Time `json:"start_date" binding:"required"`
+			EndDate   time.Time `json:"end_date" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var property models.Property
+		if err := cfg.MongoClient.Database(cfg.DBName).Collection("properties").
+			FindOne(ctx, bson.M{"_id": objID}).Decode(&property); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Property not found"})
+			return
+		}
+
+		quote, err := utils.QuoteStay(property.EffectiveRatePlan(cfg.DefaultCurrency), input.StartDate, input.EndDate)
+		if err != nil {
+			respondQuoteError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, quote)
+	}
+}
+
+func respondQuoteError(c *gin.Context, err error) {
+	var minStay utils.MinStayError
+	if errors.As(err, &minStay) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "min_stay": minStay.MinStay})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
@@ -3,6 +3,7 @@ package controllers
 import (
 	"context"
 	"net/http"
+	"regexp"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -31,7 +32,6 @@ func CreateProperty(cfg *config.Config) gin.HandlerFunc {
 			Description string  `form:"description"`
 			Location    string  `form:"location" binding:"required"`
 			Price       float64 `form:"price" binding:"required"`
-			Available   *bool   `form:"available"` // pointer so it's optional
 		}
 
 		if err := c.ShouldBind(&input); err != nil {
@@ -79,7 +79,6 @@ func CreateProperty(cfg *config.Config) gin.HandlerFunc {
 			Location:    input.Location,
 			Price:       input.Price,
 			Images:      imageURLs,
-			Available:   input.Available == nil || *input.Available,
 			CreatedAt:   time.Now(),
 			UpdatedAt:   time.Now(),
 		}
@@ -98,26 +97,84 @@ func CreateProperty(cfg *config.Config) gin.HandlerFunc {
 }
 
 
-// List all Properties
+// List Properties, paginated. Filters: location, min_price, max_price, and
+// available_from/available_to for properties free on those nights.
 func ListProperties(cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		params, err := utils.ParseListParams(c, map[string]string{
+			"created_at": "created_at",
+			"price":      "price",
+			"title":      "title",
+		}, "-created_at")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
 		col := cfg.MongoClient.Database(cfg.DBName).Collection("properties")
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		cursor, err := col.Find(ctx, bson.M{})
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch properties"})
+		filter := bson.M{}
+		if loc := c.Query("location"); loc != "" {
+			filter["location"] = bson.M{"$regex": regexp.QuoteMeta(loc), "$options": "i"}
+		}
+
+		price := bson.M{}
+		if minPrice, ok, err := utils.QueryFloat(c, "min_price"); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		} else if ok {
+			price["$gte"] = minPrice
+		}
+		if maxPrice, ok, err := utils.QueryFloat(c, "max_price"); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		} else if ok {
+			price["$lte"] = maxPrice
+		}
+		if len(price) > 0 {
+			filter["price"] = price
+		}
+
+		if c.Query("available_from") != "" || c.Query("available_to") != "" {
+			from, to, err := parseDayRange(c.Query("available_from"), c.Query("available_to"))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			// Properties with a booking on any of those nights
+			booked, err := cfg.MongoClient.Database(cfg.DBName).Collection("bookings").Distinct(ctx, "property_id", bson.M{
+				"status":     bson.M{"$in": models.BlockingBookingStatuses},
+				"start_date": bson.M{"$lt": to},
+				"end_date":   bson.M{"$gt": from},
+			})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check availability"})
+				return
+			}
+
+			filter["_id"] = bson.M{"$nin": booked}
+			filter["blackouts"] = bson.M{"$not": bson.M{"$elemMatch": bson.M{
+				"start_date": bson.M{"$lt": to},
+				"end_date":   bson.M{"$gt": from},
+			}}}
+		}
+
+		scope, ok := requestScope(c)
+		if !ok {
 			return
 		}
 
-		var properties []models.Property
-		if err := cursor.All(ctx, &properties); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not decode properties"})
+		properties := []models.Property{}
+		page, err := utils.FindPage(ctx, col, scope.Properties(filter), params, &properties)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch properties"})
 			return
 		}
 
-		c.JSON(http.StatusOK, properties)
+		c.JSON(http.StatusOK, utils.PageResponse(properties, page))
 	}
 }
 
@@ -131,12 +188,17 @@ func GetProperty(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
+		scope, ok := requestScope(c)
+		if !ok {
+			return
+		}
+
 		col := cfg.MongoClient.Database(cfg.DBName).Collection("properties")
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
 		var property models.Property
-		if err := col.FindOne(ctx, bson.M{"_id": objID}).Decode(&property); err != nil {
+		if err := col.FindOne(ctx, scope.Properties(bson.M{"_id": objID})).Decode(&property); err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Property not found"})
 			return
 		}
@@ -176,7 +238,7 @@ func UpdateProperty(cfg *config.Config) gin.HandlerFunc {
 		}
 
 		// ✅ Check permission
-		if role != "admin" && existing.UserID.Hex() != requesterID {
+		if role != models.RoleAdmin && existing.UserID.Hex() != requesterID {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
 			return
 		}
@@ -187,7 +249,6 @@ func UpdateProperty(cfg *config.Config) gin.HandlerFunc {
 			Description string   `form:"description"`
 			Location    string   `form:"location"`
 			Price       float64  `form:"price"`
-			Available   *bool    `form:"available"`
 			Images      []string `form:"images"` // existing image URLs to keep
 		}
 
@@ -211,9 +272,6 @@ func UpdateProperty(cfg *config.Config) gin.HandlerFunc {
 		if input.Price > 0 {
 			update["price"] = input.Price
 		}
-		if input.Available != nil {
-			update["available"] = *input.Available
-		}
 
 		// ✅ Handle new image uploads (multipart form)
 		newImageURLs := []string{}
@@ -299,7 +357,7 @@ func DeleteProperty(cfg *config.Config) gin.HandlerFunc {
 		}
 
 		// ✅ Enforce permissions
-		if role != "admin" && existing.UserID != userID {
+		if role != models.RoleAdmin && existing.UserID != userID {
 			c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
 			return
 		}
@@ -315,6 +373,11 @@ func DeleteProperty(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
+		// ✅ Its shared credentials and their key go with it
+		db := cfg.MongoClient.Database(cfg.DBName)
+		_, _ = db.Collection("credentials").DeleteMany(ctx, bson.M{"property_id": objID})
+		_, _ = db.Collection("data_keys").DeleteOne(ctx, bson.M{"_id": objID})
+
 		c.JSON(http.StatusOK, gin.H{
 			"message": "property deleted",
 			"id":      objID.Hex(),
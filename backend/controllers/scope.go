@@ -0,0 +1,20 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/phillip/backend/utils"
+)
+
+// requestScope returns the tenant scope loaded by middleware.TenantScope,
+// writing a 500 if the route was wired without it
+func requestScope(c *gin.Context) (*utils.Scope, bool) {
+	scope, err := utils.ScopeFrom(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return scope, true
+}
@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/phillip/backend/config"
+	"github.com/phillip/backend/models"
+	"github.com/phillip/backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// sessionView is a session as listed to its owner
+type sessionView struct {
+	models.Session
+	Current bool `json:"current"`
+}
+
+// ListSessions - the requester's signed-in devices, most recently used first
+func ListSessions(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := primitive.ObjectIDFromHex(c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "not authorized"})
+			return
+		}
+
+		col := cfg.MongoClient.Database(cfg.DBName).Collection("sessions")
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		cursor, err := col.Find(ctx,
+			bson.M{"user_id": userID, "revoked_at": nil, "expires_at": bson.M{"$gt": time.Now()}},
+			options.Find().SetSort(bson.D{{Key: "last_used_at", Value: -1}}),
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch sessions"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		var sessions []models.Session
+		if err := cursor.All(ctx, &sessions); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch sessions"})
+			return
+		}
+
+		current := c.GetString("sid")
+		views := make([]sessionView, 0, len(sessions))
+		for _, s := range sessions {
+			views = append(views, sessionView{Session: s, Current: s.ID.Hex() == current})
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": views})
+	}
+}
+
+// RevokeSession - signs one of the requester's devices out
+func RevokeSession(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		userID, err := primitive.ObjectIDFromHex(c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "not authorized"})
+			return
+		}
+
+		col := cfg.MongoClient.Database(cfg.DBName).Collection("sessions")
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		// Users can only revoke their own sessions
+		count, err := col.CountDocuments(ctx, bson.M{"_id": sessionID, "user_id": userID, "revoked_at": nil})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not revoke session"})
+			return
+		}
+		if count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+			return
+		}
+
+		if err := revokeSession(ctx, cfg, sessionID, models.RevokedByUser); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not revoke session"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "session revoked"})
+	}
+}
+
+// Logout - ends the current session and its access token straight away
+func Logout(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if jti := c.GetString("jti"); jti != "" {
+			expiresAt := time.Now().Add(utils.AccessTokenTTL)
+			if exp, ok := c.Get("token_exp"); ok {
+				expiresAt = exp.(time.Time)
+			}
+			if err := utils.RevokeAccessToken(ctx, cfg, jti, expiresAt); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "could not log out"})
+				return
+			}
+		}
+
+		if sid, err := primitive.ObjectIDFromHex(c.GetString("sid")); err == nil {
+			if err := revokeSession(ctx, cfg, sid, models.RevokedLogout); err != nil {
+				log.Printf("could not revoke session %s: %v", sid.Hex(), err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "could not log out"})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "logged out"})
+	}
+}
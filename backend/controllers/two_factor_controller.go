@@ -0,0 +1,528 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/phillip/backend/config"
+	"github.com/phillip/backend/models"
+	"github.com/phillip/backend/utils"
+)
+
+const (
+	mfaChallengeTTL         = 5 * time.Minute
+	mfaChallengeMaxAttempts = 5
+	totpSkew                = 1 // accept the previous and next 30s step for clock drift
+)
+
+var errSecondFactorFailed = errors.New("invalid authentication code")
+
+// TwoFactorStatus - whether the requester has an authenticator enrolled, and
+// whether their role requires one
+func TwoFactorStatus(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		user, ok := loadRequester(ctx, c, cfg)
+		if !ok {
+			return
+		}
+		required, err := utils.MFARequired(ctx, cfg, user.Role)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not load MFA policy"})
+			return
+		}
+
+		status := gin.H{
+			"enabled":                  user.TwoFactorEnabled(),
+			"required":                 required,
+			"pending":                  user.TOTP != nil && user.TOTP.Pending != "",
+			"session_verified":         c.GetBool("mfa"),
+			"recovery_codes_remaining": 0,
+		}
+		if user.TwoFactorEnabled() {
+			status["enabled_at"] = user.TOTP.EnabledAt
+			status["recovery_codes_remaining"] = len(user.TOTP.RecoveryCodes)
+		}
+		c.JSON(http.StatusOK, status)
+	}
+}
+
+// EnrollTwoFactor - start enrolment: store a new secret as pending and return
+// it as an otpauth:// URI (render it as a QR code) and as base32 for manual
+// entry. Nothing changes at login until ConfirmTwoFactor.
+func EnrollTwoFactor(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		user, ok := loadRequester(ctx, c, cfg)
+		if !ok {
+			return
+		}
+		if user.TwoFactorEnabled() {
+			c.JSON(http.StatusConflict, gin.H{"error": "two-factor authentication is already enabled"})
+			return
+		}
+
+		totp, err := utils.NewTOTP(utils.TOTPIssuer, user.Email)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not create secret"})
+			return
+		}
+		key, err := utils.UserDataKey(ctx, cfg, user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not load encryption key"})
+			return
+		}
+		sealed, err := key.Seal(totp.URI())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "encryption failed"})
+			return
+		}
+
+		_, err = cfg.MongoClient.Database(cfg.DBName).Collection("users").UpdateOne(ctx,
+			bson.M{"_id": user.ID},
+			bson.M{"$set": bson.M{"totp.pending": sealed, "updated_at": time.Now()}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not save secret"})
+			return
+		}
+
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, gin.H{
+			"otpauth_uri": totp.URI(),
+			"secret":      totp.Base32Secret(),
+			"digits":      totp.Digits,
+			"period":      totp.Period,
+		})
+	}
+}
+
+// ConfirmTwoFactor - finish enrolment with a code from the app. Returns the
+// recovery codes (shown only now) and fresh tokens for a verified session.
+func ConfirmTwoFactor(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Code string `json:"code" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		user, ok := loadRequester(ctx, c, cfg)
+		if !ok {
+			return
+		}
+		if user.TwoFactorEnabled() {
+			c.JSON(http.StatusConflict, gin.H{"error": "two-factor authentication is already enabled"})
+			return
+		}
+		if user.TOTP == nil || user.TOTP.Pending == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "start enrolment first"})
+			return
+		}
+
+		limit := throttleCheck{secondFactorPerAccount, user.ID.Hex()}
+		if !checkThrottles(ctx, c, cfg, limit) {
+			return
+		}
+		totp, err := openUserTOTP(ctx, cfg, user.ID, user.TOTP.Pending)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not read pending secret"})
+			return
+		}
+		step, ok := totp.Match(input.Code, time.Now(), totpSkew)
+		if !ok {
+			hitThrottles(ctx, cfg, limit)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": errSecondFactorFailed.Error()})
+			return
+		}
+		resetThrottles(ctx, cfg, limit)
+
+		codes, hashes, err := utils.NewRecoveryCodes()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not create recovery codes"})
+			return
+		}
+
+		now := time.Now()
+		res, err := cfg.MongoClient.Database(cfg.DBName).Collection("users").UpdateOne(ctx,
+			bson.M{"_id": user.ID, "totp.pending": user.TOTP.Pending},
+			bson.M{"$set": bson.M{
+				"totp": models.TwoFactor{
+					Secret:        user.TOTP.Pending,
+					EnabledAt:     &now,
+					LastStep:      step,
+					RecoveryCodes: hashes,
+				},
+				"updated_at": now,
+			}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not enable two-factor authentication"})
+			return
+		}
+		if res.ModifiedCount == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "enrolment changed, start again"})
+			return
+		}
+
+		// Swap the current session for one that has passed two-factor
+		accessToken, refreshToken, err := startSession(ctx, c, cfg, user, true)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not create tokens"})
+			return
+		}
+		if sid, err := primitive.ObjectIDFromHex(c.GetString("sid")); err == nil {
+			if err := revokeSession(ctx, cfg, sid, models.RevokedMFAUpgrade); err != nil {
+				log.Printf("could not revoke session %s: %v", sid.Hex(), err)
+			}
+		}
+
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, gin.H{
+			"message":        "two-factor authentication enabled",
+			"recovery_codes": codes,
+			"access_token":   accessToken,
+			"refresh_token":  refreshToken,
+		})
+	}
+}
+
+// RegenerateRecoveryCodes - replace all recovery codes; needs a current code
+func RegenerateRecoveryCodes(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Code string `json:"code" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		user, ok := loadRequester(ctx, c, cfg)
+		if !ok {
+			return
+		}
+		if !user.TwoFactorEnabled() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "two-factor authentication is not enabled"})
+			return
+		}
+		if !checkSecondFactor(ctx, c, cfg, user, input.Code, "") {
+			return
+		}
+
+		codes, hashes, err := utils.NewRecoveryCodes()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not create recovery codes"})
+			return
+		}
+		_, err = cfg.MongoClient.Database(cfg.DBName).Collection("users").UpdateOne(ctx,
+			bson.M{"_id": user.ID},
+			bson.M{"$set": bson.M{"totp.recovery_codes": hashes, "updated_at": time.Now()}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not save recovery codes"})
+			return
+		}
+
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+	}
+}
+
+// DisableTwoFactor - turn two-factor off with a current or recovery code.
+// Refused while the MFA policy requires it for the user's role.
+func DisableTwoFactor(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Code         string `json:"code"`
+			RecoveryCode string `json:"recovery_code"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		user, ok := loadRequester(ctx, c, cfg)
+		if !ok {
+			return
+		}
+		if !user.TwoFactorEnabled() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "two-factor authentication is not enabled"})
+			return
+		}
+		required, err := utils.MFARequired(ctx, cfg, user.Role)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not load MFA policy"})
+			return
+		}
+		if required {
+			c.JSON(http.StatusForbidden, gin.H{"error": "two-factor authentication is required for your role"})
+			return
+		}
+		if !checkSecondFactor(ctx, c, cfg, user, input.Code, input.RecoveryCode) {
+			return
+		}
+
+		_, err = cfg.MongoClient.Database(cfg.DBName).Collection("users").UpdateOne(ctx,
+			bson.M{"_id": user.ID},
+			bson.M{"$unset": bson.M{"totp": ""}, "$set": bson.M{"updated_at": time.Now()}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not disable two-factor authentication"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "two-factor authentication disabled"})
+	}
+}
+
+// VerifyTwoFactor - second login step: trade the challenge from VerifyOTP and
+// an authenticator (or recovery) code for tokens
+func VerifyTwoFactor(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Challenge    string `json:"challenge" binding:"required"`
+			Code         string `json:"code"`
+			RecoveryCode string `json:"recovery_code"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		db := cfg.MongoClient.Database(cfg.DBName)
+		challenges := db.Collection("mfa_challenges")
+
+		// Count the attempt up front so parallel guesses can't exceed the limit
+		var challenge models.MFAChallenge
+		err := challenges.FindOneAndUpdate(ctx,
+			bson.M{
+				"token_hash": utils.HashToken(input.Challenge),
+				"expires_at": bson.M{"$gt": time.Now()},
+				"attempts":   bson.M{"$lt": mfaChallengeMaxAttempts},
+			},
+			bson.M{"$inc": bson.M{"attempts": 1}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&challenge)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "challenge expired or invalid, sign in again"})
+			return
+		}
+
+		var user models.User
+		if err := db.Collection("users").FindOne(ctx, bson.M{"_id": challenge.UserID}).Decode(&user); err != nil || !user.TwoFactorEnabled() {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "challenge expired or invalid, sign in again"})
+			return
+		}
+
+		if !checkSecondFactor(ctx, c, cfg, user, input.Code, input.RecoveryCode) {
+			return
+		}
+
+		// Single use
+		if res, err := challenges.DeleteOne(ctx, bson.M{"_id": challenge.ID}); err != nil || res.DeletedCount == 0 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "challenge expired or invalid, sign in again"})
+			return
+		}
+
+		issueSession(ctx, c, cfg, user, true)
+	}
+}
+
+// GetMFAPolicy - admin: the roles that must use two-factor authentication
+func GetMFAPolicy(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		policy, err := utils.LoadMFAPolicy(ctx, cfg)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not load MFA policy"})
+			return
+		}
+		c.JSON(http.StatusOK, policy)
+	}
+}
+
+// SetMFAPolicy - admin: replace the roles that must use two-factor
+// authentication to reach the credentials vault. Sessions that have not passed
+// two-factor are turned away by middleware.RequireMFA from the next request.
+func SetMFAPolicy(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			RequiredRoles []string `json:"required_roles" binding:"required,dive,oneof=host manager cleaner guest admin"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		adminID, err := primitive.ObjectIDFromHex(c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user id"})
+			return
+		}
+
+		policy := models.MFAPolicy{
+			ID:            models.MFAPolicyID,
+			RequiredRoles: input.RequiredRoles,
+			UpdatedBy:     &adminID,
+			UpdatedAt:     time.Now(),
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		_, err = cfg.MongoClient.Database(cfg.DBName).Collection("mfa_policy").ReplaceOne(ctx,
+			bson.M{"_id": models.MFAPolicyID}, policy, options.Replace().SetUpsert(true))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not save MFA policy"})
+			return
+		}
+
+		c.JSON(http.StatusOK, policy)
+	}
+}
+
+// =============================
+// Helpers
+// =============================
+
+// loadRequester fetches the authenticated user's document
+func loadRequester(ctx context.Context, c *gin.Context, cfg *config.Config) (models.User, bool) {
+	var user models.User
+	userID, err := primitive.ObjectIDFromHex(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user id"})
+		return user, false
+	}
+	if err := cfg.MongoClient.Database(cfg.DBName).Collection("users").
+		FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+		return user, false
+	}
+	return user, true
+}
+
+// createMFAChallenge stores a challenge for the user and returns its token
+func createMFAChallenge(ctx context.Context, cfg *config.Config, userID primitive.ObjectID) (string, error) {
+	token, err := utils.RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	_, err = cfg.MongoClient.Database(cfg.DBName).Collection("mfa_challenges").InsertOne(ctx, models.MFAChallenge{
+		ID:        primitive.NewObjectID(),
+		TokenHash: utils.HashToken(token),
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(mfaChallengeTTL),
+		CreatedAt: time.Now(),
+	})
+	return token, err
+}
+
+// openUserTOTP decrypts an enrolment secret sealed with the user's data key
+func openUserTOTP(ctx context.Context, cfg *config.Config, userID primitive.ObjectID, sealed string) (utils.TOTP, error) {
+	key, err := utils.UserDataKey(ctx, cfg, userID)
+	if err != nil {
+		return utils.TOTP{}, err
+	}
+	uri, err := key.Open(sealed)
+	if err != nil {
+		return utils.TOTP{}, err
+	}
+	return utils.ParseTOTP(uri)
+}
+
+// checkSecondFactor verifies an authenticator or recovery code for user,
+// writing 401, or 429 once the account has failed too often
+func checkSecondFactor(ctx context.Context, c *gin.Context, cfg *config.Config, user models.User, code, recoveryCode string) bool {
+	limit := throttleCheck{secondFactorPerAccount, user.ID.Hex()}
+	if !checkThrottles(ctx, c, cfg, limit) {
+		return false
+	}
+
+	err := matchSecondFactor(ctx, cfg, user, code, recoveryCode)
+	if errors.Is(err, errSecondFactorFailed) {
+		hitThrottles(ctx, cfg, limit)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not check authentication code"})
+		return false
+	}
+
+	resetThrottles(ctx, cfg, limit)
+	return true
+}
+
+// matchSecondFactor accepts either an authenticator code, whose time step must
+// be newer than the last one used, or a recovery code, which is consumed.
+// Both are claimed with a conditional update so a code works only once.
+func matchSecondFactor(ctx context.Context, cfg *config.Config, user models.User, code, recoveryCode string) error {
+	users := cfg.MongoClient.Database(cfg.DBName).Collection("users")
+
+	switch {
+	case code != "":
+		totp, err := openUserTOTP(ctx, cfg, user.ID, user.TOTP.Secret)
+		if err != nil {
+			return err
+		}
+		step, ok := totp.Match(code, time.Now(), totpSkew)
+		if !ok {
+			return errSecondFactorFailed
+		}
+		res, err := users.UpdateOne(ctx,
+			bson.M{"_id": user.ID, "totp.last_step": bson.M{"$not": bson.M{"$gte": step}}},
+			bson.M{"$set": bson.M{"totp.last_step": step}},
+		)
+		if err != nil {
+			return err
+		}
+		if res.ModifiedCount == 0 {
+			return errSecondFactorFailed // replayed
+		}
+		return nil
+
+	case recoveryCode != "":
+		hash := utils.HashRecoveryCode(recoveryCode)
+		res, err := users.UpdateOne(ctx,
+			bson.M{"_id": user.ID, "totp.recovery_codes": hash},
+			bson.M{"$pull": bson.M{"totp.recovery_codes": hash}},
+		)
+		if err != nil {
+			return err
+		}
+		if res.ModifiedCount == 0 {
+			return errSecondFactorFailed
+		}
+		return nil
+	}
+	return errSecondFactorFailed
+}
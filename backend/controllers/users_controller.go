@@ -3,6 +3,7 @@ package controllers
 import (
 	"context"
 	"net/http"
+	"regexp"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,34 +13,54 @@ import (
 
 	"github.com/phillip/backend/config"
 	"github.com/phillip/backend/models"
+	"github.com/phillip/backend/utils"
 )
 
+// ListUsers - paginated users. Filters: role, q (name or email contains).
 func ListUsers(cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Get role from context (set by your auth middleware)
-		// role, exists := c.Get("role")
-		// if !exists || role != "admin" {
-		// 	c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
-		// 	return
-		// }
+		// Admins see everyone, other users only the people they work with
+		params, err := utils.ParseListParams(c, map[string]string{
+			"name":       "name",
+			"email":      "email",
+			"created_at": "created_at",
+		}, "name")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		filter := bson.M{}
+		if role := c.Query("role"); role != "" {
+			filter["role"] = role
+		}
+		if q := c.Query("q"); q != "" {
+			pattern := bson.M{"$regex": regexp.QuoteMeta(q), "$options": "i"}
+			filter["$or"] = bson.A{bson.M{"name": pattern}, bson.M{"email": pattern}}
+		}
 
 		col := cfg.MongoClient.Database(cfg.DBName).Collection("users")
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		cursor, err := col.Find(ctx, bson.M{})
+		scope, ok := requestScope(c)
+		if !ok {
+			return
+		}
+		filter, err = scope.Users(ctx, filter)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch users"})
 			return
 		}
 
-		var users []models.User
-		if err := cursor.All(ctx, &users); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not decode Users"})
+		users := []models.User{}
+		page, err := utils.FindPage(ctx, col, filter, params, &users)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch users"})
 			return
 		}
 
-		c.JSON(http.StatusOK, users)
+		c.JSON(http.StatusOK, utils.PageResponse(users, page))
 	}
 }
 
@@ -52,13 +73,24 @@ func GetUser(cfg *config.Config) gin.HandlerFunc {
             return
         }
 
+        scope, ok := requestScope(c)
+        if !ok {
+            return
+        }
+
         var user models.User
         ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
         defer cancel()
 
+        filter, err := scope.Users(ctx, bson.M{"_id": usrID})
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch user"})
+            return
+        }
+
         err = cfg.MongoClient.Database(cfg.DBName).
             Collection("users").
-            FindOne(ctx, bson.M{"_id": usrID}).
+            FindOne(ctx, filter).
             Decode(&user)
 
         if err != nil {
@@ -73,6 +105,9 @@ func GetUser(cfg *config.Config) gin.HandlerFunc {
 
 func UpdateUser(cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		role := c.GetString("role")
+		requesterID := c.GetString("user_id")
+
 		userID := c.Param("id")
 		objID, err := primitive.ObjectIDFromHex(userID)
 		if err != nil {
@@ -80,11 +115,20 @@ func UpdateUser(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
+		// Users may only edit themselves unless they are an admin
+		if role != models.RoleAdmin && requesterID != userID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+
 		var input struct {
 			Name  string `json:"name,omitempty"`
-			Email string `json:"email,omitempty"`
+			Email string `json:"email,omitempty" binding:"omitempty,email"`
 			Phone string `json:"phone,omitempty"`
 			Role  string `json:"role,omitempty"`
+
+			// How messages to the phone are sent
+			PhoneChannel string `json:"phone_channel,omitempty" binding:"omitempty,oneof=sms whatsapp"`
 		}
 
 		if err := c.ShouldBindJSON(&input); err != nil {
@@ -92,29 +136,109 @@ func UpdateUser(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
+		col := cfg.MongoClient.Database(cfg.DBName).Collection("users")
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var current models.User
+		if err := col.FindOne(ctx, bson.M{"_id": objID}).Decode(&current); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+
+		// A new email or phone needs confirming before it replaces the old one
+		contact := map[string]string{}
+		if input.Email != "" && input.Email != current.Email {
+			contact["email"] = input.Email
+		}
+		// Sending the current phone again verifies it, if it isn't yet
+		if input.Phone != "" && (input.Phone != current.Phone || current.PhoneVerifiedAt == nil) {
+			contact["phone"] = input.Phone
+		}
+
 		update := bson.M{}
+		unset := bson.M{}
 		if input.Name != "" {
 			update["name"] = input.Name
 		}
-		if input.Email != "" {
-			update["email"] = input.Email
+		if input.PhoneChannel != "" {
+			update["phone_channel"] = input.PhoneChannel
+			current.PhoneChannel = input.PhoneChannel // so a phone change below uses it
 		}
-		if input.Phone != "" {
-			update["phone"] = input.Phone
+
+		verify := ""
+		if requesterID == userID {
+			if len(contact) > 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "change email and phone one at a time"})
+				return
+			}
+			for field, value := range contact {
+				if !startContactChange(ctx, c, cfg, current, field, value) {
+					return
+				}
+				verify = field
+			}
+		} else {
+			// Admins fixing someone else's account set it directly; a new
+			// email is then verified by the next login code sent to it
+			for field, value := range contact {
+				taken, err := contactTaken(ctx, cfg, field, value, objID)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update user"})
+					return
+				}
+				if taken {
+					c.JSON(http.StatusConflict, gin.H{"error": field + " already registered"})
+					return
+				}
+				update[field] = value
+			}
+			if _, ok := contact["email"]; ok {
+				unset["email_verified_at"] = ""
+			}
+			if _, ok := contact["phone"]; ok {
+				unset["phone_verified_at"] = ""
+			}
 		}
 		if input.Role != "" {
-			update["role"] = input.Role
+			// Only admins can change roles, otherwise anyone could promote themselves
+			if role != models.RoleAdmin {
+				c.JSON(http.StatusForbidden, gin.H{"error": "only admins can change roles"})
+				return
+			}
+			switch input.Role {
+			case models.RoleHost, models.RoleManager, models.RoleCleaner, models.RoleGuest, models.RoleAdmin:
+				update["role"] = input.Role
+			default:
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid role"})
+				return
+			}
 		}
 
-		col := cfg.MongoClient.Database(cfg.DBName).Collection("users")
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
+		if len(update) == 0 {
+			if verify != "" {
+				c.JSON(http.StatusAccepted, gin.H{
+					"status":              http.StatusAccepted,
+					"message":             "Confirm the code we sent to finish changing your " + verify,
+					"verification_needed": verify,
+				})
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": "no fields to update"})
+			return
+		}
+		update["updated_at"] = time.Now()
+
+		changes := bson.M{"$set": update}
+		if len(unset) > 0 {
+			changes["$unset"] = unset
+		}
 
-		var updatedUser bson.M
+		var updatedUser models.User
 		err = col.FindOneAndUpdate(
 			ctx,
 			bson.M{"_id": objID},
-			bson.M{"$set": update},
+			changes,
 			options.FindOneAndUpdate().SetReturnDocument(options.After),
 		).Decode(&updatedUser)
 
@@ -123,11 +247,15 @@ func UpdateUser(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{
+		resp := gin.H{
 			"status":  http.StatusOK,
 			"message": "User updated successfully",
 			"user":    updatedUser,
-		})
+		}
+		if verify != "" {
+			resp["verification_needed"] = verify
+		}
+		c.JSON(http.StatusOK, resp)
 	}
 }
 
@@ -136,8 +264,8 @@ func UpdateUser(cfg *config.Config) gin.HandlerFunc {
 func DeleteUser(cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get role and userID from context
-		role, _ := c.Get("role")
-		requesterID, _ := c.Get("userID")
+		role := c.GetString("role")
+		requesterID := c.GetString("user_id")
 
 		// Get user id from URL param
 		userID := c.Param("id")
@@ -148,7 +276,7 @@ func DeleteUser(cfg *config.Config) gin.HandlerFunc {
 		}
 
 		// If not admin, only allow deleting their own account
-		if role != "admin" && requesterID != userID {
+		if role != models.RoleAdmin && requesterID != userID {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
 			return
 		}
@@ -0,0 +1,464 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/phillip/backend/config"
+	"github.com/phillip/backend/models"
+	"github.com/phillip/backend/utils"
+)
+
+// CreateVaultItem - add a secure note or generic secret to the requester's vault
+func CreateVaultItem(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := primitive.ObjectIDFromHex(c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user id"})
+			return
+		}
+
+		var input struct {
+			Kind     string `json:"kind" binding:"omitempty,oneof=note secret"`
+			Name     string `json:"name" binding:"required"`
+			URL      string `json:"url"`
+			Username string `json:"username"`
+			Secret   string `json:"secret"`
+			Notes    string `json:"notes"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if input.Secret == "" && input.Notes == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "secret or notes is required"})
+			return
+		}
+		if input.Kind == "" {
+			input.Kind = models.VaultItemNote
+			if input.Secret != "" {
+				input.Kind = models.VaultItemSecret
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		item := models.VaultItem{
+			ID:        primitive.NewObjectID(),
+			UserID:    userID,
+			Kind:      input.Kind,
+			Name:      input.Name,
+			URL:       input.URL,
+			Username:  input.Username,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+
+		key, err := utils.UserDataKey(ctx, cfg, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not load encryption key"})
+			return
+		}
+		if item.SecretEncrypted, item.NotesEncrypted, err = sealVaultItem(key, input.Secret, input.Notes); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "encryption failed"})
+			return
+		}
+
+		if _, err := cfg.MongoClient.Database(cfg.DBName).Collection("vault_items").InsertOne(ctx, item); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not save vault item"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"id": item.ID.Hex(), "message": "vault item created"})
+	}
+}
+
+// ListVaultItems - the requester's vault items, metadata only (GetVaultItem
+// decrypts one). Filters: kind, q (name, url or username).
+func ListVaultItems(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := primitive.ObjectIDFromHex(c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user id"})
+			return
+		}
+
+		params, err := utils.ParseListParams(c, map[string]string{
+			"name":       "name",
+			"created_at": "created_at",
+			"updated_at": "updated_at",
+		}, "name")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		filter := bson.M{"user_id": userID}
+		if kind := c.Query("kind"); kind != "" {
+			filter["kind"] = kind
+		}
+		if q := c.Query("q"); q != "" {
+			pattern := primitive.Regex{Pattern: regexp.QuoteMeta(q), Options: "i"}
+			filter["$or"] = bson.A{
+				bson.M{"name": pattern},
+				bson.M{"url": pattern},
+				bson.M{"username": pattern},
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		items := []models.VaultItem{}
+		col := cfg.MongoClient.Database(cfg.DBName).Collection("vault_items")
+		info, err := utils.FindPage(ctx, col, filter, params, &items)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch vault items"})
+			return
+		}
+
+		out := make([]gin.H, 0, len(items))
+		for _, item := range items {
+			out = append(out, vaultItemMetadata(item))
+		}
+
+		c.JSON(http.StatusOK, utils.PageResponse(out, info))
+	}
+}
+
+// GetVaultItem - one vault item with its secret and notes decrypted
+func GetVaultItem(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		item, ok := loadOwnedVaultItem(ctx, c, cfg)
+		if !ok {
+			return
+		}
+
+		key, err := utils.UserDataKey(ctx, cfg, item.UserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not load encryption key"})
+			return
+		}
+		out := vaultItemMetadata(item)
+		for field, sealed := range map[string]string{"secret": item.SecretEncrypted, "notes": item.NotesEncrypted} {
+			out[field] = ""
+			if sealed == "" {
+				continue
+			}
+			plain, err := key.Open(sealed)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "could not decrypt vault item"})
+				return
+			}
+			out[field] = plain
+		}
+
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// UpdateVaultItem - edit a vault item; empty fields are left unchanged
+func UpdateVaultItem(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Kind     string `json:"kind" binding:"omitempty,oneof=note secret"`
+			Name     string `json:"name"`
+			URL      string `json:"url"`
+			Username string `json:"username"`
+			Secret   string `json:"secret"`
+			Notes    string `json:"notes"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		item, ok := loadOwnedVaultItem(ctx, c, cfg)
+		if !ok {
+			return
+		}
+
+		update := bson.M{"updated_at": time.Now()}
+		for field, value := range map[string]string{"kind": input.Kind, "name": input.Name, "url": input.URL, "username": input.Username} {
+			if value != "" {
+				update[field] = value
+			}
+		}
+		if input.Secret != "" || input.Notes != "" {
+			key, err := utils.UserDataKey(ctx, cfg, item.UserID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "could not load encryption key"})
+				return
+			}
+			secret, notes, err := sealVaultItem(key, input.Secret, input.Notes)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "encryption failed"})
+				return
+			}
+			if secret != "" {
+				update["secret_encrypted"] = secret
+			}
+			if notes != "" {
+				update["notes_encrypted"] = notes
+			}
+		}
+		if len(update) == 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "no fields to update"})
+			return
+		}
+
+		_, err := cfg.MongoClient.Database(cfg.DBName).Collection("vault_items").UpdateOne(ctx,
+			bson.M{"_id": item.ID, "user_id": item.UserID},
+			bson.M{"$set": update},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update vault item"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "vault item updated", "id": item.ID.Hex()})
+	}
+}
+
+// DeleteVaultItem - remove one of the requester's vault items
+func DeleteVaultItem(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		item, ok := loadOwnedVaultItem(ctx, c, cfg)
+		if !ok {
+			return
+		}
+
+		res, err := cfg.MongoClient.Database(cfg.DBName).Collection("vault_items").
+			DeleteOne(ctx, bson.M{"_id": item.ID, "user_id": item.UserID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete vault item"})
+			return
+		}
+		if res.DeletedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "vault item not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "vault item deleted", "id": item.ID.Hex()})
+	}
+}
+
+// MigrateLegacyVaultItems - admin: move plaintext documents written by the old
+// vault API (the "vault" collection) into vault_items, sealing the password
+// and notes with each owner's data key, ?batch= at a time (default 500). Each
+// document keeps its _id, so a run interrupted between the insert and the
+// delete just finishes the delete next time. A document that can't be moved
+// is left in place marked with migration_error and skipped by later calls;
+// ?retry_failed=true clears the marks first. Call until "remaining" is 0.
+func MigrateLegacyVaultItems(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		batch := int64(500)
+		if b := c.Query("batch"); b != "" {
+			n, err := strconv.ParseInt(b, 10, 64)
+			if err != nil || n < 1 || n > 5000 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "batch must be between 1 and 5000"})
+				return
+			}
+			batch = n
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+
+		db := cfg.MongoClient.Database(cfg.DBName)
+		legacy := db.Collection("vault")
+		items := db.Collection("vault_items")
+
+		if c.Query("retry_failed") == "true" {
+			_, err := legacy.UpdateMany(ctx,
+				bson.M{legacyMigrationErrorField: bson.M{"$exists": true}},
+				bson.M{"$unset": bson.M{legacyMigrationErrorField: ""}},
+			)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "could not clear failures"})
+				return
+			}
+		}
+
+		pending := bson.M{legacyMigrationErrorField: bson.M{"$exists": false}}
+		cursor, err := legacy.Find(ctx, pending, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(batch))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch legacy vault items"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		migrated := 0
+		failed := []string{}
+		keys := utils.NewDataKeys(cfg)
+		// Set the document aside so the next batch doesn't fetch it again
+		fail := func(id bson.RawValue, reason string) {
+			failed = append(failed, rawIDString(id))
+			_, _ = legacy.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{legacyMigrationErrorField: reason}})
+		}
+
+		for cursor.Next(ctx) {
+			rawID := cursor.Current.Lookup("_id")
+
+			var old models.LegacyVaultItem
+			if err := cursor.Decode(&old); err != nil {
+				fail(rawID, "could not decode: "+err.Error())
+				continue
+			}
+			if old.UserID.IsZero() {
+				fail(rawID, "no user_id")
+				continue
+			}
+
+			key, err := keys.For(ctx, old.UserID, models.DataKeyOwnerUser)
+			if err != nil {
+				fail(rawID, "data key: "+err.Error())
+				continue
+			}
+
+			item := models.VaultItem{
+				ID:        old.ID,
+				UserID:    old.UserID,
+				Kind:      models.VaultItemNote,
+				Name:      old.Name,
+				URL:       old.URL,
+				Username:  old.Username,
+				CreatedAt: old.CreatedAt,
+				UpdatedAt: old.UpdatedAt,
+			}
+			if old.Password != "" {
+				item.Kind = models.VaultItemSecret
+			}
+			if item.Name == "" {
+				item.Name = "Untitled"
+			}
+			if item.CreatedAt.IsZero() {
+				item.CreatedAt = old.ID.Timestamp()
+			}
+			if item.UpdatedAt.IsZero() {
+				item.UpdatedAt = item.CreatedAt
+			}
+			if item.SecretEncrypted, item.NotesEncrypted, err = sealVaultItem(key, old.Password, old.Notes); err != nil {
+				fail(rawID, "seal: "+err.Error())
+				continue
+			}
+
+			if _, err := items.InsertOne(ctx, item); err != nil && !mongo.IsDuplicateKeyError(err) {
+				fail(rawID, "insert: "+err.Error())
+				continue
+			}
+			if _, err := legacy.DeleteOne(ctx, bson.M{"_id": old.ID}); err != nil {
+				fail(rawID, "delete: "+err.Error())
+				continue
+			}
+			migrated++
+		}
+
+		remaining, err := legacy.CountDocuments(ctx, pending)
+		if err != nil {
+			remaining = -1
+		}
+		failedTotal, err := legacy.CountDocuments(ctx, bson.M{legacyMigrationErrorField: bson.M{"$exists": true}})
+		if err != nil {
+			failedTotal = -1
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"migrated":     migrated,
+			"failed":       failed,
+			"failed_total": failedTotal,
+			"remaining":    remaining,
+		})
+	}
+}
+
+// =============================
+// Helpers
+// =============================
+
+// legacyMigrationErrorField marks a legacy vault document that could not be
+// migrated, with the reason, so later batches skip it
+const legacyMigrationErrorField = "migration_error"
+
+// rawIDString renders a document _id for the "failed" list
+func rawIDString(id bson.RawValue) string {
+	if oid, ok := id.ObjectIDOK(); ok {
+		return oid.Hex()
+	}
+	return id.String()
+}
+
+// loadOwnedVaultItem fetches the vault item in :id if the requester owns it,
+// writing 400 or 404 otherwise
+func loadOwnedVaultItem(ctx context.Context, c *gin.Context, cfg *config.Config) (models.VaultItem, bool) {
+	var item models.VaultItem
+	itemID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid vault item id"})
+		return item, false
+	}
+	userID, err := primitive.ObjectIDFromHex(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user id"})
+		return item, false
+	}
+
+	err = cfg.MongoClient.Database(cfg.DBName).Collection("vault_items").
+		FindOne(ctx, bson.M{"_id": itemID, "user_id": userID}).Decode(&item)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "vault item not found"})
+		return item, false
+	}
+	return item, true
+}
+
+// sealVaultItem encrypts whichever of secret and notes is set
+func sealVaultItem(key *utils.DataKey, secret, notes string) (string, string, error) {
+	var sealedSecret, sealedNotes string
+	var err error
+	if secret != "" {
+		if sealedSecret, err = key.Seal(secret); err != nil {
+			return "", "", err
+		}
+	}
+	if notes != "" {
+		if sealedNotes, err = key.Seal(notes); err != nil {
+			return "", "", err
+		}
+	}
+	return sealedSecret, sealedNotes, nil
+}
+
+func vaultItemMetadata(item models.VaultItem) gin.H {
+	return gin.H{
+		"id":         item.ID.Hex(),
+		"kind":       item.Kind,
+		"name":       item.Name,
+		"url":        item.URL,
+		"username":   item.Username,
+		"has_secret": item.SecretEncrypted != "",
+		"has_notes":  item.NotesEncrypted != "",
+		"created_at": item.CreatedAt,
+		"updated_at": item.UpdatedAt,
+	}
+}
@@ -0,0 +1,317 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/phillip/backend/config"
+	"github.com/phillip/backend/models"
+)
+
+// CreateVault - create a named shared vault owned by the requester
+func CreateVault(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := primitive.ObjectIDFromHex(c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user id"})
+			return
+		}
+
+		var input struct {
+			Name string `json:"name" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		vault := models.SharedVault{
+			ID:        primitive.NewObjectID(),
+			Name:      input.Name,
+			OwnerID:   userID,
+			Members:   []models.VaultMember{},
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if _, err := cfg.MongoClient.Database(cfg.DBName).Collection("shared_vaults").InsertOne(ctx, vault); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not create vault"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, vault)
+	}
+}
+
+// ListVaults - vaults the requester owns or is a member of
+func ListVaults(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := primitive.ObjectIDFromHex(c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user id"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		vaults, err := accessibleVaults(ctx, cfg, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch vaults"})
+			return
+		}
+
+		out := make([]gin.H, 0, len(vaults))
+		for _, v := range vaults {
+			out = append(out, gin.H{
+				"id":         v.ID.Hex(),
+				"name":       v.Name,
+				"owner_id":   v.OwnerID.Hex(),
+				"members":    v.Members,
+				"access":     v.AccessFor(userID),
+				"created_at": v.CreatedAt,
+				"updated_at": v.UpdatedAt,
+			})
+		}
+
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// RenameVault - owner renames a vault
+func RenameVault(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Name string `json:"name" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		vault, ok := loadOwnedVault(ctx, c, cfg)
+		if !ok {
+			return
+		}
+
+		_, err := cfg.MongoClient.Database(cfg.DBName).Collection("shared_vaults").UpdateOne(ctx,
+			bson.M{"_id": vault.ID},
+			bson.M{"$set": bson.M{"name": input.Name, "updated_at": time.Now()}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not rename vault"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "vault renamed", "id": vault.ID.Hex()})
+	}
+}
+
+// DeleteVault - owner deletes an empty vault. The vault goes first, so no new
+// credential can be added to it; its data key only goes once it is still empty.
+func DeleteVault(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		vault, ok := loadOwnedVault(ctx, c, cfg)
+		if !ok {
+			return
+		}
+
+		db := cfg.MongoClient.Database(cfg.DBName)
+		count, err := db.Collection("credentials").CountDocuments(ctx, bson.M{"vault_id": vault.ID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not check vault contents"})
+			return
+		}
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "vault still holds credentials", "count": count})
+			return
+		}
+
+		res, err := db.Collection("shared_vaults").DeleteOne(ctx, bson.M{"_id": vault.ID, "owner_id": vault.OwnerID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not delete vault"})
+			return
+		}
+		if res.DeletedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "vault not found"})
+			return
+		}
+
+		// A credential added while we counted keeps the vault, and the key it is sealed with
+		count, err = db.Collection("credentials").CountDocuments(ctx, bson.M{"vault_id": vault.ID})
+		if err != nil || count > 0 {
+			if _, err := db.Collection("shared_vaults").InsertOne(ctx, vault); err != nil {
+				log.Printf("vaults: could not restore vault %s: %v", vault.ID.Hex(), err)
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "could not check vault contents"})
+				return
+			}
+			c.JSON(http.StatusConflict, gin.H{"error": "vault still holds credentials", "count": count})
+			return
+		}
+		_, _ = db.Collection("data_keys").DeleteOne(ctx, bson.M{"_id": vault.ID})
+
+		c.JSON(http.StatusOK, gin.H{"message": "vault deleted", "id": vault.ID.Hex()})
+	}
+}
+
+// SetVaultMember - owner grants a user read or write access
+func SetVaultMember(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		memberID, err := primitive.ObjectIDFromHex(c.Param("userId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+			return
+		}
+
+		var input struct {
+			Access string `json:"access" binding:"required,oneof=read write"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		vault, ok := loadOwnedVault(ctx, c, cfg)
+		if !ok {
+			return
+		}
+		if memberID == vault.OwnerID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "the owner always has write access"})
+			return
+		}
+
+		db := cfg.MongoClient.Database(cfg.DBName)
+		// Unverified registrations can't be given access
+		if n, _ := db.Collection("users").CountDocuments(ctx, bson.M{"_id": memberID, "status": bson.M{"$ne": models.UserPending}}); n == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+
+		// Change the user's grant in place, or add one. Each step is a single
+		// atomic update, so a concurrent removal is never written back over.
+		col := db.Collection("shared_vaults")
+		grant := models.VaultMember{UserID: memberID, Access: input.Access}
+		after := options.FindOneAndUpdate().SetReturnDocument(options.After)
+		var updated models.SharedVault
+		for attempt := 0; ; attempt++ {
+			err = col.FindOneAndUpdate(ctx,
+				bson.M{"_id": vault.ID, "members.user_id": memberID},
+				bson.M{"$set": bson.M{"members.$.access": input.Access, "updated_at": time.Now()}},
+				after,
+			).Decode(&updated)
+			if err != mongo.ErrNoDocuments {
+				break
+			}
+			err = col.FindOneAndUpdate(ctx,
+				bson.M{"_id": vault.ID, "members.user_id": bson.M{"$ne": memberID}},
+				bson.M{"$push": bson.M{"members": grant}, "$set": bson.M{"updated_at": time.Now()}},
+				after,
+			).Decode(&updated)
+			// No match: the user was added meanwhile (try the update again) or the vault is gone
+			if err != mongo.ErrNoDocuments || attempt == 2 {
+				break
+			}
+		}
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "vault not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not update members"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "member access set", "id": vault.ID.Hex(), "members": updated.Members})
+	}
+}
+
+// RemoveVaultMember - owner revokes a user's access
+func RemoveVaultMember(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		memberID, err := primitive.ObjectIDFromHex(c.Param("userId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		vault, ok := loadOwnedVault(ctx, c, cfg)
+		if !ok {
+			return
+		}
+
+		res, err := cfg.MongoClient.Database(cfg.DBName).Collection("shared_vaults").UpdateOne(ctx,
+			bson.M{"_id": vault.ID},
+			bson.M{
+				"$pull": bson.M{"members": bson.M{"user_id": memberID}},
+				"$set":  bson.M{"updated_at": time.Now()},
+			},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not update members"})
+			return
+		}
+		if res.ModifiedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user is not a member of this vault"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "member removed", "id": vault.ID.Hex()})
+	}
+}
+
+// loadOwnedVault fetches the vault in :id if the requester owns it, writing
+// 404 otherwise (members can't manage a vault, and non-members can't see it)
+func loadOwnedVault(ctx context.Context, c *gin.Context, cfg *config.Config) (models.SharedVault, bool) {
+	var vault models.SharedVault
+	vaultID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid vault id"})
+		return vault, false
+	}
+	userID, _ := primitive.ObjectIDFromHex(c.GetString("user_id"))
+
+	err = cfg.MongoClient.Database(cfg.DBName).Collection("shared_vaults").
+		FindOne(ctx, bson.M{"_id": vaultID, "owner_id": userID}).Decode(&vault)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "vault not found"})
+		return vault, false
+	}
+	return vault, true
+}
+
+// vaultRemoved reports whether the vault a credential was just added to has
+// been deleted since it was checked. DeleteVault removes the vault before it
+// counts what is left, so either it sees the credential or the writer sees
+// the vault gone and takes the credential back out.
+func vaultRemoved(ctx context.Context, cfg *config.Config, vaultID *primitive.ObjectID) bool {
+	if vaultID == nil {
+		return false
+	}
+	n, err := cfg.MongoClient.Database(cfg.DBName).Collection("shared_vaults").CountDocuments(ctx, bson.M{"_id": *vaultID})
+	return err == nil && n == 0
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/joho/godotenv"
 
 	"github.com/phillip/backend/config"
+	"github.com/phillip/backend/controllers"
 	"github.com/phillip/backend/routes"
 )
 
@@ -25,15 +26,9 @@ func main() {
         log.Fatalf("config load error: %v", err)
     }
 
-    // ✅ Connect to MongoDB first
-    client := config.ConnectDB()
-    if client == nil {
-        log.Fatal("❌ Could not connect to MongoDB")
-    }
+    // ✅ LoadConfig has connected to MongoDB; now ensure indexes
     log.Println("✅ Connected to MongoDB")
-
-    // ✅ Now ensure indexes
-    config.EnsureAllIndexes(client, cfg.DBName)
+    config.EnsureAllIndexes(cfg.MongoClient, cfg.DBName)
 
 	// Gin router
 	r := gin.Default()
@@ -55,6 +50,12 @@ func main() {
 
 	routes.SetupRoutes(r, cfg)
 
+	// Keep imported OTA calendars fresh
+	go controllers.StartCalendarFeedSync(cfg, 30*time.Minute)
+
+	// Flag cleaning tasks nobody got to
+	go controllers.StartCleaningTaskSweeper(cfg, time.Hour)
+
 	// Start server
 	port := os.Getenv("PORT")
 	if port == "" {
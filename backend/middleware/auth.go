@@ -1,12 +1,15 @@
 package middleware
 
 import (
+	"context"
+	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/phillip/backend/config"
+	"github.com/phillip/backend/utils"
 )
 
 func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
@@ -20,11 +23,9 @@ func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
         // Remove "Bearer " prefix
         tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
 
-        claims := jwt.MapClaims{}
-        token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
-            return cfg.JWTSecret, nil
-        })
-        if err != nil || !token.Valid {
+        // Only access tokens, signed by one of our keys with its pinned algorithm
+        claims, err := utils.ParseToken(cfg, tokenStr, utils.AccessToken)
+        if err != nil {
             c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
             return
         }
@@ -34,8 +35,34 @@ func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
             c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid user_id in token"})
             return
         }
-        // Set user_id in Gin context
+        role, _ := claims["role"].(string)
+        mfa, _ := claims["mfa"].(bool) // passed two-factor at login
+        sid, _ := claims["sid"].(string)
+        jti, _ := claims["jti"].(string)
+
+        // Logged out or revoked sessions are denylisted until their tokens expire
+        ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+        defer cancel()
+        revoked, err := utils.IsTokenRevoked(ctx, cfg, jti, sid)
+        if err != nil {
+            log.Printf("could not check token revocation: %v", err)
+            c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "could not verify token"})
+            return
+        }
+        if revoked {
+            c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token has been revoked"})
+            return
+        }
+
+        // Set user_id, role, mfa and the session in Gin context
         c.Set("user_id", userID)
+        c.Set("role", role)
+        c.Set("mfa", mfa)
+        c.Set("sid", sid)
+        c.Set("jti", jti)
+        if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+            c.Set("token_exp", exp.Time)
+        }
         c.Next()
     }
 }
\ No newline at end of file
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/phillip/backend/config"
+	"github.com/phillip/backend/utils"
+)
+
+// RequireMFA turns away sessions that have not passed two-factor
+// authentication when the MFA policy requires it for their role. It must run
+// after AuthMiddleware, which sets "mfa" from the token.
+func RequireMFA(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetBool("mfa") {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		required, err := utils.MFARequired(ctx, cfg, c.GetString("role"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "could not load MFA policy"})
+			return
+		}
+		if required {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":        "two-factor authentication required",
+				"mfa_required": true,
+			})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/phillip/backend/config"
+	"github.com/phillip/backend/utils"
+)
+
+// TenantScope loads the requester's utils.Scope and stores it as "scope" for
+// controllers to read with utils.ScopeFrom. It must run after AuthMiddleware.
+func TenantScope(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := primitive.ObjectIDFromHex(c.GetString("user_id"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid user id"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		scope, err := utils.LoadScope(ctx, cfg, userID, c.GetString("role"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "could not load access scope"})
+			return
+		}
+
+		c.Set("scope", scope)
+		c.Next()
+	}
+}
@@ -6,13 +6,95 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const (
+	BookingPending   = "pending"
+	BookingConfirmed = "confirmed"
+	BookingCheckedIn = "checked_in"
+	BookingCompleted = "completed"
+	BookingCancelled = "cancelled"
+	BookingNoShow    = "no_show"
+)
+
+// bookingTransitions is the booking lifecycle:
+// pending → confirmed → checked_in → completed, with cancelled and no_show branches
+var bookingTransitions = map[string][]string{
+	BookingPending:   {BookingConfirmed, BookingCancelled},
+	BookingConfirmed: {BookingCheckedIn, BookingCancelled, BookingNoShow},
+	BookingCheckedIn: {BookingCompleted},
+}
+
+// CanTransition reports whether a booking may move from one status to another.
+// Bookings saved without a status are treated as pending.
+func CanTransition(from, to string) bool {
+	if from == "" {
+		from = BookingPending
+	}
+	for _, s := range bookingTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	BookingSourceICalFeed   = "ical_feed"
+	BookingSourceICalUpload = "ical_upload"
+)
+
+// BlockingBookingStatuses are the statuses that hold the property's dates
+var BlockingBookingStatuses = []string{BookingPending, BookingConfirmed, BookingCheckedIn}
+
 type Booking struct {
 	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	UserID     primitive.ObjectID `bson:"user_id" json:"user_id"`
 	PropertyID primitive.ObjectID `bson:"property_id" json:"property_id"`
 	StartDate  time.Time          `bson:"start_date" json:"start_date"`
 	EndDate    time.Time          `bson:"end_date" json:"end_date"`
-	Status     string             `bson:"status" json:"status"` // pending, confirmed, cancelled, completed
+	Status     string             `bson:"status" json:"status"` // see bookingTransitions
+
+	StatusHistory []StatusChange `bson:"status_history,omitempty" json:"status_history,omitempty"`
+
+	// Price computed when the booking was made, in minor units of Currency
+	Currency    string     `bson:"currency,omitempty" json:"currency,omitempty"`
+	TotalAmount int64      `bson:"total_amount,omitempty" json:"total_amount,omitempty"`
+	LineItems   []LineItem `bson:"line_items,omitempty" json:"line_items,omitempty"`
+
+	// Set on bookings imported from an iCal feed or upload
+	Source      string              `bson:"source,omitempty" json:"source,omitempty"` // ical_feed, ical_upload
+	FeedID      *primitive.ObjectID `bson:"feed_id,omitempty" json:"feed_id,omitempty"`
+	ExternalUID string              `bson:"external_uid,omitempty" json:"external_uid,omitempty"`
+	Summary     string              `bson:"summary,omitempty" json:"summary,omitempty"`
+
 	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
 	UpdatedAt  time.Time          `bson:"updated_at" json:"updated_at"`
 }
+
+// StatusChange records who moved a booking between statuses, and when
+type StatusChange struct {
+	From      string             `bson:"from" json:"from"`
+	To        string             `bson:"to" json:"to"`
+	ChangedBy primitive.ObjectID `bson:"changed_by" json:"changed_by"`
+	ChangedAt time.Time          `bson:"changed_at" json:"changed_at"`
+	Reason    string             `bson:"reason,omitempty" json:"reason,omitempty"`
+}
+
+// IsBlocking reports whether the booking occupies its dates
+func (b Booking) IsBlocking() bool {
+	for _, s := range BlockingBookingStatuses {
+		if b.Status == s {
+			return true
+		}
+	}
+	return false
+}
+
+// IsTerminal reports whether the booking has reached the end of its
+// lifecycle (cancelled, completed or no_show) and can no longer change
+func (b Booking) IsTerminal() bool {
+	status := b.Status
+	if status == "" {
+		status = BookingPending
+	}
+	return len(bookingTransitions[status]) == 0
+}
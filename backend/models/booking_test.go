@@ -0,0 +1,66 @@
+package models
+
+import "testing"
+
+func TestBookingIsTerminal(t *testing.T) {
+	cases := map[string]bool{
+		"":               false, // saved before statuses existed, treated as pending
+		BookingPending:   false,
+		BookingConfirmed: false,
+		BookingCheckedIn: false,
+		BookingCancelled: true,
+		BookingCompleted: true,
+		BookingNoShow:    true,
+	}
+	for status, want := range cases {
+		if got := (Booking{Status: status}).IsTerminal(); got != want {
+			t.Errorf("IsTerminal(%q) = %v, want %v", status, got, want)
+		}
+	}
+}
+
+func TestCanTransition(t *testing.T) {
+	cases := []struct {
+		from, to string
+		want     bool
+	}{
+		{BookingPending, BookingConfirmed, true},
+		{BookingPending, BookingCancelled, true},
+		{"", BookingConfirmed, true}, // saved before statuses existed, treated as pending
+		{"", BookingCancelled, true},
+		{BookingConfirmed, BookingCheckedIn, true},
+		{BookingConfirmed, BookingCancelled, true},
+		{BookingConfirmed, BookingNoShow, true},
+		{BookingCheckedIn, BookingCompleted, true},
+
+		// Skipping steps
+		{BookingPending, BookingCheckedIn, false},
+		{BookingPending, BookingCompleted, false},
+		{BookingPending, BookingNoShow, false},
+		{BookingConfirmed, BookingCompleted, false},
+
+		// Going back
+		{BookingConfirmed, BookingPending, false},
+		{BookingCheckedIn, BookingConfirmed, false},
+
+		// A stay under way can't be cancelled or missed
+		{BookingCheckedIn, BookingCancelled, false},
+		{BookingCheckedIn, BookingNoShow, false},
+
+		// Terminal statuses stay put
+		{BookingCancelled, BookingPending, false},
+		{BookingCancelled, BookingConfirmed, false},
+		{BookingCompleted, BookingCheckedIn, false},
+		{BookingNoShow, BookingConfirmed, false},
+
+		// Same status and unknown statuses
+		{BookingPending, BookingPending, false},
+		{BookingConfirmed, "archived", false},
+		{"archived", BookingCancelled, false},
+	}
+	for _, tc := range cases {
+		if got := CanTransition(tc.from, tc.to); got != tc.want {
+			t.Errorf("CanTransition(%q, %q) = %v, want %v", tc.from, tc.to, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CalendarFeed is an external iCal URL (Airbnb, Booking.com, ...) whose events
+// are imported as blocking bookings on the property
+type CalendarFeed struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	PropertyID   primitive.ObjectID `bson:"property_id" json:"property_id"`
+	UserID       primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Name         string             `bson:"name" json:"name"`
+	URL          string             `bson:"url" json:"url"`
+	LastSyncedAt *time.Time         `bson:"last_synced_at,omitempty" json:"last_synced_at,omitempty"`
+	LastError    string             `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt    time.Time          `bson:"updated_at" json:"updated_at"`
+}
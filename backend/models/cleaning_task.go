@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	TaskScheduled  = "scheduled"
+	TaskInProgress = "in_progress"
+	TaskDone       = "done"
+	TaskMissed     = "missed"
+	TaskCancelled  = "cancelled"
+)
+
+// taskTransitions is the cleaning task lifecycle:
+// scheduled → in_progress → done, with missed and cancelled branches
+var taskTransitions = map[string][]string{
+	TaskScheduled:  {TaskInProgress, TaskDone, TaskMissed, TaskCancelled},
+	TaskInProgress: {TaskDone, TaskMissed},
+}
+
+// CanTransitionTask reports whether a cleaning task may move from one status to another
+func CanTransitionTask(from, to string) bool {
+	for _, s := range taskTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenTaskStatuses are the statuses of tasks that still need doing
+var OpenTaskStatuses = []string{TaskScheduled, TaskInProgress}
+
+// LiveTaskStatuses is every status but cancelled: a booking has at most one
+// task in these (enforced by a unique index on booking_id)
+var LiveTaskStatuses = []string{TaskScheduled, TaskInProgress, TaskDone, TaskMissed}
+
+// HousekeeperTaskStatuses are the statuses the assigned housekeeper may set;
+// cancelling a task or marking it missed is up to the property owner
+var HousekeeperTaskStatuses = []string{TaskInProgress, TaskDone}
+
+// CleaningTask is the turnover clean after a confirmed booking checks out.
+// HousekeeperID is nil while nobody is assigned to the property.
+type CleaningTask struct {
+	ID            primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	PropertyID    primitive.ObjectID  `bson:"property_id" json:"property_id"`
+	BookingID     primitive.ObjectID  `bson:"booking_id" json:"booking_id"`
+	HousekeeperID *primitive.ObjectID `bson:"housekeeper_id" json:"housekeeper_id"`
+	ScheduledFor  time.Time           `bson:"scheduled_for" json:"scheduled_for"` // checkout day, 00:00 UTC
+	Status        string              `bson:"status" json:"status"`               // see taskTransitions
+	ReportID      *primitive.ObjectID `bson:"report_id,omitempty" json:"report_id,omitempty"`
+	CompletedAt   *time.Time          `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
+	CreatedAt     time.Time           `bson:"created_at" json:"created_at"`
+	UpdatedAt     time.Time           `bson:"updated_at" json:"updated_at"`
+}
@@ -6,15 +6,32 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Credential belongs to its creator (UserID) unless it is attached to a
+// property, which shares it with the property's team, or to a shared vault
 type Credential struct {
-	ID                primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	UserID            primitive.ObjectID `bson:"user_id" json:"user_id"`
-	SiteName          string             `bson:"site_name" json:"site_name"`
-	Username          string             `bson:"username" json:"username"`
-	PasswordEncrypted string             `bson:"password_encrypted" json:"-"`
-	LoginURL          string             `bson:"login_url" json:"login_url"`
-	Notes             string             `bson:"notes,omitempty" json:"notes,omitempty"`
-	Category          string             `bson:"category,omitempty" json:"category,omitempty"`
-	CreatedAt         time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt         time.Time          `bson:"updated_at" json:"updated_at"`
+	ID                primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	UserID            primitive.ObjectID  `bson:"user_id" json:"user_id"`
+	PropertyID        *primitive.ObjectID `bson:"property_id,omitempty" json:"property_id,omitempty"`
+	VaultID           *primitive.ObjectID `bson:"vault_id,omitempty" json:"vault_id,omitempty"`
+	SiteName          string              `bson:"site_name" json:"site_name"`
+	Username          string              `bson:"username" json:"username"`
+	PasswordEncrypted string              `bson:"password_encrypted" json:"-"`
+	TOTPEncrypted     string              `bson:"totp_encrypted,omitempty" json:"-"` // sealed otpauth:// URI
+	LoginURL          string              `bson:"login_url" json:"login_url"`
+	Notes             string              `bson:"notes,omitempty" json:"notes,omitempty"`
+	Category          string              `bson:"category,omitempty" json:"category,omitempty"`
+	CreatedAt         time.Time           `bson:"created_at" json:"created_at"`
+	UpdatedAt         time.Time           `bson:"updated_at" json:"updated_at"`
+}
+
+// KeyOwner is whose data key seals the credential: the vault's, the
+// property's, or the creator's
+func (c Credential) KeyOwner() (primitive.ObjectID, string) {
+	switch {
+	case c.VaultID != nil:
+		return *c.VaultID, DataKeyOwnerVault
+	case c.PropertyID != nil:
+		return *c.PropertyID, DataKeyOwnerProperty
+	}
+	return c.UserID, DataKeyOwnerUser
 }
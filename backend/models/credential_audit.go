@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	AuditCreate = "create"
+	AuditReveal = "reveal"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+	AuditImport = "import"
+	AuditExport = "export"
+	AuditTOTP   = "totp" // a one-time code was generated
+)
+
+// CredentialAudit is one entry in the append-only credential_audit
+// collection. Entries are only ever inserted, and outlive the credential.
+type CredentialAudit struct {
+	ID           primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	CredentialID primitive.ObjectID  `bson:"credential_id" json:"credential_id"`
+	OwnerID      primitive.ObjectID  `bson:"owner_id" json:"owner_id"` // credential creator
+	PropertyID   *primitive.ObjectID `bson:"property_id,omitempty" json:"property_id,omitempty"`
+	VaultID      *primitive.ObjectID `bson:"vault_id,omitempty" json:"vault_id,omitempty"`
+	ActorID      primitive.ObjectID  `bson:"actor_id" json:"actor_id"`
+	Action       string              `bson:"action" json:"action"` // see the Audit* constants
+	IP           string              `bson:"ip" json:"ip"`
+	UserAgent    string              `bson:"user_agent" json:"user_agent"`
+	CreatedAt    time.Time           `bson:"created_at" json:"created_at"`
+}
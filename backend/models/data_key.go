@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	DataKeyOwnerUser     = "user"
+	DataKeyOwnerProperty = "property"
+	DataKeyOwnerVault    = "vault"
+)
+
+// DataKey is an owner's data encryption key, stored only in wrapped form.
+// Its _id is the owner's ID, so each owner has exactly one.
+type DataKey struct {
+	ID          primitive.ObjectID `bson:"_id" json:"id"`
+	OwnerType   string             `bson:"owner_type" json:"owner_type"`
+	Provider    string             `bson:"provider" json:"provider"`           // master key provider that wrapped it
+	MasterKeyID string             `bson:"master_key_id" json:"master_key_id"` // master key it is wrapped under
+	WrappedKey  string             `bson:"wrapped_key" json:"-"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+}
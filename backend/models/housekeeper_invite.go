@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	InvitePending   = "pending"
+	InviteAccepted  = "accepted"
+	InviteDeclined  = "declined"
+	InviteCancelled = "cancelled"
+)
+
+// HousekeeperInvite asks a cleaner to join a property. They are only added to
+// Property.Housekeepers once they accept.
+type HousekeeperInvite struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	PropertyID    primitive.ObjectID `bson:"property_id" json:"property_id"`
+	InvitedBy     primitive.ObjectID `bson:"invited_by" json:"invited_by"`
+	HousekeeperID primitive.ObjectID `bson:"housekeeper_id" json:"housekeeper_id"`
+	Status        string             `bson:"status" json:"status"` // pending, accepted, declined, cancelled
+	ExpiresAt     time.Time          `bson:"expires_at" json:"expires_at"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt     time.Time          `bson:"updated_at" json:"updated_at"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MFAPolicyID is the _id of the single document in the mfa_policy collection
+const MFAPolicyID = "default"
+
+// MFAPolicy lists the roles that must use two-factor authentication before
+// they can reach the credentials vault
+type MFAPolicy struct {
+	ID            string              `bson:"_id" json:"-"`
+	RequiredRoles []string            `bson:"required_roles" json:"required_roles"`
+	UpdatedBy     *primitive.ObjectID `bson:"updated_by,omitempty" json:"updated_by,omitempty"`
+	UpdatedAt     time.Time           `bson:"updated_at" json:"updated_at"`
+}
+
+// MFAChallenge is handed out by VerifyOTP when the user has two-factor
+// enabled, and traded for tokens at /auth/2fa/verify. Only the token's hash is
+// stored; documents expire through a TTL index on expires_at.
+type MFAChallenge struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	TokenHash string             `bson:"token_hash"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	Attempts  int                `bson:"attempts"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Passkey is a WebAuthn credential a user can sign in with instead of an
+// emailed code. PublicKey is the COSE key the authenticator registered.
+type Passkey struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID         primitive.ObjectID `bson:"user_id" json:"-"`
+	Name           string             `bson:"name" json:"name"`
+	CredentialID   string             `bson:"credential_id" json:"credential_id"` // base64url, unique
+	PublicKey      []byte             `bson:"public_key" json:"-"`
+	Algorithm      int                `bson:"algorithm" json:"algorithm"` // COSE algorithm, e.g. -7 for ES256
+	SignCount      uint32             `bson:"sign_count" json:"-"`
+	Transports     []string           `bson:"transports,omitempty" json:"transports,omitempty"`
+	BackupEligible bool               `bson:"backup_eligible" json:"backup_eligible"` // synced passkey
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	LastUsedAt     *time.Time         `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+}
+
+// Passkey ceremonies
+const (
+	PasskeyRegister = "register"
+	PasskeyLogin    = "login"
+)
+
+// PasskeyChallenge is one outstanding registration or login ceremony. Only
+// the challenge's hash is stored; it is deleted when answered and otherwise
+// expires through a TTL index on expires_at.
+type PasskeyChallenge struct {
+	ID            primitive.ObjectID  `bson:"_id,omitempty"`
+	ChallengeHash string              `bson:"challenge_hash"`
+	Ceremony      string              `bson:"ceremony"`
+	UserID        *primitive.ObjectID `bson:"user_id,omitempty"` // unset for logins that let the passkey name the user
+	ExpiresAt     time.Time           `bson:"expires_at"`
+	CreatedAt     time.Time           `bson:"created_at"`
+}
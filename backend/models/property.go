@@ -14,8 +14,18 @@ type Property struct {
 	Location      string               `bson:"location" json:"location"`
 	Price         float64              `bson:"price" json:"price"`
 	Images        []string             `bson:"images" json:"images"`
-	Available  bool                 `bson:"availability" json:"availability"`
+	RatePlan      *RatePlan            `bson:"rate_plan,omitempty" json:"rate_plan,omitempty"`
+	Blackouts     []Blackout           `bson:"blackouts,omitempty" json:"blackouts,omitempty"`
 	Housekeepers  []primitive.ObjectID `bson:"housekeepers,omitempty" json:"housekeepers,omitempty"`
+	CalendarToken string               `bson:"calendar_token,omitempty" json:"-"` // guards the public .ics export
 	CreatedAt     time.Time            `bson:"created_at" json:"created_at"`
 	UpdatedAt     time.Time            `bson:"updated_at" json:"updated_at"`
 }
+
+// Blackout is an owner-defined range of nights [StartDate, EndDate) that can't be booked
+type Blackout struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	StartDate time.Time          `bson:"start_date" json:"start_date"`
+	EndDate   time.Time          `bson:"end_date" json:"end_date"`
+	Reason    string             `bson:"reason,omitempty" json:"reason,omitempty"`
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// RatePlan prices stays at a property. Every amount is in integer minor units
+// (e.g. cents) of Currency.
+type RatePlan struct {
+	Currency       string         `bson:"currency" json:"currency"` // ISO 4217, e.g. KES
+	BaseNightly    int64          `bson:"base_nightly" json:"base_nightly"`
+	WeekendNightly int64          `bson:"weekend_nightly,omitempty" json:"weekend_nightly,omitempty"` // Friday and Saturday nights, 0 = base rate
+	Seasons        []SeasonalRate `bson:"seasons,omitempty" json:"seasons,omitempty"`
+	MinStay        int            `bson:"min_stay,omitempty" json:"min_stay,omitempty"` // nights
+	CleaningFee    int64          `bson:"cleaning_fee,omitempty" json:"cleaning_fee,omitempty"`
+	TaxPercent     float64        `bson:"tax_percent,omitempty" json:"tax_percent,omitempty"`
+}
+
+// SeasonalRate overrides the nightly rate for nights in [StartDate, EndDate)
+type SeasonalRate struct {
+	Name      string    `bson:"name" json:"name"`
+	StartDate time.Time `bson:"start_date" json:"start_date"`
+	EndDate   time.Time `bson:"end_date" json:"end_date"`
+	Nightly   int64     `bson:"nightly" json:"nightly"`
+	MinStay   int       `bson:"min_stay,omitempty" json:"min_stay,omitempty"` // overrides RatePlan.MinStay for stays starting in the season
+}
+
+type LineItem struct {
+	Description string `bson:"description" json:"description"`
+	Quantity    int    `bson:"quantity" json:"quantity"`
+	UnitAmount  int64  `bson:"unit_amount" json:"unit_amount"`
+	Amount      int64  `bson:"amount" json:"amount"`
+}
+
+// Quote is the priced breakdown of a stay
+type Quote struct {
+	Currency  string     `bson:"currency" json:"currency"`
+	Nights    int        `bson:"nights" json:"nights"`
+	LineItems []LineItem `bson:"line_items" json:"line_items"`
+	Subtotal  int64      `bson:"subtotal" json:"subtotal"`
+	Tax       int64      `bson:"tax" json:"tax"`
+	Total     int64      `bson:"total" json:"total"`
+}
+
+// EffectiveRatePlan returns the property's rate plan, or one built from the
+// legacy Price field (major units) when no plan has been set yet
+func (p Property) EffectiveRatePlan(defaultCurrency string) RatePlan {
+	if p.RatePlan != nil {
+		return *p.RatePlan
+	}
+	return RatePlan{
+		Currency:    defaultCurrency,
+		BaseNightly: int64(math.Round(p.Price * 100)),
+	}
+}
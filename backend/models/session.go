@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session is one signed-in device. Its refresh tokens form a family: each
+// refresh rotates RefreshJTI, and presenting any older token from the family
+// is treated as theft and revokes the session.
+type Session struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID        primitive.ObjectID `bson:"user_id" json:"user_id"`
+	RefreshJTI    string             `bson:"refresh_jti" json:"-"` // the only refresh token still accepted
+	MFA           bool               `bson:"mfa" json:"mfa"`       // passed two-factor at sign-in
+	UserAgent     string             `bson:"user_agent" json:"user_agent"`
+	IP            string             `bson:"ip" json:"ip"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+	LastUsedAt    time.Time          `bson:"last_used_at" json:"last_used_at"`
+	ExpiresAt     time.Time          `bson:"expires_at" json:"expires_at"`
+	RevokedAt     *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+	RevokedReason string             `bson:"revoked_reason,omitempty" json:"revoked_reason,omitempty"`
+}
+
+// Why sessions end early
+const (
+	RevokedLogout     = "logout"
+	RevokedByUser     = "revoked"
+	RevokedReuse      = "refresh token reuse"
+	RevokedMFAUpgrade = "replaced by two-factor session"
+)
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	VaultAccessRead  = "read"
+	VaultAccessWrite = "write"
+)
+
+// SharedVault is a named group of credentials shared with its members
+type SharedVault struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name      string             `bson:"name" json:"name"`
+	OwnerID   primitive.ObjectID `bson:"owner_id" json:"owner_id"`
+	Members   []VaultMember      `bson:"members" json:"members"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+type VaultMember struct {
+	UserID primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Access string             `bson:"access" json:"access"` // read, write
+}
+
+// AccessFor returns the user's access to the vault: write for the owner, the
+// member's grant otherwise, or "" for non-members
+func (v SharedVault) AccessFor(userID primitive.ObjectID) string {
+	if v.OwnerID == userID {
+		return VaultAccessWrite
+	}
+	for _, m := range v.Members {
+		if m.UserID == userID {
+			return m.Access
+		}
+	}
+	return ""
+}
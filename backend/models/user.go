@@ -6,15 +6,86 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Roles carried in the access token and checked by middleware.RequireRole
+const (
+	RoleHost    = "host"
+	RoleManager = "manager"
+	RoleCleaner = "cleaner"
+	RoleGuest   = "guest"
+	RoleAdmin   = "admin"
+)
+
+// Account lifecycle. Accounts from before verification was tracked have no
+// status and count as active.
+const (
+	UserPending = "pending" // registered, email not verified yet
+	UserActive  = "active"
+)
+
+// Channels messages and one-time codes are sent on
+const (
+	ChannelEmail    = "email"
+	ChannelSMS      = "sms"
+	ChannelWhatsApp = "whatsapp"
+)
+
 type User struct {
 	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Name         string             `bson:"name" json:"name"`
 	Email        string             `bson:"email" json:"email"`
-	Role      	 string             `bson:"role" json:"role"`           // e.g., host, manager, cleaner
+	Role      	 string             `bson:"role" json:"role"`           // host, manager, cleaner, guest or admin
 	Phone     	 string             `bson:"phone,omitempty" json:"phone,omitempty"`
-	RefreshToken string             `bson:"refresh_token,omitempty" json:"-"`
-	OTP          string             `bson:"otp,omitempty" json:"-"`
+	RefreshToken string             `bson:"refresh_token,omitempty" json:"-"` // legacy, from before sessions; see models.Session
+	OTP          string             `bson:"otp,omitempty" json:"-"` // utils.HashOTP of the code sent
 	OTPExpiry    time.Time          `bson:"otp_expiry,omitempty" json:"-"`
+	OTPChannel   string             `bson:"otp_channel,omitempty" json:"-"` // where the current OTP was sent
+	TOTP         *TwoFactor         `bson:"totp,omitempty" json:"-"`
 	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
 	UpdatedAt    time.Time          `bson:"updated_at" json:"updated_at"`
-}
\ No newline at end of file
+
+	Status           string         `bson:"status,omitempty" json:"status,omitempty"` // UserPending or UserActive
+	EmailVerifiedAt  *time.Time     `bson:"email_verified_at,omitempty" json:"email_verified_at,omitempty"`
+	PhoneVerifiedAt  *time.Time     `bson:"phone_verified_at,omitempty" json:"phone_verified_at,omitempty"`
+	PhoneChannel     string         `bson:"phone_channel,omitempty" json:"phone_channel,omitempty"` // ChannelSMS (default) or ChannelWhatsApp
+	PendingExpiresAt *time.Time     `bson:"pending_expires_at,omitempty" json:"-"`                  // unverified accounts are deleted then (TTL index)
+	ContactChange    *ContactChange `bson:"contact_change,omitempty" json:"-"`
+}
+
+// ContactChange is a new email or phone awaiting its verification code. It
+// only replaces the current one once the code is confirmed.
+type ContactChange struct {
+	Field     string    `bson:"field"` // "email" or "phone"
+	Value     string    `bson:"value"`
+	Code      string    `bson:"code"`              // utils.HashOTP of the code sent
+	Channel   string    `bson:"channel,omitempty"` // where the code was sent
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// PhoneMessageChannel is how messages to the user's phone are sent
+func (u User) PhoneMessageChannel() string {
+	if u.PhoneChannel == ChannelWhatsApp {
+		return ChannelWhatsApp
+	}
+	return ChannelSMS
+}
+
+// Pending reports whether the user has yet to verify their email
+func (u User) Pending() bool {
+	return u.Status == UserPending
+}
+
+// TwoFactor is a user's authenticator-app enrolment. Secrets are otpauth://
+// URIs sealed with the user's data key; recovery codes are stored as SHA-256
+// hashes and removed as they are used.
+type TwoFactor struct {
+	Secret        string     `bson:"secret,omitempty"`  // confirmed secret
+	Pending       string     `bson:"pending,omitempty"` // awaiting a first code
+	EnabledAt     *time.Time `bson:"enabled_at,omitempty"`
+	LastStep      int64      `bson:"last_step,omitempty"` // newest time step accepted, against replay
+	RecoveryCodes []string   `bson:"recovery_codes,omitempty"`
+}
+
+// TwoFactorEnabled reports whether login needs an authenticator code
+func (u User) TwoFactorEnabled() bool {
+	return u.TOTP != nil && u.TOTP.Secret != "" && u.TOTP.EnabledAt != nil
+}
@@ -6,14 +6,39 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Vault item kinds
+const (
+	VaultItemNote   = "note"   // free-form secure note
+	VaultItemSecret = "secret" // API key, Wi-Fi password, alarm code, ...
+)
+
+// VaultItem is a personal secure note or generic secret, stored in the
+// vault_items collection. The secret and notes are sealed with the owner's
+// data key like credential passwords; name, URL and username are metadata.
 type VaultItem struct {
-    ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	UserID            primitive.ObjectID `bson:"user_id" json:"user_id"`
-    Name        string             `json:"name"`
-    URL         string             `json:"url"`
-    Username    string             `json:"username"`
-    Password    string             `json:"password"`
-    Notes       string             `json:"notes"`
-    CreatedAt   time.Time          `json:"created_at"`
-    UpdatedAt   time.Time          `json:"updated_at"`
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID          primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Kind            string             `bson:"kind" json:"kind"`
+	Name            string             `bson:"name" json:"name"`
+	URL             string             `bson:"url,omitempty" json:"url,omitempty"`
+	Username        string             `bson:"username,omitempty" json:"username,omitempty"`
+	SecretEncrypted string             `bson:"secret_encrypted,omitempty" json:"-"`
+	NotesEncrypted  string             `bson:"notes_encrypted,omitempty" json:"-"`
+	CreatedAt       time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt       time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// LegacyVaultItem is the plaintext document the old, unrouted vault API wrote
+// to the "vault" collection (field names are the driver's lowercased defaults).
+// It is only read by the migration.
+type LegacyVaultItem struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	Name      string             `bson:"name"`
+	URL       string             `bson:"url"`
+	Username  string             `bson:"username"`
+	Password  string             `bson:"password"`
+	Notes     string             `bson:"notes"`
+	CreatedAt time.Time          `bson:"createdat"`
+	UpdatedAt time.Time          `bson:"updatedat"`
 }
@@ -5,6 +5,29 @@ import (
 	"github.com/phillip/backend/config"
 	"github.com/phillip/backend/controllers"
 	"github.com/phillip/backend/middleware"
+	"github.com/phillip/backend/models"
+)
+
+// Permission matrix: which roles may call which endpoints.
+// Which records they see is narrowed by middleware.TenantScope, and ownership
+// (e.g. "only your own property") is still checked in the controllers.
+var (
+	anyRole = []string{models.RoleHost, models.RoleManager, models.RoleCleaner, models.RoleGuest, models.RoleAdmin}
+
+	// properties
+	propertyWriters  = []string{models.RoleHost, models.RoleManager, models.RoleAdmin}
+	propertyDeleters = []string{models.RoleHost, models.RoleAdmin}
+
+	// bookings (guests may book and cancel their own stays)
+	bookingWriters  = []string{models.RoleHost, models.RoleManager, models.RoleGuest, models.RoleAdmin}
+	bookingDeleters = []string{models.RoleHost, models.RoleManager, models.RoleAdmin}
+
+	// housekeeper reports
+	reportWriters  = []string{models.RoleCleaner, models.RoleManager, models.RoleAdmin}
+	reportDeleters = []string{models.RoleCleaner, models.RoleAdmin}
+
+	// cleaning tasks
+	taskWorkers = []string{models.RoleHost, models.RoleManager, models.RoleCleaner, models.RoleAdmin}
 )
 
 func SetupRoutes(r *gin.Engine, cfg *config.Config) {
@@ -16,59 +39,169 @@ func SetupRoutes(r *gin.Engine, cfg *config.Config) {
 	// otp
 	r.POST("/auth/request-otp", controllers.RequestOTP(cfg))
 	r.POST("/auth/verify-otp", controllers.VerifyOTP(cfg))
+	r.POST("/auth/2fa/verify", controllers.VerifyTwoFactor(cfg))
+
+	// passkeys, with email OTP as the fallback
+	r.POST("/auth/passkeys/login/begin", controllers.BeginPasskeyLogin(cfg))
+	r.POST("/auth/passkeys/login/finish", controllers.FinishPasskeyLogin(cfg))
+
+	// public keys for verifying our access tokens
+	r.GET("/.well-known/jwks.json", controllers.JWKS(cfg))
+
+	// iCal export, authenticated by the per-property token in the query string
+	r.GET("/properties/:id/calendar.ics", controllers.ExportPropertyCalendar(cfg))
 
 	// protected
 	auth := middleware.AuthMiddleware(cfg)
-	
+	role := middleware.RequireRole
+	scope := middleware.TenantScope(cfg)
+	mfa := middleware.RequireMFA(cfg)
+
+	// Two-factor enrolment stays reachable without it, so users can comply with the policy
+	account := r.Group("/auth")
+	account.Use(auth)
+	{
+		account.GET("/sessions", controllers.ListSessions(cfg))
+		account.DELETE("/sessions/:id", controllers.RevokeSession(cfg))
+		account.POST("/logout", controllers.Logout(cfg))
+
+		// email and phone changes started through PATCH /users/:id
+		account.POST("/contact-change/confirm", controllers.ConfirmContactChange(cfg))
+		account.DELETE("/contact-change", controllers.CancelContactChange(cfg))
+
+		account.GET("/passkeys", controllers.ListPasskeys(cfg))
+		account.POST("/passkeys/register/begin", controllers.BeginPasskeyRegistration(cfg))
+		account.POST("/passkeys/register/finish", controllers.FinishPasskeyRegistration(cfg))
+		account.DELETE("/passkeys/:id", controllers.DeletePasskey(cfg))
+	}
+
+	twoFactor := r.Group("/auth/2fa")
+	twoFactor.Use(auth)
+	{
+		twoFactor.GET("", controllers.TwoFactorStatus(cfg))
+		twoFactor.POST("/enroll", controllers.EnrollTwoFactor(cfg))
+		twoFactor.POST("/confirm", controllers.ConfirmTwoFactor(cfg))
+		twoFactor.POST("/recovery-codes", controllers.RegenerateRecoveryCodes(cfg))
+		twoFactor.POST("/disable", controllers.DisableTwoFactor(cfg))
+	}
+
 	creds := r.Group("/credentials")
-	creds.Use(auth)
+	creds.Use(auth, mfa, scope)
 	{
 		creds.POST("", controllers.CreateCredential(cfg))
 		creds.GET("", controllers.ListCredentials(cfg))
+		creds.POST("/import", controllers.ImportCredentialsCSV(cfg))
+		creds.POST("/import/backup", controllers.ImportCredentialsBackup(cfg))
+		creds.POST("/export", controllers.ExportCredentials(cfg))
+		creds.GET("/health", controllers.CredentialHealth(cfg))
+		creds.POST("/generate", controllers.GeneratePassword())
 		creds.GET(":id", controllers.GetCredential(cfg))
+		creds.POST(":id/reveal", controllers.RevealCredential(cfg))
+		creds.GET(":id/totp", controllers.CredentialTOTP(cfg))
+		creds.GET(":id/audit", controllers.ListCredentialAudit(cfg))
 		creds.PUT(":id", controllers.UpdateCredential(cfg))
 		creds.DELETE(":id", controllers.DeleteCredential(cfg))
 	}
 
+	admin := r.Group("/admin")
+	admin.Use(auth, role(models.RoleAdmin))
+	{
+		admin.POST("/credentials/reencrypt", controllers.ReencryptCredentials(cfg))
+		admin.GET("/credential-audit", controllers.QueryCredentialAudit(cfg))
+		admin.POST("/vault-items/migrate", controllers.MigrateLegacyVaultItems(cfg))
+		admin.GET("/mfa-policy", controllers.GetMFAPolicy(cfg))
+		admin.PUT("/mfa-policy", controllers.SetMFAPolicy(cfg))
+	}
+
+	vaultItems := r.Group("/vault-items")
+	vaultItems.Use(auth, mfa)
+	{
+		vaultItems.POST("", controllers.CreateVaultItem(cfg))
+		vaultItems.GET("", controllers.ListVaultItems(cfg))
+		vaultItems.GET("/:id", controllers.GetVaultItem(cfg))
+		vaultItems.PUT("/:id", controllers.UpdateVaultItem(cfg))
+		vaultItems.DELETE("/:id", controllers.DeleteVaultItem(cfg))
+	}
+
+	vaults := r.Group("/vaults")
+	vaults.Use(auth, mfa)
+	{
+		vaults.POST("", controllers.CreateVault(cfg))
+		vaults.GET("", controllers.ListVaults(cfg))
+		vaults.PATCH("/:id", controllers.RenameVault(cfg))
+		vaults.DELETE("/:id", controllers.DeleteVault(cfg))
+		vaults.PUT("/:id/members/:userId", controllers.SetVaultMember(cfg))
+		vaults.DELETE("/:id/members/:userId", controllers.RemoveVaultMember(cfg))
+	}
+
 	users := r.Group("/users")
-	users.Use(auth)
+	users.Use(auth, scope)
 	{
 		// users.POST("", controllers.ListUsers(cfg))
-		users.GET("", controllers.ListUsers(cfg))
-		users.GET(":id", controllers.GetUser(cfg))
-		users.PATCH(":id", controllers.UpdateUser(cfg))
-		users.DELETE(":id", controllers.DeleteUser(cfg))
+		users.GET("", role(anyRole...), controllers.ListUsers(cfg))
+		users.GET(":id", role(anyRole...), controllers.GetUser(cfg))
+		users.PATCH(":id", role(anyRole...), controllers.UpdateUser(cfg))
+		users.DELETE(":id", role(anyRole...), controllers.DeleteUser(cfg))
 	}
 
 	props := r.Group("/properties")
-	props.Use(auth) // ensure user is logged in
+	props.Use(auth, scope) // ensure user is logged in
+	{
+		props.POST("", role(propertyWriters...), controllers.CreateProperty(cfg))
+		props.GET("", role(anyRole...), controllers.ListProperties(cfg))
+		props.GET("/:id", role(anyRole...), controllers.GetProperty(cfg))
+		props.PATCH("/:id", role(propertyWriters...), controllers.UpdateProperty(cfg))
+		props.DELETE("/:id", role(propertyDeleters...), controllers.DeleteProperty(cfg))
+		props.GET("/:id/availability", role(anyRole...), controllers.GetPropertyAvailability(cfg))
+		props.PUT("/:id/rate-plan", role(propertyWriters...), controllers.SetRatePlan(cfg))
+		props.POST("/:id/quote", role(anyRole...), controllers.QuoteProperty(cfg))
+		props.POST("/:id/blackouts", role(propertyWriters...), controllers.AddPropertyBlackout(cfg))
+		props.DELETE("/:id/blackouts/:blackoutId", role(propertyWriters...), controllers.DeletePropertyBlackout(cfg))
+		props.POST("/:id/calendar-token", role(propertyWriters...), controllers.RotateCalendarToken(cfg))
+		props.POST("/:id/calendar-import", role(propertyWriters...), controllers.ImportCalendarFile(cfg))
+		props.GET("/:id/calendar-feeds", role(propertyWriters...), controllers.ListCalendarFeeds(cfg))
+		props.POST("/:id/calendar-feeds", role(propertyWriters...), controllers.CreateCalendarFeed(cfg))
+		props.POST("/:id/calendar-feeds/:feedId/sync", role(propertyWriters...), controllers.SyncCalendarFeed(cfg))
+		props.DELETE("/:id/calendar-feeds/:feedId", role(propertyWriters...), controllers.DeleteCalendarFeed(cfg))
+		props.POST("/:id/housekeepers/:userId", role(propertyWriters...), controllers.InviteHousekeeper(cfg))
+		props.DELETE("/:id/housekeepers/:userId", role(propertyWriters...), controllers.RemoveHousekeeper(cfg))
+	}
+
+	invites := r.Group("/housekeeper-invites")
+	invites.Use(auth, role(models.RoleCleaner))
 	{
-		props.POST("", controllers.CreateProperty(cfg))
-		props.GET("", controllers.ListProperties(cfg))
-		props.GET("/:id", controllers.GetProperty(cfg))
-		props.PATCH("/:id", controllers.UpdateProperty(cfg))
-		props.DELETE("/:id", controllers.DeleteProperty(cfg))
+		invites.GET("", controllers.ListHousekeeperInvites(cfg))
+		invites.POST("/:id/accept", controllers.AcceptHousekeeperInvite(cfg))
+		invites.POST("/:id/decline", controllers.DeclineHousekeeperInvite(cfg))
 	}
 
 	bookings := r.Group("/bookings")
-	bookings.Use(auth) // protect routes
+	bookings.Use(auth, scope) // protect routes
+	{
+		bookings.POST("", role(bookingWriters...), controllers.CreateBooking(cfg))
+		bookings.GET("", role(anyRole...), controllers.ListBookings(cfg))
+		bookings.GET("/:id", role(anyRole...), controllers.GetBooking(cfg))
+		bookings.PATCH("/:id", role(bookingWriters...), controllers.UpdateBooking(cfg))
+		bookings.DELETE("/:id", role(bookingDeleters...), controllers.DeleteBooking(cfg))
+	}
+
+	tasks := r.Group("/tasks")
+	tasks.Use(auth, scope)
 	{
-		bookings.POST("", controllers.CreateBooking(cfg))
-		bookings.GET("", controllers.ListBookings(cfg))
-		bookings.GET("/:id", controllers.GetBooking(cfg))
-		bookings.PATCH("/:id", controllers.UpdateBooking(cfg))
-		bookings.DELETE("/:id", controllers.DeleteBooking(cfg))
+		tasks.GET("", role(anyRole...), controllers.ListCleaningTasks(cfg))
+		tasks.PATCH("/:id/status", role(taskWorkers...), controllers.UpdateCleaningTaskStatus(cfg))
+		tasks.PUT("/:id/assignee", role(propertyWriters...), controllers.AssignCleaningTask(cfg))
 	}
 
 	reports := r.Group("/housekeeper-reports")
-	reports.Use(auth)
+	reports.Use(auth, scope)
 
 	{
-		reports.POST("", controllers.CreateHousekeeperReport(cfg)) 
-		reports.GET("", controllers.ListHousekeeperReports(cfg))   
-		reports.GET("/:id", controllers.GetHousekeeperReport(cfg))   
-		reports.PATCH("/:id", controllers.UpdateHousekeeperReport(cfg))   
-		reports.DELETE("/:id", controllers.DeleteHousekeeperReport(cfg))  
+		reports.POST("", role(reportWriters...), controllers.CreateHousekeeperReport(cfg))
+		reports.GET("", role(anyRole...), controllers.ListHousekeeperReports(cfg))
+		reports.GET("/:id", role(anyRole...), controllers.GetHousekeeperReport(cfg))
+		reports.PATCH("/:id", role(reportWriters...), controllers.UpdateHousekeeperReport(cfg))
+		reports.DELETE("/:id", role(reportDeleters...), controllers.DeleteHousekeeperReport(cfg))
 	}
 
 	notifs := r.Group("/notifications")
@@ -0,0 +1,223 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/phillip/backend/config"
+)
+
+// AttemptCounter is the state of one throttled key, e.g. "otp-verify:user:<id>"
+type AttemptCounter struct {
+	Count       int       `bson:"count"`
+	WindowStart time.Time `bson:"window_start"`
+	LockedUntil time.Time `bson:"locked_until,omitempty"`
+}
+
+// AttemptStore keeps attempt counters. Implementations must make Hit atomic
+// so that concurrent requests, on any instance, each see their own count.
+type AttemptStore interface {
+	// Get returns the key's counter (zero if there is none)
+	Get(ctx context.Context, key string) (AttemptCounter, error)
+	// Hit counts an attempt, starting a new window when the last one is over
+	Hit(ctx context.Context, key string, window time.Duration) (AttemptCounter, error)
+	// Lock refuses attempts on key until the given time
+	Lock(ctx context.Context, key string, until time.Time) error
+	// Reset forgets the key
+	Reset(ctx context.Context, key string) error
+}
+
+var (
+	memoryAttemptsOnce sync.Once
+	memoryAttempts     *memoryAttemptStore
+)
+
+// Attempts returns the store selected by ATTEMPT_STORE. "memory" only
+// suits a single instance, since each process counts on its own.
+func Attempts(cfg *config.Config) (AttemptStore, error) {
+	switch cfg.AttemptStore {
+	case "", "mongo":
+		return mongoAttemptStore{col: cfg.MongoClient.Database(cfg.DBName).Collection("rate_limits")}, nil
+	case "memory":
+		memoryAttemptsOnce.Do(func() { memoryAttempts = &memoryAttemptStore{counters: map[string]memoryCounter{}} })
+		return memoryAttempts, nil
+	}
+	return nil, fmt.Errorf("unknown attempt store %q", cfg.AttemptStore)
+}
+
+// Throttle limits attempts per subject (an account, an IP address, ...).
+// Once Limit attempts are counted within Window, the subject is locked for
+// Lockout, doubling with every further attempt in the window up to MaxLockout.
+type Throttle struct {
+	Name       string
+	Limit      int
+	Window     time.Duration
+	Lockout    time.Duration
+	MaxLockout time.Duration
+}
+
+// Check returns how long subject must wait before trying again (0 if it may)
+func (t Throttle) Check(ctx context.Context, store AttemptStore, subject string) (time.Duration, error) {
+	counter, err := store.Get(ctx, t.key(subject))
+	if err != nil {
+		return 0, err
+	}
+	if wait := time.Until(counter.LockedUntil); wait > 0 {
+		return wait, nil
+	}
+	return 0, nil
+}
+
+// Hit counts an attempt (a failed code, or an issued one) and returns the
+// lockout it triggered, if any
+func (t Throttle) Hit(ctx context.Context, store AttemptStore, subject string) (time.Duration, error) {
+	key := t.key(subject)
+	counter, err := store.Hit(ctx, key, t.Window)
+	if err != nil {
+		return 0, err
+	}
+	if counter.Count < t.Limit {
+		return 0, nil
+	}
+
+	lockout := t.Lockout
+	for i := t.Limit; i < counter.Count && lockout < t.MaxLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > t.MaxLockout {
+		lockout = t.MaxLockout
+	}
+	if err := store.Lock(ctx, key, time.Now().Add(lockout)); err != nil {
+		return 0, err
+	}
+	return lockout, nil
+}
+
+// Reset clears subject's counter, e.g. after a successful login
+func (t Throttle) Reset(ctx context.Context, store AttemptStore, subject string) error {
+	return store.Reset(ctx, t.key(subject))
+}
+
+func (t Throttle) key(subject string) string {
+	return t.Name + ":" + subject
+}
+
+// mongoAttemptStore keeps counters in the rate_limits collection, one document
+// per key; a TTL index on expires_at removes idle ones
+type mongoAttemptStore struct {
+	col *mongo.Collection
+}
+
+func (m mongoAttemptStore) Get(ctx context.Context, key string) (AttemptCounter, error) {
+	var counter AttemptCounter
+	err := m.col.FindOne(ctx, bson.M{"_id": key}).Decode(&counter)
+	if err == mongo.ErrNoDocuments {
+		return counter, nil
+	}
+	return counter, err
+}
+
+func (m mongoAttemptStore) Hit(ctx context.Context, key string, window time.Duration) (AttemptCounter, error) {
+	now := time.Now()
+	inWindow := bson.M{"$gt": bson.A{"$window_start", now.Add(-window)}}
+
+	// One pipeline update, so the window check and increment are atomic
+	var counter AttemptCounter
+	err := m.col.FindOneAndUpdate(ctx,
+		bson.M{"_id": key},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			"count":        bson.M{"$cond": bson.A{inWindow, bson.M{"$add": bson.A{"$count", 1}}, 1}},
+			"window_start": bson.M{"$cond": bson.A{inWindow, "$window_start", now}},
+			"expires_at":   bson.M{"$max": bson.A{"$locked_until", now.Add(window)}},
+		}}}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter)
+	return counter, err
+}
+
+func (m mongoAttemptStore) Lock(ctx context.Context, key string, until time.Time) error {
+	_, err := m.col.UpdateOne(ctx,
+		bson.M{"_id": key},
+		bson.M{
+			"$set": bson.M{"locked_until": until},
+			"$max": bson.M{"expires_at": until},
+		},
+	)
+	return err
+}
+
+func (m mongoAttemptStore) Reset(ctx context.Context, key string) error {
+	_, err := m.col.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
+
+// memoryAttemptStore is a process-local AttemptStore
+type memoryAttemptStore struct {
+	mu       sync.Mutex
+	counters map[string]memoryCounter
+}
+
+type memoryCounter struct {
+	AttemptCounter
+	expiresAt time.Time
+}
+
+func (m *memoryAttemptStore) Get(_ context.Context, key string) (AttemptCounter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.live(key).AttemptCounter, nil
+}
+
+func (m *memoryAttemptStore) Hit(_ context.Context, key string, window time.Duration) (AttemptCounter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	c := m.live(key)
+	if c.WindowStart.After(now.Add(-window)) {
+		c.Count++
+	} else {
+		c.Count, c.WindowStart = 1, now
+	}
+	if exp := now.Add(window); exp.After(c.expiresAt) {
+		c.expiresAt = exp
+	}
+	m.counters[key] = c
+	return c.AttemptCounter, nil
+}
+
+func (m *memoryAttemptStore) Lock(_ context.Context, key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c := m.live(key)
+	c.LockedUntil = until
+	if until.After(c.expiresAt) {
+		c.expiresAt = until
+	}
+	m.counters[key] = c
+	return nil
+}
+
+func (m *memoryAttemptStore) Reset(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.counters, key)
+	return nil
+}
+
+// live returns key's counter, dropping it once expired; callers hold mu
+func (m *memoryAttemptStore) live(key string) memoryCounter {
+	c, ok := m.counters[key]
+	if ok && time.Now().After(c.expiresAt) {
+		delete(m.counters, key)
+		return memoryCounter{}
+	}
+	return c
+}
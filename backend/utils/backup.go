@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+)
+
+const (
+	backupFormat     = "unit-wise-credentials"
+	backupVersion    = 1
+	backupKDF        = "pbkdf2-sha256"
+	backupIterations = 600000
+
+	// Archives from older or newer exports may differ, but an uploaded file
+	// can't ask the server for much more work than an export of its own
+	minBackupIterations = 100000
+	maxBackupIterations = 2 * backupIterations
+
+	// MinBackupPassphrase is the shortest passphrase accepted for exports
+	MinBackupPassphrase = 12
+)
+
+// BackupArchive is the passphrase-encrypted export file. The key is derived
+// from the passphrase with PBKDF2-SHA256; the payload is AES-256-GCM with the
+// archive header authenticated as additional data.
+type BackupArchive struct {
+	Format     string `json:"format"`
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+var ErrBadPassphrase = errors.New("wrong passphrase or corrupted archive")
+
+// SealBackup encrypts payload (JSON) under passphrase
+func SealBackup(passphrase string, payload []byte) (BackupArchive, error) {
+	a := BackupArchive{
+		Format:     backupFormat,
+		Version:    backupVersion,
+		KDF:        backupKDF,
+		Iterations: backupIterations,
+		Salt:       make([]byte, 16),
+	}
+	if _, err := rand.Read(a.Salt); err != nil {
+		return a, err
+	}
+
+	gcm, err := a.cipher(passphrase)
+	if err != nil {
+		return a, err
+	}
+	a.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(a.Nonce); err != nil {
+		return a, err
+	}
+	a.Ciphertext = gcm.Seal(nil, a.Nonce, payload, a.header())
+	return a, nil
+}
+
+// OpenBackup decrypts an archive produced by SealBackup
+func OpenBackup(passphrase string, raw []byte) ([]byte, error) {
+	var a BackupArchive
+	if err := json.Unmarshal(raw, &a); err != nil {
+		return nil, errors.New("not a credentials backup file")
+	}
+	if a.Format != backupFormat || a.Version != backupVersion || a.KDF != backupKDF {
+		return nil, errors.New("unsupported backup format")
+	}
+	// Bound the work an uploaded file can ask for
+	if a.Iterations < minBackupIterations || a.Iterations > maxBackupIterations || len(a.Salt) < 16 {
+		return nil, errors.New("unsupported backup parameters")
+	}
+
+	gcm, err := a.cipher(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if len(a.Nonce) != gcm.NonceSize() {
+		return nil, ErrBadPassphrase
+	}
+	payload, err := gcm.Open(nil, a.Nonce, a.Ciphertext, a.header())
+	if err != nil {
+		return nil, ErrBadPassphrase
+	}
+	return payload, nil
+}
+
+func (a BackupArchive) cipher(passphrase string) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, a.Salt, a.Iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// header binds the KDF parameters to the ciphertext
+func (a BackupArchive) header() []byte {
+	h, _ := json.Marshal(struct {
+		Format     string `json:"format"`
+		Version    int    `json:"version"`
+		KDF        string `json:"kdf"`
+		Iterations int    `json:"iterations"`
+		Salt       []byte `json:"salt"`
+	}{a.Format, a.Version, a.KDF, a.Iterations, a.Salt})
+	return h
+}
@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestBackupRoundTrip(t *testing.T) {
+	payload := []byte(`{"credentials":[{"site_name":"Airbnb","password":"hunter2"}]}`)
+	a, err := SealBackup("correct horse battery", payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := json.Marshal(a)
+
+	got, err := OpenBackup("correct horse battery", raw)
+	if err != nil || !bytes.Equal(got, payload) {
+		t.Fatalf("OpenBackup = %s, %v", got, err)
+	}
+	if _, err := OpenBackup("wrong horse battery", raw); !errors.Is(err, ErrBadPassphrase) {
+		t.Fatalf("wrong passphrase: got %v", err)
+	}
+}
+
+func TestOpenBackupBoundsIterations(t *testing.T) {
+	a, err := SealBackup("correct horse battery", []byte(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{minBackupIterations - 1, maxBackupIterations + 1, 1 << 30} {
+		a.Iterations = n
+		raw, _ := json.Marshal(a)
+		if _, err := OpenBackup("correct horse battery", raw); err == nil || errors.Is(err, ErrBadPassphrase) {
+			t.Errorf("%d iterations: got %v, want unsupported parameters", n, err)
+		}
+	}
+}
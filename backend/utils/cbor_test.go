@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestDecodeCBOR(t *testing.T) {
+	// Vectors from RFC 8949 Appendix A
+	cases := []struct {
+		hex  string
+		want interface{}
+	}{
+		{"00", int64(0)},
+		{"17", int64(23)},
+		{"1818", int64(24)},
+		{"1903e8", int64(1000)},
+		{"1a000f4240", int64(1000000)},
+		{"1b000000e8d4a51000", int64(1000000000000)},
+		{"20", int64(-1)},
+		{"3903e7", int64(-1000)},
+		{"f4", false},
+		{"f5", true},
+		{"f6", nil},
+		{"4401020304", []byte{1, 2, 3, 4}},
+		{"6449455446", "IETF"},
+		{"c074323031332d30332d32315432303a30343a30305a", "2013-03-21T20:04:00Z"},
+	}
+	for _, tc := range cases {
+		in, _ := hex.DecodeString(tc.hex)
+		got, rest, err := decodeCBOR(in)
+		if err != nil || len(rest) != 0 {
+			t.Errorf("decodeCBOR(%s) = %v, rest %x, %v", tc.hex, got, rest, err)
+			continue
+		}
+		if b, ok := tc.want.([]byte); ok {
+			if !bytes.Equal(got.([]byte), b) {
+				t.Errorf("decodeCBOR(%s) = %x, want %x", tc.hex, got, b)
+			}
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("decodeCBOR(%s) = %#v, want %#v", tc.hex, got, tc.want)
+		}
+	}
+}
+
+func TestDecodeCBORContainers(t *testing.T) {
+	// {"a": 1, "b": [2, 3]}
+	in, _ := hex.DecodeString("a26161016162820203")
+	v, rest, err := decodeCBOR(append(in, 0xff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(rest, []byte{0xff}) {
+		t.Errorf("trailing bytes = %x, want ff", rest)
+	}
+	m := v.(map[interface{}]interface{})
+	if m["a"] != int64(1) {
+		t.Errorf(`m["a"] = %#v`, m["a"])
+	}
+	if arr := m["b"].([]interface{}); len(arr) != 2 || arr[0] != int64(2) || arr[1] != int64(3) {
+		t.Errorf(`m["b"] = %#v`, m["b"])
+	}
+}
+
+func TestEncodeCBORRoundTrip(t *testing.T) {
+	in := map[interface{}]interface{}{1: 2, 3: COSEAlgRS256, -1: []byte{0xde, 0xad}, "fmt": "none"}
+	enc, err := encodeCBOR(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, rest, err := decodeCBOR(enc)
+	if err != nil || len(rest) != 0 {
+		t.Fatalf("decode: %v, rest %x", err, rest)
+	}
+	m := v.(map[interface{}]interface{})
+	if m[int64(1)] != int64(2) || m[int64(3)] != int64(COSEAlgRS256) || m["fmt"] != "none" ||
+		!bytes.Equal(m[int64(-1)].([]byte), []byte{0xde, 0xad}) {
+		t.Errorf("round trip = %#v", m)
+	}
+}
+
+func TestDecodeCBORMalformed(t *testing.T) {
+	cases := map[string]string{
+		"empty":                     "",
+		"truncated uint16":          "19 03",
+		"truncated byte string":     "44 0102",
+		"truncated text":            "64 4945",
+		"array longer than input":   "9b 00000000ffffffff",
+		"map longer than input":     "bb 00000000ffffffff",
+		"byte string over int size": "5b ffffffffffffffff 00",
+		"missing map value":         "a1 01",
+		"indefinite byte string":    "5f 4101 ff",
+		"indefinite array":          "9f 01 ff",
+		"reserved additional info":  "1c",
+		"float":                     "fb 3ff199999999999a",
+		"undefined":                 "f7",
+		"negative int overflow":     "3b ffffffffffffffff",
+		"uint overflow":             "1b ffffffffffffffff",
+		"array map key":             "a1 8101 01",
+		"duplicate map key":         "a2 01 01 01 02",
+		"tag without item":          "c0",
+	}
+	for name, h := range cases {
+		in, err := hex.DecodeString(strings.ReplaceAll(h, " ", ""))
+		if err != nil {
+			t.Fatalf("%s: bad test hex: %v", name, err)
+		}
+		if v, _, err := decodeCBOR(in); err == nil {
+			t.Errorf("%s: decoded %#v, want error", name, v)
+		}
+	}
+}
+
+func TestDecodeCBORDeeplyNested(t *testing.T) {
+	// Just inside the limit decodes
+	ok := append(bytes.Repeat([]byte{0x81}, cborMaxDepth), 0x00)
+	if _, _, err := decodeCBOR(ok); err != nil {
+		t.Fatalf("depth %d: %v", cborMaxDepth, err)
+	}
+
+	// Arrays, maps and tags all count towards the limit
+	for name, wrap := range map[string][]byte{
+		"arrays": {0x81},
+		"maps":   {0xa1, 0x00},
+		"tags":   {0xc0},
+	} {
+		in := append(bytes.Repeat(wrap, 100000), 0x00)
+		_, _, err := decodeCBOR(in)
+		if err == nil || !strings.Contains(err.Error(), "nested too deeply") {
+			t.Errorf("%s: got %v, want nesting error", name, err)
+		}
+	}
+}
+
+func TestParseCOSEKeyRejects(t *testing.T) {
+	cases := map[string]map[interface{}]interface{}{
+		"unsupported alg":      {1: 2, 3: -35, -1: 2, -2: make([]byte, 48), -3: make([]byte, 48)},
+		"ed25519 short key":    {1: 1, 3: COSEAlgEdDSA, -1: 6, -2: make([]byte, 31)},
+		"ed25519 wrong curve":  {1: 1, 3: COSEAlgEdDSA, -1: 7, -2: make([]byte, 32)},
+		"p256 point off curve": {1: 2, 3: COSEAlgES256, -1: 1, -2: bytes.Repeat([]byte{1}, 32), -3: bytes.Repeat([]byte{2}, 32)},
+		"rsa 1024-bit":         {1: 3, 3: COSEAlgRS256, -1: make([]byte, 128), -2: []byte{1, 0, 1}},
+		"kty/alg mismatch":     {1: 1, 3: COSEAlgES256, -1: 1, -2: make([]byte, 32), -3: make([]byte, 32)},
+	}
+	for name, key := range cases {
+		raw, err := encodeCBOR(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := parseCOSEKey(raw); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+	if _, _, err := parseCOSEKey([]byte{0x81, 0x01}); err == nil {
+		t.Error("accepted an array as a COSE key")
+	}
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/phillip/backend/config"
+	"github.com/phillip/backend/models"
+)
+
+// RecordCredentialEvent appends an audit entry for an action the requester
+// took on a credential
+func RecordCredentialEvent(cfg *config.Config, c *gin.Context, cred models.Credential, action string) error {
+	return RecordCredentialEvents(cfg, c, []models.Credential{cred}, action)
+}
+
+// RecordCredentialEvents appends one audit entry per credential, for bulk
+// actions such as import and export
+func RecordCredentialEvents(cfg *config.Config, c *gin.Context, creds []models.Credential, action string) error {
+	if len(creds) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	actorID, _ := primitive.ObjectIDFromHex(c.GetString("user_id"))
+	now := time.Now()
+
+	docs := make([]interface{}, 0, len(creds))
+	for _, cred := range creds {
+		docs = append(docs, models.CredentialAudit{
+			ID:           primitive.NewObjectID(),
+			CredentialID: cred.ID,
+			OwnerID:      cred.UserID,
+			PropertyID:   cred.PropertyID,
+			VaultID:      cred.VaultID,
+			ActorID:      actorID,
+			Action:       action,
+			IP:           c.ClientIP(),
+			UserAgent:    c.Request.UserAgent(),
+			CreatedAt:    now,
+		})
+	}
+
+	_, err := cfg.MongoClient.Database(cfg.DBName).Collection("credential_audit").InsertMany(ctx, docs)
+	return err
+}
@@ -0,0 +1,174 @@
+package utils
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// ImportedCredential is one login read from an export file. Row is the
+// 1-based line in the source file (the header is row 1).
+type ImportedCredential struct {
+	Row      int    `json:"row"`
+	SiteName string `json:"site_name"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	LoginURL string `json:"login_url"`
+	Notes    string `json:"notes"`
+	Category string `json:"category"`
+	TOTP     string `json:"totp,omitempty"`
+}
+
+// SkippedRow is a CSV row that could not be imported
+type SkippedRow struct {
+	Row    int    `json:"row"`
+	Reason string `json:"reason"`
+}
+
+// csvFormat maps our fields onto one password manager's CSV columns
+type csvFormat struct {
+	name     string
+	detect   []string // columns that identify the format
+	site     string
+	username string
+	password string
+	url      string
+	notes    string
+	category string
+	totp     string
+	kind     string // Bitwarden's item type column; only "login" rows are imported
+}
+
+var csvFormats = []csvFormat{
+	{
+		name:     "bitwarden",
+		detect:   []string{"login_uri", "login_username", "login_password"},
+		site:     "name",
+		username: "login_username",
+		password: "login_password",
+		url:      "login_uri",
+		notes:    "notes",
+		category: "folder",
+		totp:     "login_totp",
+		kind:     "type",
+	},
+	{
+		name:     "firefox",
+		detect:   []string{"url", "username", "password", "httprealm"},
+		username: "username",
+		password: "password",
+		url:      "url",
+	},
+	{
+		name:     "chrome",
+		detect:   []string{"name", "url", "username", "password"},
+		site:     "name",
+		username: "username",
+		password: "password",
+		url:      "url",
+		notes:    "note",
+	},
+}
+
+// ParseCredentialCSV reads a Chrome, Firefox or Bitwarden password export,
+// detected from its header. Rows without a password, and non-login Bitwarden
+// items, are returned as skipped rather than failing the whole file.
+func ParseCredentialCSV(r io.Reader, maxRows int) (format string, creds []ImportedCredential, skipped []SkippedRow, err error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return "", nil, nil, errors.New("could not read CSV header")
+	}
+	cols := map[string]int{}
+	for i, h := range header {
+		cols[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))] = i
+	}
+
+	var f *csvFormat
+	for i := range csvFormats {
+		if hasColumns(cols, csvFormats[i].detect) {
+			f = &csvFormats[i]
+			break
+		}
+	}
+	if f == nil {
+		return "", nil, nil, errors.New("unrecognised CSV: expected a Chrome, Firefox or Bitwarden export")
+	}
+
+	get := func(rec []string, col string) string {
+		if col == "" {
+			return ""
+		}
+		i, ok := cols[col]
+		if !ok || i >= len(rec) {
+			return ""
+		}
+		return strings.TrimSpace(rec[i])
+	}
+
+	for row := 2; ; row++ {
+		rec, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return f.name, nil, nil, fmt.Errorf("row %d: %v", row, err)
+		}
+		if len(creds)+len(skipped) >= maxRows {
+			return f.name, nil, nil, fmt.Errorf("file has more than %d rows", maxRows)
+		}
+
+		if kind := get(rec, f.kind); f.kind != "" && kind != "" && kind != "login" {
+			skipped = append(skipped, SkippedRow{Row: row, Reason: "not a login item (" + kind + ")"})
+			continue
+		}
+
+		cred := ImportedCredential{
+			Row:      row,
+			SiteName: get(rec, f.site),
+			Username: get(rec, f.username),
+			Password: get(rec, f.password),
+			LoginURL: get(rec, f.url),
+			Notes:    get(rec, f.notes),
+			Category: get(rec, f.category),
+			TOTP:     get(rec, f.totp),
+		}
+		if cred.SiteName == "" {
+			cred.SiteName = SiteFromURL(cred.LoginURL)
+		}
+
+		switch {
+		case cred.Password == "":
+			skipped = append(skipped, SkippedRow{Row: row, Reason: "missing password"})
+		case cred.SiteName == "":
+			skipped = append(skipped, SkippedRow{Row: row, Reason: "missing site name and url"})
+		default:
+			creds = append(creds, cred)
+		}
+	}
+
+	return f.name, creds, skipped, nil
+}
+
+// SiteFromURL returns the host of a login URL without "www.", or "" if there is none
+func SiteFromURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+func hasColumns(cols map[string]int, names []string) bool {
+	for _, n := range names {
+		if _, ok := cols[n]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func parseCSVFixture(t *testing.T, name string) (string, []ImportedCredential, []SkippedRow) {
+	t.Helper()
+	f, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	format, creds, skipped, err := ParseCredentialCSV(f, 100)
+	if err != nil {
+		t.Fatalf("ParseCredentialCSV(%s): %v", name, err)
+	}
+	return format, creds, skipped
+}
+
+func checkImported(t *testing.T, got, want []ImportedCredential) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("imported %d credentials, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("credential %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func checkSkipped(t *testing.T, got, want []SkippedRow) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("skipped %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i].Row != want[i].Row || !strings.Contains(got[i].Reason, want[i].Reason) {
+			t.Errorf("skipped %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseCredentialCSVChrome(t *testing.T) {
+	format, creds, skipped := parseCSVFixture(t, "chrome_passwords.csv")
+	if format != "chrome" {
+		t.Fatalf("format = %q, want chrome", format)
+	}
+	checkImported(t, creds, []ImportedCredential{
+		{Row: 2, SiteName: "Airbnb", Username: "host@example.com", Password: "s3cret-airbnb", LoginURL: "https://www.airbnb.com/login", Notes: "Main listing account"},
+		// No name: the site comes from the URL
+		{Row: 3, SiteName: "admin.booking.com", Username: "frontdesk", Password: "s3cret-booking", LoginURL: "https://admin.booking.com/hotel/"},
+		// A name is enough without a URL
+		{Row: 4, SiteName: "Router", Username: "admin", Password: "s3cret-router"},
+	})
+	checkSkipped(t, skipped, []SkippedRow{{Row: 5, Reason: "missing password"}})
+}
+
+func TestParseCredentialCSVFirefox(t *testing.T) {
+	format, creds, skipped := parseCSVFixture(t, "firefox_passwords.csv")
+	if format != "firefox" {
+		t.Fatalf("format = %q, want firefox", format)
+	}
+	// Firefox has no name column, so every site comes from the URL
+	checkImported(t, creds, []ImportedCredential{
+		{Row: 2, SiteName: "vrbo.com", Username: "host@example.com", Password: "s3cret-vrbo", LoginURL: "https://www.vrbo.com"},
+		{Row: 3, SiteName: "cameras.example.net", Username: "viewer", Password: "s3cret-cam", LoginURL: "https://cameras.example.net:8443"},
+	})
+	checkSkipped(t, skipped, []SkippedRow{{Row: 4, Reason: "missing password"}})
+}
+
+func TestParseCredentialCSVBitwarden(t *testing.T) {
+	format, creds, skipped := parseCSVFixture(t, "bitwarden_export.csv")
+	if format != "bitwarden" {
+		t.Fatalf("format = %q, want bitwarden", format)
+	}
+	checkImported(t, creds, []ImportedCredential{
+		{
+			Row: 2, SiteName: "Airbnb", Username: "host@example.com", Password: "s3cret-airbnb",
+			LoginURL: "https://www.airbnb.com/login", Notes: "Main listing account", Category: "Listings",
+			TOTP: "otpauth://totp/Airbnb:host@example.com?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+		},
+		{Row: 3, SiteName: "booking.com", Username: "frontdesk", Password: "s3cret-booking", LoginURL: "https://www.booking.com/"},
+	})
+	checkSkipped(t, skipped, []SkippedRow{
+		{Row: 4, Reason: "not a login item (note)"},
+		{Row: 5, Reason: "not a login item (card)"},
+		{Row: 6, Reason: "missing password"},
+	})
+}
+
+func TestParseCredentialCSVHeaders(t *testing.T) {
+	cases := []struct {
+		name, csv, format string
+	}{
+		{"byte order mark", "\ufeffname,url,username,password\nAirbnb,https://airbnb.com,host,pw\n", "chrome"},
+		{"upper case and spaces", "Name , URL,Username,Password\nAirbnb,https://airbnb.com,host,pw\n", "chrome"},
+		{"columns in another order", "password,username,url,name\npw,host,https://airbnb.com,Airbnb\n", "chrome"},
+		{"firefox httpRealm wins over chrome", "name,url,username,password,httpRealm\nAirbnb,https://airbnb.com,host,pw,\n", "firefox"},
+	}
+	for _, tc := range cases {
+		format, creds, _, err := ParseCredentialCSV(strings.NewReader(tc.csv), 10)
+		if err != nil || format != tc.format || len(creds) != 1 || creds[0].Password != "pw" || creds[0].Username != "host" {
+			t.Errorf("%s: format %q, creds %+v, err %v", tc.name, format, creds, err)
+		}
+	}
+
+	for name, in := range map[string]string{
+		"empty file":      "",
+		"unknown columns": "site,login,secret\nAirbnb,host,pw\n",
+		"missing column":  "name,url,password\nAirbnb,https://airbnb.com,pw\n",
+	} {
+		if _, _, _, err := ParseCredentialCSV(strings.NewReader(in), 10); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+func TestParseCredentialCSVMissingSite(t *testing.T) {
+	in := "name,url,username,password\n,,host,pw\n,not a url,host,pw\n"
+	_, creds, skipped, err := ParseCredentialCSV(strings.NewReader(in), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(creds) != 0 {
+		t.Errorf("imported %+v", creds)
+	}
+	checkSkipped(t, skipped, []SkippedRow{{Row: 2, Reason: "missing site name"}, {Row: 3, Reason: "missing site name"}})
+}
+
+func TestParseCredentialCSVMaxRows(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("name,url,username,password\n")
+	for i := 0; i < 3; i++ {
+		b.WriteString("Airbnb,https://airbnb.com,host,pw\n")
+	}
+	// Skipped rows count towards the cap too
+	b.WriteString("Airbnb,https://airbnb.com,host,\n")
+
+	if _, creds, skipped, err := ParseCredentialCSV(strings.NewReader(b.String()), 4); err != nil || len(creds) != 3 || len(skipped) != 1 {
+		t.Fatalf("at the cap: %d imported, %d skipped, %v", len(creds), len(skipped), err)
+	}
+	_, _, _, err := ParseCredentialCSV(strings.NewReader(b.String()), 3)
+	if err == nil || !strings.Contains(err.Error(), "more than 3 rows") {
+		t.Fatalf("over the cap: got %v", err)
+	}
+}
+
+func TestSiteFromURL(t *testing.T) {
+	cases := map[string]string{
+		"https://www.Airbnb.com/login":    "airbnb.com",
+		"https://admin.booking.com:443/x": "admin.booking.com",
+		"http://192.168.1.1/":             "192.168.1.1",
+		"airbnb.com":                      "", // no scheme, so no host
+		"":                                "",
+		"://bad":                          "",
+	}
+	for in, want := range cases {
+		if got := SiteFromURL(in); got != want {
+			t.Errorf("SiteFromURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"io"
 )
 
+// Encrypt is the legacy AES-CFB format. It is kept so old records can be read
+// (see DecryptSecret); new values are written with EncryptSecret.
 func Encrypt(aesKey []byte, plaintext string) (string, error) {
 	if len(aesKey) != 32 {
 		return "", errors.New("aes key must be 32 bytes")
@@ -28,6 +30,7 @@ func Encrypt(aesKey []byte, plaintext string) (string, error) {
 	return base64.URLEncoding.EncodeToString(ct), nil
 }
 
+// Decrypt opens a legacy AES-CFB value written by Encrypt
 func Decrypt(aesKey []byte, encoded string) (string, error) {
 	if len(aesKey) != 32 {
 		return "", errors.New("aes key must be 32 bytes")
@@ -0,0 +1,207 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/phillip/backend/config"
+	"github.com/phillip/backend/models"
+)
+
+// Values sealed with a data key look like "u1:<base64url(nonce | ciphertext+tag)>".
+// The owner's ID is authenticated as additional data, so a value copied onto
+// another owner's record fails to open.
+const dataKeyVersion = "u1"
+
+// DataKey is an owner's unwrapped data key, good for one request
+type DataKey struct {
+	ownerID primitive.ObjectID
+	key     []byte
+	cfg     *config.Config
+}
+
+// UserDataKey loads and unwraps the user's data key, creating it on first use
+// (users registered before per-user keys get theirs lazily)
+func UserDataKey(ctx context.Context, cfg *config.Config, userID primitive.ObjectID) (*DataKey, error) {
+	return loadDataKey(ctx, cfg, userID, models.DataKeyOwnerUser)
+}
+
+// DataKeys caches unwrapped data keys for a request that touches several
+// owners, such as listing credentials across personal, property and shared vaults
+type DataKeys struct {
+	cfg  *config.Config
+	keys map[primitive.ObjectID]*DataKey
+}
+
+func NewDataKeys(cfg *config.Config) *DataKeys {
+	return &DataKeys{cfg: cfg, keys: map[primitive.ObjectID]*DataKey{}}
+}
+
+// For returns the owner's data key, creating it on first use
+func (d *DataKeys) For(ctx context.Context, ownerID primitive.ObjectID, ownerType string) (*DataKey, error) {
+	if k, ok := d.keys[ownerID]; ok {
+		return k, nil
+	}
+	k, err := loadDataKey(ctx, d.cfg, ownerID, ownerType)
+	if err != nil {
+		return nil, err
+	}
+	d.keys[ownerID] = k
+	return k, nil
+}
+
+// Seal encrypts plaintext for the key's owner
+func (k *DataKey) Seal(plaintext string) (string, error) {
+	gcm, err := newGCM(k.key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), k.aad())
+	return dataKeyVersion + ":" + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value sealed by Seal. Values from before per-user keys
+// (v2 or legacy) are opened with the shared keys so nothing becomes unreadable.
+func (k *DataKey) Open(encoded string) (string, error) {
+	rest, ok := strings.CutPrefix(encoded, dataKeyVersion+":")
+	if !ok {
+		return DecryptSecret(k.cfg, encoded)
+	}
+	gcm, err := newGCM(k.key)
+	if err != nil {
+		return "", err
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(rest)
+	if err != nil || len(raw) < gcm.NonceSize()+gcm.Overhead() {
+		return "", ErrMalformedSecret
+	}
+	pt, err := gcm.Open(nil, raw[:gcm.NonceSize()], raw[gcm.NonceSize():], k.aad())
+	if err != nil {
+		return "", ErrSecretTampered
+	}
+	return string(pt), nil
+}
+
+func (k *DataKey) aad() []byte {
+	return []byte(dataKeyVersion + ":" + k.ownerID.Hex())
+}
+
+func loadDataKey(ctx context.Context, cfg *config.Config, ownerID primitive.ObjectID, ownerType string) (*DataKey, error) {
+	provider, err := MasterKeys(cfg)
+	if err != nil {
+		return nil, err
+	}
+	col := cfg.MongoClient.Database(cfg.DBName).Collection("data_keys")
+
+	var stored models.DataKey
+	err = col.FindOne(ctx, bson.M{"_id": ownerID}).Decode(&stored)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		stored, err = createDataKey(ctx, col, provider, ownerID, ownerType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if stored.Provider != provider.Name() {
+		return nil, errors.New("data key was wrapped by provider " + stored.Provider)
+	}
+
+	key, err := provider.Unwrap(ctx, ownerID, stored.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	return &DataKey{ownerID: ownerID, key: key, cfg: cfg}, nil
+}
+
+// createDataKey stores a fresh wrapped key. If a concurrent request won the
+// race, its key is returned instead so the owner still has exactly one.
+func createDataKey(ctx context.Context, col *mongo.Collection, provider MasterKeyProvider, ownerID primitive.ObjectID, ownerType string) (models.DataKey, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return models.DataKey{}, err
+	}
+	wrapped, err := provider.Wrap(ctx, ownerID, key)
+	if err != nil {
+		return models.DataKey{}, err
+	}
+
+	now := time.Now()
+	stored := models.DataKey{
+		ID:          ownerID,
+		OwnerType:   ownerType,
+		Provider:    provider.Name(),
+		MasterKeyID: provider.ActiveKeyID(),
+		WrappedKey:  wrapped,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if _, err := col.InsertOne(ctx, stored); err != nil {
+		if !mongo.IsDuplicateKeyError(err) {
+			return models.DataKey{}, err
+		}
+		err = col.FindOne(ctx, bson.M{"_id": ownerID}).Decode(&stored)
+		return stored, err
+	}
+	return stored, nil
+}
+
+// RewrapDataKeys re-wraps data keys that are under a retired master key with
+// the active one. The data keys themselves, and so every value sealed with
+// them, are unchanged. It returns how many keys were re-wrapped.
+func RewrapDataKeys(ctx context.Context, cfg *config.Config, limit int64) (int, error) {
+	provider, err := MasterKeys(cfg)
+	if err != nil {
+		return 0, err
+	}
+	col := cfg.MongoClient.Database(cfg.DBName).Collection("data_keys")
+
+	cursor, err := col.Find(ctx, bson.M{
+		"provider":      provider.Name(),
+		"master_key_id": bson.M{"$ne": provider.ActiveKeyID()},
+	}, options.Find().SetLimit(limit))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	rewrapped := 0
+	for cursor.Next(ctx) {
+		var stored models.DataKey
+		if err := cursor.Decode(&stored); err != nil {
+			return rewrapped, err
+		}
+		key, err := provider.Unwrap(ctx, stored.ID, stored.WrappedKey)
+		if err != nil {
+			return rewrapped, err
+		}
+		wrapped, err := provider.Wrap(ctx, stored.ID, key)
+		if err != nil {
+			return rewrapped, err
+		}
+		_, err = col.UpdateOne(ctx,
+			bson.M{"_id": stored.ID, "wrapped_key": stored.WrappedKey},
+			bson.M{"$set": bson.M{
+				"wrapped_key":   wrapped,
+				"master_key_id": provider.ActiveKeyID(),
+				"updated_at":    time.Now(),
+			}},
+		)
+		if err != nil {
+			return rewrapped, err
+		}
+		rewrapped++
+	}
+	return rewrapped, nil
+}
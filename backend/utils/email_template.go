@@ -2,6 +2,7 @@ package utils
 
 import (
 	"fmt"
+	"html"
 	"time"
 )
 
@@ -32,3 +33,59 @@ func BuildOtpEmail(name, otp string) string {
 		</div>
 	`, name, otp, year)
 }
+
+func BuildHousekeeperInviteEmail(name, propertyTitle, inviter string) string {
+	year := time.Now().Year()
+	return fmt.Sprintf(`
+		<div style="font-family: Arial, sans-serif; background: #f9f9f9; padding: 20px;">
+		  <div style="max-width: 500px; margin: auto; background: #ffffff; border-radius: 10px; overflow: hidden; box-shadow: 0 4px 6px rgba(0,0,0,0.1);">
+			
+			<div style="background: #7378f5; padding: 15px; text-align: center;">
+			</div>
+			
+			<div style="padding: 20px; text-align: center;">
+			  <h2 style="color: #333;">Hello %s 👋</h2>
+			  <p style="color: #555;"><b>%s</b> has invited you to look after</p>
+			  
+			  <div style="font-size: 24px; font-weight: bold; color: #7378f5; margin: 20px 0;">
+				%s
+			  </div>
+			  
+			  <p style="color: #555;">Open the app to accept or decline. The invite is valid for <b>7 days</b>.</p>
+			</div>
+			
+			<div style="background: #f1f1f1; padding: 15px; text-align: center; font-size: 12px; color: #777;">
+			  &copy; %d Vault. All rights reserved.
+			</div>
+		  </div>
+		</div>
+	`, html.EscapeString(name), html.EscapeString(inviter), html.EscapeString(propertyTitle), year)
+}
+
+func BuildContactChangedEmail(name, field, value string) string {
+	year := time.Now().Year()
+	return fmt.Sprintf(`
+		<div style="font-family: Arial, sans-serif; background: #f9f9f9; padding: 20px;">
+		  <div style="max-width: 500px; margin: auto; background: #ffffff; border-radius: 10px; overflow: hidden; box-shadow: 0 4px 6px rgba(0,0,0,0.1);">
+			
+			<div style="background: #7378f5; padding: 15px; text-align: center;">
+			</div>
+			
+			<div style="padding: 20px; text-align: center;">
+			  <h2 style="color: #333;">Hello %s 👋</h2>
+			  <p style="color: #555;">The %s on your account was changed to</p>
+			  
+			  <div style="font-size: 20px; font-weight: bold; color: #7378f5; margin: 20px 0;">
+				%s
+			  </div>
+			  
+			  <p style="color: #999;">If you didn’t make this change, contact support straight away.</p>
+			</div>
+			
+			<div style="background: #f1f1f1; padding: 15px; text-align: center; font-size: 12px; color: #777;">
+			  &copy; %d Vault. All rights reserved.
+			</div>
+		  </div>
+		</div>
+	`, html.EscapeString(name), html.EscapeString(field), html.EscapeString(value), year)
+}
@@ -0,0 +1,202 @@
+package utils
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ICSEvent is the subset of a VEVENT we export and import
+type ICSEvent struct {
+	UID     string
+	Summary string
+	Start   time.Time
+	End     time.Time
+	AllDay  bool
+}
+
+const (
+	icsDate     = "20060102"
+	icsDateTime = "20060102T150405"
+)
+
+// BuildICS serialises events as an RFC 5545 VCALENDAR
+func BuildICS(calendarName string, events []ICSEvent) string {
+	var b strings.Builder
+	line := func(s string) { b.WriteString(foldICSLine(s) + "\r\n") }
+
+	stamp := time.Now().UTC().Format(icsDateTime) + "Z"
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//Unit Wise//Bookings//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	line("X-WR-CALNAME:" + escapeICSText(calendarName))
+
+	for _, e := range events {
+		line("BEGIN:VEVENT")
+		line("UID:" + e.UID)
+		line("DTSTAMP:" + stamp)
+		if e.AllDay {
+			line("DTSTART;VALUE=DATE:" + e.Start.Format(icsDate))
+			line("DTEND;VALUE=DATE:" + e.End.Format(icsDate))
+		} else {
+			line("DTSTART:" + e.Start.UTC().Format(icsDateTime) + "Z")
+			line("DTEND:" + e.End.UTC().Format(icsDateTime) + "Z")
+		}
+		line("SUMMARY:" + escapeICSText(e.Summary))
+		line("END:VEVENT")
+	}
+
+	line("END:VCALENDAR")
+	return b.String()
+}
+
+// ParseICS reads the VEVENTs of a calendar. Cancelled events and events
+// without a start are skipped; an all-day event without an end lasts one day.
+func ParseICS(r io.Reader) ([]ICSEvent, error) {
+	lines, err := unfoldICSLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		events    []ICSEvent
+		cur       *ICSEvent
+		cancelled bool
+		hasEnd    bool
+		sawCal    bool
+	)
+
+	for _, l := range lines {
+		name, params, value := splitICSLine(l)
+
+		switch {
+		case name == "BEGIN" && value == "VCALENDAR":
+			sawCal = true
+		case name == "BEGIN" && value == "VEVENT":
+			cur, cancelled, hasEnd = &ICSEvent{}, false, false
+		case name == "END" && value == "VEVENT":
+			if cur != nil && !cancelled && !cur.Start.IsZero() {
+				if !hasEnd {
+					if cur.AllDay {
+						cur.End = cur.Start.AddDate(0, 0, 1)
+					} else {
+						cur.End = cur.Start
+					}
+				}
+				events = append(events, *cur)
+			}
+			cur = nil
+		case cur == nil:
+			// property outside an event
+		case name == "UID":
+			cur.UID = value
+		case name == "SUMMARY":
+			cur.Summary = unescapeICSText(value)
+		case name == "STATUS":
+			cancelled = strings.EqualFold(value, "CANCELLED")
+		case name == "DTSTART":
+			t, allDay, err := parseICSTime(params, value)
+			if err != nil {
+				return nil, fmt.Errorf("event %q: %v", cur.UID, err)
+			}
+			cur.Start, cur.AllDay = t, allDay
+		case name == "DTEND":
+			t, _, err := parseICSTime(params, value)
+			if err != nil {
+				return nil, fmt.Errorf("event %q: %v", cur.UID, err)
+			}
+			cur.End, hasEnd = t, true
+		}
+	}
+
+	if !sawCal {
+		return nil, errors.New("not an iCalendar file")
+	}
+	return events, nil
+}
+
+// unfoldICSLines joins continuation lines (those starting with a space or tab)
+func unfoldICSLines(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var lines []string
+	for scanner.Scan() {
+		l := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(l, " ") || strings.HasPrefix(l, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += l[1:]
+			continue
+		}
+		if l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// splitICSLine splits `NAME;PARAM=x;PARAM=y:value`
+func splitICSLine(l string) (name string, params map[string]string, value string) {
+	params = map[string]string{}
+	head, value, _ := strings.Cut(l, ":")
+	parts := strings.Split(head, ";")
+	name = strings.ToUpper(parts[0])
+	for _, p := range parts[1:] {
+		if k, v, ok := strings.Cut(p, "="); ok {
+			params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return name, params, value
+}
+
+func parseICSTime(params map[string]string, value string) (time.Time, bool, error) {
+	if params["VALUE"] == "DATE" || len(value) == len(icsDate) {
+		t, err := time.Parse(icsDate, value)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(icsDateTime, strings.TrimSuffix(value, "Z"))
+		return t, false, err
+	}
+
+	loc := time.UTC
+	if tz := params["TZID"]; tz != "" {
+		if l, err := time.LoadLocation(tz); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation(icsDateTime, value, loc)
+	return t.UTC(), false, err
+}
+
+func escapeICSText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+func unescapeICSText(s string) string {
+	return strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n").Replace(s)
+}
+
+// foldICSLine splits content lines longer than 75 octets, without breaking UTF-8 sequences
+func foldICSLine(s string) string {
+	if len(s) <= 75 {
+		return s
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range s {
+		size := len(string(r))
+		if n+size > 75 {
+			b.WriteString("\r\n ")
+			n = 1
+		}
+		b.WriteRune(r)
+		n += size
+	}
+	return b.String()
+}
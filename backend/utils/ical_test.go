@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata" // TZID fixtures must not depend on the host's zoneinfo
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func parseFixture(t *testing.T, name string) []ICSEvent {
+	t.Helper()
+	f, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	events, err := ParseICS(f)
+	if err != nil {
+		t.Fatalf("ParseICS(%s): %v", name, err)
+	}
+	return events
+}
+
+func TestParseICSAllDayFeed(t *testing.T) {
+	events := parseFixture(t, "airbnb.ics")
+
+	want := []ICSEvent{
+		{UID: "1418fb94e984-f4dbd4d2c5dcb0b2a9b2@airbnb.com", Summary: "Reserved", Start: date(2026, 11, 5), End: date(2026, 11, 8), AllDay: true},
+		{UID: "7f3a0c1e-blocked@airbnb.com", Summary: "Airbnb (Not available)", Start: date(2026, 11, 20), End: date(2026, 11, 22), AllDay: true},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d", len(events), len(want))
+	}
+	for i := range want {
+		assertEvent(t, events[i], want[i])
+	}
+}
+
+func TestParseICSTimezonesFoldingAndCancellations(t *testing.T) {
+	events := parseFixture(t, "booking_com.ics")
+
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2 (cancelled event skipped)", len(events))
+	}
+
+	// 14:00 / 10:00 in Nairobi (UTC+3)
+	assertEvent(t, events[0], ICSEvent{
+		UID:     "bdc-4411@booking.com",
+		Summary: "CLOSED - Not available, owner stay",
+		Start:   time.Date(2026, 12, 1, 11, 0, 0, 0, time.UTC),
+		End:     time.Date(2026, 12, 3, 7, 0, 0, 0, time.UTC),
+	})
+	// All-day event without DTEND lasts one night
+	assertEvent(t, events[1], ICSEvent{
+		UID:     "bdc-4413@booking.com",
+		Summary: "Single night",
+		Start:   date(2026, 12, 24),
+		End:     date(2026, 12, 25),
+		AllDay:  true,
+	})
+}
+
+func TestParseICSRejectsNonCalendar(t *testing.T) {
+	if _, err := ParseICS(strings.NewReader("<html>not a calendar</html>")); err == nil {
+		t.Fatal("expected an error for a non-iCalendar body")
+	}
+}
+
+func TestBuildICSRoundTrip(t *testing.T) {
+	in := []ICSEvent{
+		{UID: "a@unit-wise", Summary: "Reserved", Start: date(2026, 11, 5), End: date(2026, 11, 8), AllDay: true},
+		{
+			UID:     "b@unit-wise",
+			Summary: "Owner; maintenance, plumbing\nand a summary long enough that the content line has to be folded — twice over, ideally",
+			Start:   time.Date(2026, 11, 9, 14, 0, 0, 0, time.UTC),
+			End:     time.Date(2026, 11, 10, 10, 30, 0, 0, time.UTC),
+		},
+	}
+
+	ics := BuildICS("Beach House, Diani", in)
+
+	for _, l := range strings.Split(strings.TrimSuffix(ics, "\r\n"), "\r\n") {
+		if len(l) > 75 {
+			t.Errorf("content line longer than 75 octets: %q", l)
+		}
+	}
+	if !strings.Contains(ics, `X-WR-CALNAME:Beach House\, Diani`) {
+		t.Errorf("calendar name not escaped:\n%s", ics)
+	}
+
+	out, err := ParseICS(strings.NewReader(ics))
+	if err != nil {
+		t.Fatalf("ParseICS(BuildICS()): %v", err)
+	}
+	if len(out) != len(in) {
+		t.Fatalf("got %d events back, want %d", len(out), len(in))
+	}
+	for i := range in {
+		assertEvent(t, out[i], in[i])
+	}
+}
+
+func TestFixtureRoundTrip(t *testing.T) {
+	for _, name := range []string{"airbnb.ics", "booking_com.ics"} {
+		t.Run(name, func(t *testing.T) {
+			first := parseFixture(t, name)
+			again, err := ParseICS(strings.NewReader(BuildICS("fixture", first)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(again) != len(first) {
+				t.Fatalf("got %d events back, want %d", len(again), len(first))
+			}
+			for i := range first {
+				assertEvent(t, again[i], first[i])
+			}
+		})
+	}
+}
+
+func assertEvent(t *testing.T, got, want ICSEvent) {
+	t.Helper()
+	if got.UID != want.UID || got.Summary != want.Summary || got.AllDay != want.AllDay ||
+		!got.Start.Equal(want.Start) || !got.End.Equal(want.End) {
+		t.Errorf("event mismatch\n got: %+v\nwant: %+v", got, want)
+	}
+}
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/phillip/backend/config"
+)
+
+// MasterKeyProvider wraps and unwraps data keys. Data keys never leave the
+// process unwrapped; only the provider can open them. A wrapped key is bound
+// to its owner, so it can't be moved onto another owner's record. A
+// KMS-backed provider implements this by calling the KMS encrypt/decrypt
+// APIs with the owner as encryption context.
+type MasterKeyProvider interface {
+	// Name identifies the provider in stored DataKey records
+	Name() string
+	// ActiveKeyID is the master key new data keys are wrapped under
+	ActiveKeyID() string
+	Wrap(ctx context.Context, ownerID primitive.ObjectID, dataKey []byte) (string, error)
+	Unwrap(ctx context.Context, ownerID primitive.ObjectID, wrapped string) ([]byte, error)
+}
+
+// MasterKeys returns the provider selected by MASTER_KEY_PROVIDER.
+// Providers that hold connections should be built once and cached here.
+func MasterKeys(cfg *config.Config) (MasterKeyProvider, error) {
+	switch cfg.MasterKeyProvider {
+	case "", "local":
+		return localMasterKeys{cfg: cfg}, nil
+	}
+	return nil, fmt.Errorf("unknown master key provider %q", cfg.MasterKeyProvider)
+}
+
+// Data keys wrapped by the local provider look like
+//
+//	w1:<key id>:<base64url(nonce | AES-256-GCM ciphertext+tag)>
+//
+// with "w1:<key id>:<owner id>" as additional data. The keys, version and
+// additional data all differ from stored secrets (see EncryptSecret), so a
+// wrapped key never opens as a secret, nor a secret as a wrapped key.
+const wrappedKeyVersion = "w1"
+
+// localMasterKeys wraps data keys with the MASTER_KEYS keyring from the
+// environment. Keys wrapped before MASTER_KEYS existed are v2 secrets under
+// AES_KEYS; they are still unwrapped, and RewrapDataKeys moves them over.
+type localMasterKeys struct {
+	cfg *config.Config
+}
+
+func (l localMasterKeys) Name() string        { return "local" }
+func (l localMasterKeys) ActiveKeyID() string { return l.cfg.MasterActiveKeyID }
+
+func (l localMasterKeys) Wrap(_ context.Context, ownerID primitive.ObjectID, dataKey []byte) (string, error) {
+	keyID := l.cfg.MasterActiveKeyID
+	key, ok := l.cfg.MasterKeys[keyID]
+	if !ok {
+		return "", ErrUnknownKeyID
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, dataKey, wrapAAD(keyID, ownerID))
+	return wrappedKeyVersion + ":" + keyID + ":" + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+func (l localMasterKeys) Unwrap(_ context.Context, ownerID primitive.ObjectID, wrapped string) ([]byte, error) {
+	if strings.HasPrefix(wrapped, secretVersion+":") {
+		encoded, err := openWithKeys(l.cfg.AESKeys, wrapped)
+		if err != nil {
+			return nil, err
+		}
+		return base64.StdEncoding.DecodeString(encoded)
+	}
+
+	parts := strings.SplitN(wrapped, ":", 3)
+	if len(parts) != 3 || parts[0] != wrappedKeyVersion {
+		return nil, ErrMalformedSecret
+	}
+	key, ok := l.cfg.MasterKeys[parts[1]]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKeyID, parts[1])
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(raw) < gcm.NonceSize()+gcm.Overhead() {
+		return nil, ErrMalformedSecret
+	}
+	dataKey, err := gcm.Open(nil, raw[:gcm.NonceSize()], raw[gcm.NonceSize():], wrapAAD(parts[1], ownerID))
+	if err != nil {
+		return nil, ErrSecretTampered
+	}
+	return dataKey, nil
+}
+
+func wrapAAD(keyID string, ownerID primitive.ObjectID) []byte {
+	return []byte(wrappedKeyVersion + ":" + keyID + ":" + ownerID.Hex())
+}
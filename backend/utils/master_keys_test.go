@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/phillip/backend/config"
+)
+
+func testMasterKeyConfig() *config.Config {
+	return &config.Config{
+		AESKey:            []byte(strings.Repeat("c", 32)),
+		AESKeys:           map[string][]byte{"k1": []byte(strings.Repeat("a", 32))},
+		AESActiveKeyID:    "k1",
+		MasterKeyProvider: "local",
+		MasterKeys:        map[string][]byte{"m1": []byte(strings.Repeat("m", 32)), "m2": []byte(strings.Repeat("n", 32))},
+		MasterActiveKeyID: "m2",
+	}
+}
+
+func TestLocalMasterKeysRoundTrip(t *testing.T) {
+	cfg := testMasterKeyConfig()
+	provider, err := MasterKeys(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	owner := primitive.NewObjectID()
+	dataKey := bytes.Repeat([]byte{7}, 32)
+
+	wrapped, err := provider.Wrap(ctx, owner, dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(wrapped, "w1:m2:") || provider.ActiveKeyID() != "m2" {
+		t.Fatalf("wrapped %q under %s", wrapped, provider.ActiveKeyID())
+	}
+	got, err := provider.Unwrap(ctx, owner, wrapped)
+	if err != nil || !bytes.Equal(got, dataKey) {
+		t.Fatalf("Unwrap = %x, %v", got, err)
+	}
+
+	// Keys wrapped under a retired master key stay readable
+	cfg.MasterActiveKeyID = "m1"
+	if got, err := provider.Unwrap(ctx, owner, wrapped); err != nil || !bytes.Equal(got, dataKey) {
+		t.Fatalf("after rotation: %x, %v", got, err)
+	}
+	delete(cfg.MasterKeys, "m2")
+	if _, err := provider.Unwrap(ctx, owner, wrapped); err == nil {
+		t.Fatal("unwrapped with the master key removed")
+	}
+}
+
+func TestLocalMasterKeysBindOwner(t *testing.T) {
+	provider, _ := MasterKeys(testMasterKeyConfig())
+	ctx := context.Background()
+	wrapped, err := provider.Wrap(ctx, primitive.NewObjectID(), bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Unwrap(ctx, primitive.NewObjectID(), wrapped); err == nil {
+		t.Fatal("a wrapped key opened for another owner")
+	}
+}
+
+func TestWrappedKeyIsNotASecret(t *testing.T) {
+	cfg := testMasterKeyConfig()
+	// Even a misconfigured keyring reusing the same key under the same ID
+	// must not let one envelope open as the other
+	cfg.AESKeys["m2"] = cfg.MasterKeys["m2"]
+
+	provider, _ := MasterKeys(cfg)
+	ctx := context.Background()
+	owner := primitive.NewObjectID()
+	dataKey := bytes.Repeat([]byte{7}, 32)
+
+	wrapped, err := provider.Wrap(ctx, owner, dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain, err := DecryptSecret(cfg, wrapped); err == nil {
+		t.Fatalf("wrapped key revealed as a secret: %q", plain)
+	}
+	if plain, err := (&DataKey{ownerID: owner, key: dataKey, cfg: cfg}).Open(wrapped); err == nil {
+		t.Fatalf("wrapped key revealed through a data key: %q", plain)
+	}
+
+	// And a stored secret can't pass for a wrapped key
+	secret, err := sealWithKey(cfg.AESKeys, "m2", base64.StdEncoding.EncodeToString(dataKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := wrappedKeyVersion + strings.TrimPrefix(secret, secretVersion)
+	if _, err := provider.Unwrap(ctx, owner, forged); err == nil {
+		t.Fatal("a v2 secret unwrapped as a data key")
+	}
+}
+
+func TestLocalMasterKeysReadLegacyWraps(t *testing.T) {
+	// Before MASTER_KEYS, data keys were wrapped as v2 secrets under AES_KEYS
+	cfg := testMasterKeyConfig()
+	dataKey := bytes.Repeat([]byte{9}, 32)
+	legacy, err := sealWithKey(cfg.AESKeys, "k1", base64.StdEncoding.EncodeToString(dataKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider, _ := MasterKeys(cfg)
+	got, err := provider.Unwrap(context.Background(), primitive.NewObjectID(), legacy)
+	if err != nil || !bytes.Equal(got, dataKey) {
+		t.Fatalf("legacy wrap: %x, %v", got, err)
+	}
+}
@@ -0,0 +1,181 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/phillip/backend/config"
+	"github.com/phillip/backend/models"
+)
+
+// ErrChannelUnavailable means the channel has no provider configured
+var ErrChannelUnavailable = errors.New("messaging channel not configured")
+
+// Message is one outgoing notification. Email sends HTML when it is set;
+// SMS and WhatsApp send Text.
+type Message struct {
+	To      string // email address, or E.164 phone number
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Messenger sends messages on one channel (models.ChannelEmail, ...)
+type Messenger interface {
+	Channel() string
+	Send(ctx context.Context, msg Message) error
+}
+
+var (
+	fakeOutboxOnce sync.Once
+	fakeOutbox     *FakeOutbox
+)
+
+// MessengerFor returns the messenger for channel under MESSAGE_PROVIDER,
+// or ErrChannelUnavailable when nothing is set up to send on it
+func MessengerFor(cfg *config.Config, channel string) (Messenger, error) {
+	switch channel {
+	case models.ChannelEmail, models.ChannelSMS, models.ChannelWhatsApp:
+	default:
+		return nil, fmt.Errorf("unknown channel %q", channel)
+	}
+
+	switch cfg.MessageProvider {
+	case "fake":
+		return fakeMessenger{channel: channel, outbox: FakeMessages()}, nil
+	case "", "twilio":
+		switch channel {
+		case models.ChannelEmail:
+			return emailMessenger{}, nil
+		case models.ChannelSMS:
+			if cfg.TwilioAccountSID == "" || cfg.TwilioAuthToken == "" || cfg.TwilioSMSFrom == "" {
+				return nil, ErrChannelUnavailable
+			}
+		case models.ChannelWhatsApp:
+			if cfg.TwilioAccountSID == "" || cfg.TwilioAuthToken == "" || cfg.TwilioWhatsAppFrom == "" {
+				return nil, ErrChannelUnavailable
+			}
+		}
+		return twilioMessenger{cfg: cfg, channel: channel}, nil
+	}
+	return nil, fmt.Errorf("unknown message provider %q", cfg.MessageProvider)
+}
+
+// emailMessenger sends through SendEmail
+type emailMessenger struct{}
+
+func (emailMessenger) Channel() string { return models.ChannelEmail }
+
+func (emailMessenger) Send(_ context.Context, msg Message) error {
+	body := msg.HTML
+	if body == "" {
+		body = html.EscapeString(msg.Text)
+	}
+	return SendEmail(msg.To, msg.Subject, body)
+}
+
+var twilioClient = &http.Client{Timeout: 10 * time.Second}
+
+// twilioMessenger sends SMS or WhatsApp messages through Twilio's
+// Messages API; WhatsApp addresses carry a "whatsapp:" prefix
+type twilioMessenger struct {
+	cfg     *config.Config
+	channel string
+}
+
+func (t twilioMessenger) Channel() string { return t.channel }
+
+func (t twilioMessenger) Send(ctx context.Context, msg Message) error {
+	from, to := t.cfg.TwilioSMSFrom, msg.To
+	if t.channel == models.ChannelWhatsApp {
+		from, to = "whatsapp:"+t.cfg.TwilioWhatsAppFrom, "whatsapp:"+msg.To
+	}
+
+	form := url.Values{"From": {from}, "To": {to}, "Body": {msg.Text}}
+	endpoint := "https://api.twilio.com/2010-04-01/Accounts/" + url.PathEscape(t.cfg.TwilioAccountSID) + "/Messages.json"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(t.cfg.TwilioAccountSID, t.cfg.TwilioAuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := twilioClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("twilio %s: %s: %s", t.channel, resp.Status, body)
+	}
+	return nil
+}
+
+// SentMessage is a message the fake provider recorded
+type SentMessage struct {
+	Channel string
+	Message
+	SentAt time.Time
+}
+
+// FakeOutbox records what the fake provider was asked to send, for tests
+// and local development
+type FakeOutbox struct {
+	mu   sync.Mutex
+	sent []SentMessage
+}
+
+// FakeMessages returns the process-wide outbox of MESSAGE_PROVIDER=fake
+func FakeMessages() *FakeOutbox {
+	fakeOutboxOnce.Do(func() { fakeOutbox = &FakeOutbox{} })
+	return fakeOutbox
+}
+
+// Sent returns every recorded message, oldest first
+func (o *FakeOutbox) Sent() []SentMessage {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]SentMessage(nil), o.sent...)
+}
+
+// Last returns the newest message sent to the address
+func (o *FakeOutbox) Last(to string) (SentMessage, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for i := len(o.sent) - 1; i >= 0; i-- {
+		if o.sent[i].To == to {
+			return o.sent[i], true
+		}
+	}
+	return SentMessage{}, false
+}
+
+// Reset forgets every recorded message
+func (o *FakeOutbox) Reset() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.sent = nil
+}
+
+type fakeMessenger struct {
+	channel string
+	outbox  *FakeOutbox
+}
+
+func (f fakeMessenger) Channel() string { return f.channel }
+
+func (f fakeMessenger) Send(_ context.Context, msg Message) error {
+	f.outbox.mu.Lock()
+	defer f.outbox.mu.Unlock()
+	f.outbox.sent = append(f.outbox.sent, SentMessage{Channel: f.channel, Message: msg, SentAt: time.Now()})
+	return nil
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/phillip/backend/config"
+	"github.com/phillip/backend/models"
+)
+
+// TOTPIssuer labels our entry in authenticator apps
+const TOTPIssuer = "Unit Wise"
+
+const recoveryCodeCount = 10
+
+// LoadMFAPolicy returns the stored policy, or an empty one (nobody required)
+func LoadMFAPolicy(ctx context.Context, cfg *config.Config) (models.MFAPolicy, error) {
+	policy := models.MFAPolicy{ID: models.MFAPolicyID, RequiredRoles: []string{}}
+	err := cfg.MongoClient.Database(cfg.DBName).Collection("mfa_policy").
+		FindOne(ctx, bson.M{"_id": models.MFAPolicyID}).Decode(&policy)
+	if err == mongo.ErrNoDocuments {
+		return policy, nil
+	}
+	return policy, err
+}
+
+// MFARequired reports whether the policy makes role use two-factor authentication
+func MFARequired(ctx context.Context, cfg *config.Config, role string) (bool, error) {
+	policy, err := LoadMFAPolicy(ctx, cfg)
+	if err != nil {
+		return false, err
+	}
+	for _, r := range policy.RequiredRoles {
+		if r == role {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// NewRecoveryCodes returns single-use codes to show the user once, and the
+// hashes to store
+func NewRecoveryCodes() (codes []string, hashes []string, err error) {
+	for i := 0; i < recoveryCodeCount; i++ {
+		token, err := RandomToken(5)
+		if err != nil {
+			return nil, nil, err
+		}
+		code := token[:5] + "-" + token[5:]
+		codes = append(codes, code)
+		hashes = append(hashes, HashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// HashRecoveryCode normalises a code as typed (case, spaces, dashes) and hashes it
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(code))
+	if len(code) == 10 {
+		code = code[:5] + "-" + code[5:]
+	}
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// HashToken is how short-lived bearer secrets (e.g. MFA challenges) are stored
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/phillip/backend/config"
+)
+
+// NewOTP returns a uniformly random six-digit code
+func NewOTP() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// HashOTP is what gets stored instead of the code: an HMAC keyed with the
+// server secret and bound to the user, so a leaked users collection can't be
+// brute-forced offline or replayed against another account
+func HashOTP(cfg *config.Config, userID primitive.ObjectID, code string) string {
+	mac := hmac.New(sha256.New, cfg.JWTSecret)
+	mac.Write([]byte("otp:" + userID.Hex() + ":" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// CheckOTP compares code against a stored HashOTP in constant time
+func CheckOTP(cfg *config.Config, userID primitive.ObjectID, stored, code string) bool {
+	if stored == "" {
+		return false
+	}
+	return hmac.Equal([]byte(stored), []byte(HashOTP(cfg, userID, code)))
+}
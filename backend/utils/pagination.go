@@ -0,0 +1,194 @@
+package utils
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// ListParams is the parsed ?limit=&cursor=&sort= of a list endpoint
+type ListParams struct {
+	Limit  int64
+	Offset int64
+	Sort   bson.D
+}
+
+// PageInfo describes where a page sits in the full result
+type PageInfo struct {
+	Total      int64
+	NextCursor string
+}
+
+// ParseListParams reads limit (1-100, default 20), the opaque cursor returned
+// as next_cursor by the previous page, and sort. sort is a comma-separated list
+// of public field names, each optionally prefixed with "-" for descending;
+// sortable maps those names to bson fields.
+func ParseListParams(c *gin.Context, sortable map[string]string, defaultSort string) (ListParams, error) {
+	p := ListParams{Limit: DefaultPageSize}
+
+	if l := c.Query("limit"); l != "" {
+		n, err := strconv.ParseInt(l, 10, 64)
+		if err != nil || n < 1 || n > MaxPageSize {
+			return p, fmt.Errorf("limit must be between 1 and %d", MaxPageSize)
+		}
+		p.Limit = n
+	}
+
+	if cur := c.Query("cursor"); cur != "" {
+		offset, err := decodeCursor(cur)
+		if err != nil {
+			return p, errors.New("invalid cursor")
+		}
+		p.Offset = offset
+	}
+
+	sortParam := c.DefaultQuery("sort", defaultSort)
+	for _, f := range strings.Split(sortParam, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		dir := 1
+		if strings.HasPrefix(f, "-") {
+			dir = -1
+			f = f[1:]
+		}
+		field, ok := sortable[f]
+		if !ok {
+			return p, fmt.Errorf("cannot sort by %q", f)
+		}
+		p.Sort = append(p.Sort, bson.E{Key: field, Value: dir})
+	}
+	// _id as the last key keeps pages stable when sort values tie
+	p.Sort = append(p.Sort, bson.E{Key: "_id", Value: 1})
+
+	return p, nil
+}
+
+// FindPage decodes one page of col matching filter into out (a pointer to a slice)
+func FindPage(ctx context.Context, col *mongo.Collection, filter interface{}, p ListParams, out interface{}) (PageInfo, error) {
+	total, err := col.CountDocuments(ctx, filter)
+	if err != nil {
+		return PageInfo{}, err
+	}
+
+	opts := options.Find().SetSort(p.Sort).SetSkip(p.Offset).SetLimit(p.Limit)
+	cursor, err := col.Find(ctx, filter, opts)
+	if err != nil {
+		return PageInfo{}, err
+	}
+	if err := cursor.All(ctx, out); err != nil {
+		return PageInfo{}, err
+	}
+
+	return pageInfo(p, total), nil
+}
+
+// AggregatePage is FindPage for results that need extra stages (e.g. $lookup),
+// which run after the page has been cut so they only touch returned documents
+func AggregatePage(ctx context.Context, col *mongo.Collection, filter bson.M, p ListParams, stages mongo.Pipeline, out interface{}) (PageInfo, error) {
+	total, err := col.CountDocuments(ctx, filter)
+	if err != nil {
+		return PageInfo{}, err
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$sort", Value: p.Sort}},
+		{{Key: "$skip", Value: p.Offset}},
+		{{Key: "$limit", Value: p.Limit}},
+	}
+	pipeline = append(pipeline, stages...)
+
+	cursor, err := col.Aggregate(ctx, pipeline)
+	if err != nil {
+		return PageInfo{}, err
+	}
+	if err := cursor.All(ctx, out); err != nil {
+		return PageInfo{}, err
+	}
+
+	return pageInfo(p, total), nil
+}
+
+// PageResponse is the envelope every list endpoint returns
+func PageResponse(data interface{}, info PageInfo) gin.H {
+	var next interface{}
+	if info.NextCursor != "" {
+		next = info.NextCursor
+	}
+	return gin.H{
+		"data":        data,
+		"next_cursor": next,
+		"total":       info.Total,
+	}
+}
+
+// QueryObjectID parses an optional ObjectID query parameter
+func QueryObjectID(c *gin.Context, name string) (primitive.ObjectID, bool, error) {
+	v := c.Query(name)
+	if v == "" {
+		return primitive.NilObjectID, false, nil
+	}
+	id, err := primitive.ObjectIDFromHex(v)
+	if err != nil {
+		return primitive.NilObjectID, false, fmt.Errorf("invalid %s", name)
+	}
+	return id, true, nil
+}
+
+// QueryFloat parses an optional numeric query parameter
+func QueryFloat(c *gin.Context, name string) (float64, bool, error) {
+	v := c.Query(name)
+	if v == "" {
+		return 0, false, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid %s", name)
+	}
+	return f, true, nil
+}
+
+func pageInfo(p ListParams, total int64) PageInfo {
+	info := PageInfo{Total: total}
+	if next := p.Offset + p.Limit; next < total {
+		info.NextCursor = encodeCursor(next)
+	}
+	return info
+}
+
+// Cursors are opaque to clients; today they wrap an offset
+func encodeCursor(offset int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("o:" + strconv.FormatInt(offset, 10)))
+}
+
+func decodeCursor(s string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return 0, err
+	}
+	v, ok := strings.CutPrefix(string(raw), "o:")
+	if !ok {
+		return 0, errors.New("unknown cursor")
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, errors.New("bad cursor offset")
+	}
+	return n, nil
+}
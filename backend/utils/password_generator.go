@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+const (
+	lowerChars  = "abcdefghijklmnopqrstuvwxyz"
+	upperChars  = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digitChars  = "0123456789"
+	symbolChars = "!@#$%^&*()-_=+[]{};:,.<>?/~"
+
+	// ambiguousChars look alike in many fonts
+	ambiguousChars = "Il1O0o"
+
+	MinGeneratedLength = 8
+	MaxGeneratedLength = 128
+)
+
+// PasswordOptions picks the length and character classes of a generated password
+type PasswordOptions struct {
+	Length           int
+	Lowercase        bool
+	Uppercase        bool
+	Digits           bool
+	Symbols          bool
+	ExcludeAmbiguous bool
+}
+
+// GeneratePassword returns a random password from crypto/rand containing at
+// least one character of every class requested
+func GeneratePassword(opts PasswordOptions) (string, error) {
+	if opts.Length < MinGeneratedLength || opts.Length > MaxGeneratedLength {
+		return "", errors.New("length must be between 8 and 128")
+	}
+
+	var classes []string
+	for _, class := range []struct {
+		on    bool
+		chars string
+	}{{opts.Lowercase, lowerChars}, {opts.Uppercase, upperChars}, {opts.Digits, digitChars}, {opts.Symbols, symbolChars}} {
+		if !class.on {
+			continue
+		}
+		chars := class.chars
+		if opts.ExcludeAmbiguous {
+			chars = strings.Map(func(r rune) rune {
+				if strings.ContainsRune(ambiguousChars, r) {
+					return -1
+				}
+				return r
+			}, chars)
+		}
+		classes = append(classes, chars)
+	}
+	if len(classes) == 0 {
+		return "", errors.New("at least one character class is required")
+	}
+
+	// One from each class, the rest from all of them, then shuffle
+	out := make([]byte, 0, opts.Length)
+	for _, chars := range classes {
+		ch, err := randomChar(chars)
+		if err != nil {
+			return "", err
+		}
+		out = append(out, ch)
+	}
+	all := strings.Join(classes, "")
+	for len(out) < opts.Length {
+		ch, err := randomChar(all)
+		if err != nil {
+			return "", err
+		}
+		out = append(out, ch)
+	}
+	for i := len(out) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		out[i], out[j.Int64()] = out[j.Int64()], out[i]
+	}
+	return string(out), nil
+}
+
+func randomChar(chars string) (byte, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+	if err != nil {
+		return 0, err
+	}
+	return chars[n.Int64()], nil
+}
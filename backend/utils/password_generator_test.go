@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGeneratePasswordLength(t *testing.T) {
+	all := PasswordOptions{Lowercase: true, Uppercase: true, Digits: true, Symbols: true}
+	for _, n := range []int{MinGeneratedLength, 20, MaxGeneratedLength} {
+		opts := all
+		opts.Length = n
+		p, err := GeneratePassword(opts)
+		if err != nil || len(p) != n {
+			t.Errorf("length %d: got %q, %v", n, p, err)
+		}
+	}
+	for _, n := range []int{0, -1, MinGeneratedLength - 1, MaxGeneratedLength + 1} {
+		opts := all
+		opts.Length = n
+		if p, err := GeneratePassword(opts); err == nil {
+			t.Errorf("length %d: accepted, got %q", n, p)
+		}
+	}
+}
+
+func TestGeneratePasswordClasses(t *testing.T) {
+	classes := map[string]string{"lower": lowerChars, "upper": upperChars, "digit": digitChars, "symbol": symbolChars}
+	cases := []struct {
+		name string
+		opts PasswordOptions
+		want []string
+	}{
+		{"all classes", PasswordOptions{Lowercase: true, Uppercase: true, Digits: true, Symbols: true}, []string{"lower", "upper", "digit", "symbol"}},
+		{"letters only", PasswordOptions{Lowercase: true, Uppercase: true}, []string{"lower", "upper"}},
+		{"digits only", PasswordOptions{Digits: true}, []string{"digit"}},
+		{"symbols and digits", PasswordOptions{Symbols: true, Digits: true}, []string{"symbol", "digit"}},
+	}
+	for _, tc := range cases {
+		tc.opts.Length = MinGeneratedLength
+		// Every requested class turns up every time, even at the minimum length
+		for i := 0; i < 200; i++ {
+			p, err := GeneratePassword(tc.opts)
+			if err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+			allowed := ""
+			for _, class := range tc.want {
+				if !strings.ContainsAny(p, classes[class]) {
+					t.Fatalf("%s: %q has no %s character", tc.name, p, class)
+				}
+				allowed += classes[class]
+			}
+			if strings.Trim(p, allowed) != "" {
+				t.Fatalf("%s: %q has characters outside the requested classes", tc.name, p)
+			}
+		}
+	}
+}
+
+func TestGeneratePasswordExcludeAmbiguous(t *testing.T) {
+	opts := PasswordOptions{Length: MaxGeneratedLength, Lowercase: true, Uppercase: true, Digits: true, ExcludeAmbiguous: true}
+	for i := 0; i < 50; i++ {
+		p, err := GeneratePassword(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.ContainsAny(p, ambiguousChars) {
+			t.Fatalf("%q contains an ambiguous character", p)
+		}
+	}
+
+	// Without the option they do turn up
+	opts.ExcludeAmbiguous = false
+	seen := false
+	for i := 0; i < 50 && !seen; i++ {
+		p, err := GeneratePassword(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen = strings.ContainsAny(p, ambiguousChars)
+	}
+	if !seen {
+		t.Error("ambiguous characters never generated without ExcludeAmbiguous")
+	}
+}
+
+func TestGeneratePasswordNeedsAClass(t *testing.T) {
+	for _, opts := range []PasswordOptions{
+		{Length: 16},
+		{Length: 16, ExcludeAmbiguous: true},
+	} {
+		if p, err := GeneratePassword(opts); err == nil {
+			t.Errorf("%+v: accepted, got %q", opts, p)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+const (
+	// MinPasswordLength is the shortest password not flagged as short
+	MinPasswordLength = 12
+	// MinPasswordEntropy is the estimated strength, in bits, below which a password is weak
+	MinPasswordEntropy = 60
+)
+
+// commonPasswords are rejected outright whatever their estimated entropy
+var commonPasswords = map[string]bool{
+	"123456": true, "123456789": true, "12345678": true, "password": true,
+	"qwerty": true, "qwerty123": true, "1q2w3e4r": true, "111111": true,
+	"abc123": true, "password1": true, "iloveyou": true, "admin": true,
+	"welcome": true, "letmein": true, "monkey": true, "dragon": true,
+	"sunshine": true, "princess": true, "football": true, "baseball": true,
+	"passw0rd": true, "p@ssw0rd": true, "p@ssword": true, "changeme": true,
+	"trustno1": true, "superman": true, "starwars": true, "123123": true,
+	"000000": true, "qwertyuiop": true, "asdfghjkl": true, "zaq12wsx": true,
+}
+
+// PasswordStrength is the outcome of checking one password
+type PasswordStrength struct {
+	Length  int     `json:"length"`
+	Entropy float64 `json:"entropy_bits"`
+	Short   bool    `json:"short"`
+	Weak    bool    `json:"weak"`
+	Common  bool    `json:"common"`
+}
+
+// CheckPasswordStrength estimates a password's entropy from its length and the
+// character classes it draws on, discounting runs of repeated or sequential
+// characters. It is a heuristic, not a cracking-time estimate.
+func CheckPasswordStrength(password string) PasswordStrength {
+	runes := []rune(password)
+	s := PasswordStrength{
+		Length: len(runes),
+		Common: commonPasswords[strings.ToLower(password)],
+	}
+
+	var lower, upper, digit, symbol, other bool
+	for _, r := range runes {
+		switch {
+		case r < unicode.MaxASCII && unicode.IsLower(r):
+			lower = true
+		case r < unicode.MaxASCII && unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case r < unicode.MaxASCII && unicode.IsPrint(r):
+			symbol = true
+		default:
+			other = true
+		}
+	}
+	pool := 0
+	for _, class := range []struct {
+		used bool
+		size int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if class.used {
+			pool += class.size
+		}
+	}
+
+	// Characters that repeat or continue a sequence of the previous one add little
+	effective := 0
+	for i, r := range runes {
+		if i > 0 {
+			d := r - runes[i-1]
+			if d == 0 || d == 1 || d == -1 {
+				continue
+			}
+		}
+		effective++
+	}
+
+	if pool > 0 {
+		s.Entropy = math.Round(float64(effective)*math.Log2(float64(pool))*10) / 10
+	}
+	s.Short = s.Length < MinPasswordLength
+	s.Weak = s.Common || s.Entropy < MinPasswordEntropy
+	return s
+}
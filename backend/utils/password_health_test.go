@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckPasswordStrengthEntropy(t *testing.T) {
+	cases := []struct {
+		password string
+		entropy  float64
+	}{
+		{"", 0},
+		{"a", 4.7},         // log2(26)
+		{"aaaa", 4.7},      // repeats add nothing
+		{"abcd", 4.7},      // nor does an ascending run
+		{"dcba", 4.7},      // or a descending one
+		{"123456789", 3.3}, // log2(10)
+		{"aB3$", 26.3},     // 4 × log2(26+26+10+33)
+		{"ñ", 6.6},         // non-ASCII draws on a large pool
+		{"acegikmoqsuw", 56.4},
+		{"acegikmoqsuwy", 61.1},
+	}
+	for _, tc := range cases {
+		if got := CheckPasswordStrength(tc.password).Entropy; got != tc.entropy {
+			t.Errorf("entropy(%q) = %v, want %v", tc.password, got, tc.entropy)
+		}
+	}
+}
+
+func TestCheckPasswordStrengthFlags(t *testing.T) {
+	cases := []struct {
+		password            string
+		short, weak, common bool
+	}{
+		{"", true, true, false},
+		{"password", true, true, true},
+		{"PassWord", true, true, true}, // case doesn't hide a common password
+		{"P@ssw0rd", true, true, true},
+		{"qwertyuiop", true, true, true},
+		{"password123456", false, true, false},
+
+		// Length alone: 11 characters is short, 12 is not
+		{"k7#Qm2@xV9!", true, false, false},
+		{"k7#Qm2@xV9!z", false, false, false},
+
+		// Long but made of runs
+		{"aaaaaaaaaaaaaaaaaaaa", false, true, false},
+		{"abcdefghijklmnopqrst", false, true, false},
+		{"98765432109876543210", false, true, false},
+
+		// Just under and just over the entropy threshold at 12+ characters
+		{"acegikmoqsuw", false, true, false},
+		{"acegikmoqsuwy", false, false, false},
+	}
+	for _, tc := range cases {
+		s := CheckPasswordStrength(tc.password)
+		if s.Short != tc.short || s.Weak != tc.weak || s.Common != tc.common {
+			t.Errorf("%q: short %v weak %v common %v (entropy %v), want %v %v %v",
+				tc.password, s.Short, s.Weak, s.Common, s.Entropy, tc.short, tc.weak, tc.common)
+		}
+		if s.Length != len([]rune(tc.password)) {
+			t.Errorf("%q: length %d", tc.password, s.Length)
+		}
+	}
+}
+
+func TestCommonPasswordsAreLowerCase(t *testing.T) {
+	// Lookups lower-case the password, so an entry with capitals would never match
+	for p := range commonPasswords {
+		if p != strings.ToLower(p) {
+			t.Errorf("common password %q is not lower case", p)
+		}
+	}
+}
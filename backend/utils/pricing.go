@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/phillip/backend/models"
+)
+
+// MinStayError is returned when a stay is shorter than the applicable minimum
+type MinStayError struct {
+	MinStay int
+}
+
+func (e MinStayError) Error() string {
+	return fmt.Sprintf("minimum stay is %d nights", e.MinStay)
+}
+
+// QuoteStay prices the nights in [start, end) against a rate plan. Each night
+// uses the first matching season, then the weekend rate for Friday and
+// Saturday nights, then the base rate. Tax applies to nights and cleaning.
+func QuoteStay(plan models.RatePlan, start, end time.Time) (models.Quote, error) {
+	start = start.UTC().Truncate(24 * time.Hour)
+	end = end.UTC().Truncate(24 * time.Hour)
+
+	nights := int(end.Sub(start).Hours() / 24)
+	if nights <= 0 {
+		return models.Quote{}, errors.New("stay must be at least one night")
+	}
+
+	minStay := plan.MinStay
+	if season := seasonFor(plan.Seasons, start); season != nil && season.MinStay > 0 {
+		minStay = season.MinStay
+	}
+	if nights < minStay {
+		return models.Quote{}, MinStayError{MinStay: minStay}
+	}
+
+	// Group nights with the same description and rate into one line item
+	var items []models.LineItem
+	index := map[string]int{}
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		desc, rate := nightlyRate(plan, day)
+		key := fmt.Sprintf("%s|%d", desc, rate)
+		if i, ok := index[key]; ok {
+			items[i].Quantity++
+			items[i].Amount += rate
+			continue
+		}
+		index[key] = len(items)
+		items = append(items, models.LineItem{Description: desc, Quantity: 1, UnitAmount: rate, Amount: rate})
+	}
+
+	if plan.CleaningFee > 0 {
+		items = append(items, models.LineItem{Description: "Cleaning fee", Quantity: 1, UnitAmount: plan.CleaningFee, Amount: plan.CleaningFee})
+	}
+
+	var subtotal int64
+	for _, it := range items {
+		subtotal += it.Amount
+	}
+
+	tax := int64(math.Round(float64(subtotal) * plan.TaxPercent / 100))
+	if tax > 0 {
+		items = append(items, models.LineItem{Description: fmt.Sprintf("Tax (%g%%)", plan.TaxPercent), Quantity: 1, UnitAmount: tax, Amount: tax})
+	}
+
+	return models.Quote{
+		Currency:  plan.Currency,
+		Nights:    nights,
+		LineItems: items,
+		Subtotal:  subtotal,
+		Tax:       tax,
+		Total:     subtotal + tax,
+	}, nil
+}
+
+// ValidateRatePlan checks amounts, currency code and season ranges
+func ValidateRatePlan(plan models.RatePlan) error {
+	if len(plan.Currency) != 3 {
+		return errors.New("currency must be a 3-letter ISO code")
+	}
+	if plan.BaseNightly <= 0 {
+		return errors.New("base_nightly must be positive")
+	}
+	if plan.WeekendNightly < 0 || plan.CleaningFee < 0 || plan.MinStay < 0 {
+		return errors.New("amounts and min_stay cannot be negative")
+	}
+	if plan.TaxPercent < 0 || plan.TaxPercent > 100 {
+		return errors.New("tax_percent must be between 0 and 100")
+	}
+	for _, s := range plan.Seasons {
+		if !s.EndDate.After(s.StartDate) {
+			return fmt.Errorf("season %q must end after it starts", s.Name)
+		}
+		if s.Nightly <= 0 || s.MinStay < 0 {
+			return fmt.Errorf("season %q needs a positive nightly rate", s.Name)
+		}
+	}
+	return nil
+}
+
+func nightlyRate(plan models.RatePlan, day time.Time) (string, int64) {
+	if s := seasonFor(plan.Seasons, day); s != nil {
+		return "Nightly rate (" + s.Name + ")", s.Nightly
+	}
+	if plan.WeekendNightly > 0 && (day.Weekday() == time.Friday || day.Weekday() == time.Saturday) {
+		return "Weekend nightly rate", plan.WeekendNightly
+	}
+	return "Nightly rate", plan.BaseNightly
+}
+
+func seasonFor(seasons []models.SeasonalRate, day time.Time) *models.SeasonalRate {
+	for i := range seasons {
+		s := &seasons[i]
+		if !s.StartDate.UTC().Truncate(24*time.Hour).After(day) && s.EndDate.UTC().Truncate(24*time.Hour).After(day) {
+			return s
+		}
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phillip/backend/models"
+)
+
+// march returns midnight UTC on the given date in March 2026 (the 2nd is a Monday)
+func march(d int) time.Time {
+	return time.Date(2026, time.March, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestQuoteStayRates(t *testing.T) {
+	plan := models.RatePlan{
+		Currency:       "KES",
+		BaseNightly:    10000,
+		WeekendNightly: 15000,
+		Seasons: []models.SeasonalRate{
+			{Name: "Easter", StartDate: march(13), EndDate: march(15), Nightly: 20000},
+			{Name: "Early", StartDate: march(23), EndDate: march(25), Nightly: 12000},
+		},
+	}
+
+	cases := []struct {
+		name       string
+		start, end time.Time
+		want       []models.LineItem
+		subtotal   int64
+	}{
+		{
+			name:  "weekday and weekend nights",
+			start: march(2), end: march(9), // Mon to Mon
+			want: []models.LineItem{
+				{Description: "Nightly rate", Quantity: 5, UnitAmount: 10000, Amount: 50000},
+				{Description: "Weekend nightly rate", Quantity: 2, UnitAmount: 15000, Amount: 30000},
+			},
+			subtotal: 80000,
+		},
+		{
+			name:  "season wins over weekend",
+			start: march(12), end: march(16), // Thu to Mon, season covers Fri and Sat
+			want: []models.LineItem{
+				{Description: "Nightly rate", Quantity: 2, UnitAmount: 10000, Amount: 20000},
+				{Description: "Nightly rate (Easter)", Quantity: 2, UnitAmount: 20000, Amount: 40000},
+			},
+			subtotal: 60000,
+		},
+		{
+			name:  "season end date is exclusive",
+			start: march(23), end: march(26), // Mon to Thu, season covers Mon and Tue
+			want: []models.LineItem{
+				{Description: "Nightly rate (Early)", Quantity: 2, UnitAmount: 12000, Amount: 24000},
+				{Description: "Nightly rate", Quantity: 1, UnitAmount: 10000, Amount: 10000},
+			},
+			subtotal: 34000,
+		},
+		{
+			name:  "times of day are ignored",
+			start: march(2).Add(15 * time.Hour), end: march(3).Add(10 * time.Hour),
+			want: []models.LineItem{
+				{Description: "Nightly rate", Quantity: 1, UnitAmount: 10000, Amount: 10000},
+			},
+			subtotal: 10000,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := QuoteStay(plan, tc.start, tc.end)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(q.LineItems) != len(tc.want) {
+				t.Fatalf("line items = %+v, want %+v", q.LineItems, tc.want)
+			}
+			for i, it := range q.LineItems {
+				if it != tc.want[i] {
+					t.Errorf("line %d = %+v, want %+v", i, it, tc.want[i])
+				}
+			}
+			if q.Subtotal != tc.subtotal || q.Tax != 0 || q.Total != tc.subtotal || q.Currency != "KES" {
+				t.Errorf("quote = %+v, want subtotal and total %d", q, tc.subtotal)
+			}
+		})
+	}
+}
+
+func TestQuoteStayMinStay(t *testing.T) {
+	plan := models.RatePlan{
+		Currency:    "KES",
+		BaseNightly: 10000,
+		MinStay:     3,
+		Seasons: []models.SeasonalRate{
+			{Name: "Peak", StartDate: march(9), EndDate: march(16), Nightly: 20000, MinStay: 5},
+			{Name: "Quiet", StartDate: march(23), EndDate: march(30), Nightly: 8000, MinStay: 1},
+			{Name: "Shoulder", StartDate: march(16), EndDate: march(23), Nightly: 9000},
+		},
+	}
+
+	cases := []struct {
+		name       string
+		start, end time.Time
+		wantMin    int // 0 when the stay is allowed
+	}{
+		{"plan minimum met", march(2), march(5), 0},
+		{"plan minimum missed", march(2), march(4), 3},
+		{"season raises the minimum", march(9), march(13), 5},
+		{"season minimum met", march(9), march(14), 0},
+		{"season lowers the minimum", march(23), march(24), 0},
+		{"season without a minimum keeps the plan's", march(16), march(18), 3},
+		{"minimum follows the season the stay starts in", march(8), march(10), 3},
+	}
+	for _, tc := range cases {
+		_, err := QuoteStay(plan, tc.start, tc.end)
+		var minErr MinStayError
+		switch {
+		case tc.wantMin == 0 && err != nil:
+			t.Errorf("%s: %v", tc.name, err)
+		case tc.wantMin != 0 && (!errors.As(err, &minErr) || minErr.MinStay != tc.wantMin):
+			t.Errorf("%s: got %v, want minimum stay of %d", tc.name, err, tc.wantMin)
+		}
+	}
+}
+
+func TestQuoteStayTax(t *testing.T) {
+	cases := []struct {
+		name          string
+		plan          models.RatePlan
+		nights        int
+		subtotal, tax int64
+		wantTaxLine   string
+	}{
+		{
+			name:     "rounds down to a minor unit",
+			plan:     models.RatePlan{Currency: "USD", BaseNightly: 3333, TaxPercent: 16},
+			nights:   1,
+			subtotal: 3333, tax: 533, // 533.28
+			wantTaxLine: "Tax (16%)",
+		},
+		{
+			name:     "rounds half up",
+			plan:     models.RatePlan{Currency: "USD", BaseNightly: 1010, TaxPercent: 5},
+			nights:   1,
+			subtotal: 1010, tax: 51, // 50.5
+			wantTaxLine: "Tax (5%)",
+		},
+		{
+			name:     "fractional rate",
+			plan:     models.RatePlan{Currency: "USD", BaseNightly: 1001, TaxPercent: 7.5},
+			nights:   2,
+			subtotal: 2002, tax: 150, // 150.15
+			wantTaxLine: "Tax (7.5%)",
+		},
+		{
+			name:     "cleaning fee is taxed",
+			plan:     models.RatePlan{Currency: "KES", BaseNightly: 10000, CleaningFee: 2500, TaxPercent: 10},
+			nights:   1,
+			subtotal: 12500, tax: 1250,
+			wantTaxLine: "Tax (10%)",
+		},
+		{
+			name:     "no tax line without tax",
+			plan:     models.RatePlan{Currency: "KES", BaseNightly: 10000, CleaningFee: 2500},
+			nights:   1,
+			subtotal: 12500, tax: 0,
+		},
+		{
+			name:     "tax that rounds to zero adds no line",
+			plan:     models.RatePlan{Currency: "KES", BaseNightly: 4, TaxPercent: 10},
+			nights:   1,
+			subtotal: 4, tax: 0,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := QuoteStay(tc.plan, march(2), march(2+tc.nights))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if q.Subtotal != tc.subtotal || q.Tax != tc.tax || q.Total != tc.subtotal+tc.tax {
+				t.Errorf("subtotal %d, tax %d, total %d; want %d, %d, %d", q.Subtotal, q.Tax, q.Total, tc.subtotal, tc.tax, tc.subtotal+tc.tax)
+			}
+
+			last := q.LineItems[len(q.LineItems)-1]
+			if tc.wantTaxLine == "" {
+				if strings.HasPrefix(last.Description, "Tax") {
+					t.Errorf("unexpected tax line %+v", last)
+				}
+				return
+			}
+			if last.Description != tc.wantTaxLine || last.Amount != tc.tax {
+				t.Errorf("tax line = %+v, want %q for %d", last, tc.wantTaxLine, tc.tax)
+			}
+			if tc.plan.CleaningFee > 0 {
+				fee := q.LineItems[len(q.LineItems)-2]
+				if fee.Description != "Cleaning fee" || fee.Amount != tc.plan.CleaningFee {
+					t.Errorf("cleaning line = %+v", fee)
+				}
+			}
+		})
+	}
+}
+
+func TestQuoteStayNoNights(t *testing.T) {
+	plan := models.RatePlan{Currency: "KES", BaseNightly: 10000}
+	cases := map[string][2]time.Time{
+		"zero nights":          {march(2), march(2)},
+		"same day, later time": {march(2).Add(9 * time.Hour), march(2).Add(18 * time.Hour)},
+		"end before start":     {march(5), march(2)},
+		"one day before start": {march(3), march(2)},
+	}
+	for name, r := range cases {
+		if q, err := QuoteStay(plan, r[0], r[1]); err == nil {
+			t.Errorf("%s: quoted %+v", name, q)
+		}
+	}
+}
+
+func TestValidateRatePlan(t *testing.T) {
+	valid := models.RatePlan{Currency: "KES", BaseNightly: 10000}
+	season := models.SeasonalRate{Name: "Peak", StartDate: march(9), EndDate: march(16), Nightly: 20000}
+
+	cases := []struct {
+		name    string
+		edit    func(p *models.RatePlan)
+		wantErr bool
+	}{
+		{"minimal plan", func(p *models.RatePlan) {}, false},
+		{"full plan", func(p *models.RatePlan) {
+			p.WeekendNightly, p.CleaningFee, p.MinStay, p.TaxPercent = 15000, 2500, 2, 16
+			p.Seasons = []models.SeasonalRate{season}
+		}, false},
+		{"currency too short", func(p *models.RatePlan) { p.Currency = "KS" }, true},
+		{"no currency", func(p *models.RatePlan) { p.Currency = "" }, true},
+		{"zero base rate", func(p *models.RatePlan) { p.BaseNightly = 0 }, true},
+		{"negative weekend rate", func(p *models.RatePlan) { p.WeekendNightly = -1 }, true},
+		{"negative cleaning fee", func(p *models.RatePlan) { p.CleaningFee = -1 }, true},
+		{"negative min stay", func(p *models.RatePlan) { p.MinStay = -1 }, true},
+		{"negative tax", func(p *models.RatePlan) { p.TaxPercent = -0.5 }, true},
+		{"tax over 100%", func(p *models.RatePlan) { p.TaxPercent = 100.5 }, true},
+		{"season ends when it starts", func(p *models.RatePlan) {
+			s := season
+			s.EndDate = s.StartDate
+			p.Seasons = []models.SeasonalRate{s}
+		}, true},
+		{"season without a rate", func(p *models.RatePlan) {
+			s := season
+			s.Nightly = 0
+			p.Seasons = []models.SeasonalRate{s}
+		}, true},
+		{"season with negative min stay", func(p *models.RatePlan) {
+			s := season
+			s.MinStay = -2
+			p.Seasons = []models.SeasonalRate{s}
+		}, true},
+	}
+	for _, tc := range cases {
+		p := valid
+		tc.edit(&p)
+		if err := ValidateRatePlan(p); (err != nil) != tc.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tc.name, err, tc.wantErr)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/phillip/backend/config"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrPropertyLocked is returned when another request holds the property lock for too long
+var ErrPropertyLocked = errors.New("property is locked by another booking request")
+
+const (
+	propertyLockTTL  = 10 * time.Second
+	propertyLockWait = 3 * time.Second
+)
+
+// LockProperty serialises booking writes for one property using a lock document
+// in "property_locks" (_id = property id). Our mongod runs standalone, so
+// multi-document transactions are not available. Always call the returned release func.
+func LockProperty(cfg *config.Config, propertyID primitive.ObjectID) (release func(), err error) {
+	col := cfg.MongoClient.Database(cfg.DBName).Collection("property_locks")
+	owner := primitive.NewObjectID()
+	deadline := time.Now().Add(propertyLockWait)
+
+	ctx, cancel := context.WithTimeout(context.Background(), propertyLockWait+2*time.Second)
+	defer cancel()
+
+	for {
+		// Clear a lock left behind by a crashed request
+		_, _ = col.DeleteOne(ctx, bson.M{"_id": propertyID, "expires_at": bson.M{"$lt": time.Now()}})
+
+		_, err := col.InsertOne(ctx, bson.M{
+			"_id":        propertyID,
+			"owner":      owner,
+			"expires_at": time.Now().Add(propertyLockTTL),
+		})
+		if err == nil {
+			break
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			return nil, ErrPropertyLocked
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, _ = col.DeleteOne(ctx, bson.M{"_id": propertyID, "owner": owner})
+	}, nil
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	cases := map[string]bool{
+		"93.184.216.34":          true,
+		"2606:4700::6810:85e5":   true,
+		"127.0.0.1":              false,
+		"10.1.2.3":               false,
+		"172.16.0.1":             false,
+		"192.168.1.1":            false,
+		"169.254.169.254":        false, // cloud metadata
+		"100.64.0.1":             false,
+		"0.0.0.0":                false,
+		"::1":                    false,
+		"fe80::1":                false,
+		"fd00:ec2::254":          false,
+		"::ffff:127.0.0.1":       false,
+		"::ffff:169.254.169.254": false,
+		"64:ff9b::a9fe:a9fe":     false,
+	}
+	for addr, want := range cases {
+		if got := IsPublicIP(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("IsPublicIP(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestPublicHTTPClientRefusesLoopback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached the loopback server")
+	}))
+	defer srv.Close()
+
+	_, err := NewPublicHTTPClient(5 * time.Second).Get(srv.URL)
+	if !errors.Is(err, ErrNonPublicAddress) {
+		t.Fatalf("got %v, want ErrNonPublicAddress", err)
+	}
+}
+
+func TestPublicHTTPClientRefusesRedirectToMetadata(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "http://169.254.169.254/latest/meta-data/", nil)
+	if err := checkPublicRedirect(req, nil); !errors.Is(err, ErrNonPublicAddress) {
+		t.Fatalf("got %v, want ErrNonPublicAddress", err)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, "file:///etc/passwd", nil)
+	if err := checkPublicRedirect(req, nil); err == nil {
+		t.Fatal("expected redirect to file:// to be refused")
+	}
+}
+
+func TestCheckPublicHost(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, host := range []string{"127.0.0.1", "169.254.169.254", "localhost", "::1"} {
+		if err := CheckPublicHost(ctx, host); err == nil {
+			t.Errorf("CheckPublicHost(%s) accepted an internal host", host)
+		}
+	}
+	if err := CheckPublicHost(ctx, "93.184.216.34"); err != nil {
+		t.Errorf("CheckPublicHost(public ip) = %v", err)
+	}
+}
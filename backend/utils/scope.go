@@ -0,0 +1,205 @@
+package utils
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/phillip/backend/config"
+	"github.com/phillip/backend/models"
+)
+
+// Scope is the slice of the data the requesting user is related to. It is
+// loaded once per request by middleware.TenantScope, and list/get/delete
+// handlers AND their own filters with it:
+//
+//   - admins see everything
+//   - hosts and managers see the properties they own, plus those properties'
+//     bookings and reports (and bookings they made themselves)
+//   - cleaners see the properties listing them in Housekeepers, plus those
+//     properties' bookings, and reports on them or written by them
+//   - guests browse every property but only see their own bookings
+type Scope struct {
+	UserID   primitive.ObjectID
+	Role     string
+	Owned    []primitive.ObjectID // properties owned (hosts, managers)
+	Assigned []primitive.ObjectID // properties cleaned (cleaners)
+
+	cfg *config.Config
+}
+
+// matchNothing never matches a stored document
+var matchNothing = bson.M{"_id": primitive.NilObjectID}
+
+// LoadScope resolves the property IDs the user is related to
+func LoadScope(ctx context.Context, cfg *config.Config, userID primitive.ObjectID, role string) (*Scope, error) {
+	s := &Scope{UserID: userID, Role: role, cfg: cfg}
+	props := cfg.MongoClient.Database(cfg.DBName).Collection("properties")
+
+	var filter bson.M
+	switch role {
+	case models.RoleHost, models.RoleManager:
+		filter = bson.M{"user_id": userID}
+	case models.RoleCleaner:
+		filter = bson.M{"housekeepers": userID}
+	default:
+		return s, nil
+	}
+
+	ids, err := distinctIDs(ctx, props, "_id", filter)
+	if err != nil {
+		return nil, err
+	}
+	if role == models.RoleCleaner {
+		s.Assigned = ids
+	} else {
+		s.Owned = ids
+	}
+	return s, nil
+}
+
+// ScopeFrom returns the scope stored on the request by middleware.TenantScope
+func ScopeFrom(c *gin.Context) (*Scope, error) {
+	v, ok := c.Get("scope")
+	if !ok {
+		return nil, errors.New("tenant scope not loaded")
+	}
+	return v.(*Scope), nil
+}
+
+func (s *Scope) IsAdmin() bool { return s.Role == models.RoleAdmin }
+
+// CanAccessProperty reports whether the user owns or cleans the property
+func (s *Scope) CanAccessProperty(id primitive.ObjectID) bool {
+	if s.IsAdmin() {
+		return true
+	}
+	for _, ids := range [][]primitive.ObjectID{s.Owned, s.Assigned} {
+		for _, p := range ids {
+			if p == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Properties restricts a properties filter
+func (s *Scope) Properties(filter bson.M) bson.M {
+	switch s.Role {
+	case models.RoleAdmin, models.RoleGuest:
+		return filter
+	case models.RoleHost, models.RoleManager:
+		return and(filter, bson.M{"user_id": s.UserID})
+	case models.RoleCleaner:
+		return and(filter, bson.M{"housekeepers": s.UserID})
+	}
+	return and(filter, matchNothing)
+}
+
+// Bookings restricts a bookings filter
+func (s *Scope) Bookings(filter bson.M) bson.M {
+	switch s.Role {
+	case models.RoleAdmin:
+		return filter
+	case models.RoleHost, models.RoleManager:
+		return and(filter, bson.M{"$or": bson.A{
+			bson.M{"property_id": bson.M{"$in": s.Owned}},
+			bson.M{"user_id": s.UserID},
+		}})
+	case models.RoleCleaner:
+		return and(filter, bson.M{"property_id": bson.M{"$in": s.Assigned}})
+	case models.RoleGuest:
+		return and(filter, bson.M{"user_id": s.UserID})
+	}
+	return and(filter, matchNothing)
+}
+
+// Reports restricts a housekeeper_reports filter
+func (s *Scope) Reports(filter bson.M) bson.M {
+	switch s.Role {
+	case models.RoleAdmin:
+		return filter
+	case models.RoleHost, models.RoleManager:
+		return and(filter, bson.M{"property_id": bson.M{"$in": s.Owned}})
+	case models.RoleCleaner:
+		return and(filter, bson.M{"$or": bson.A{
+			bson.M{"property_id": bson.M{"$in": s.Assigned}},
+			bson.M{"housekeeper_id": s.UserID},
+		}})
+	}
+	return and(filter, matchNothing)
+}
+
+// Tasks restricts a cleaning_tasks filter: owners see every task on their
+// properties, cleaners only the tasks assigned to them
+func (s *Scope) Tasks(filter bson.M) bson.M {
+	switch s.Role {
+	case models.RoleAdmin:
+		return filter
+	case models.RoleHost, models.RoleManager:
+		return and(filter, bson.M{"property_id": bson.M{"$in": s.Owned}})
+	case models.RoleCleaner:
+		return and(filter, bson.M{"housekeeper_id": s.UserID})
+	}
+	return and(filter, matchNothing)
+}
+
+// Users restricts a users filter to the people the user works with: hosts see
+// their cleaners and guests, cleaners see the owners they work for, and
+// everyone sees themselves.
+func (s *Scope) Users(ctx context.Context, filter bson.M) (bson.M, error) {
+	if s.IsAdmin() {
+		return filter, nil
+	}
+
+	db := s.cfg.MongoClient.Database(s.cfg.DBName)
+	related := []primitive.ObjectID{s.UserID}
+
+	switch s.Role {
+	case models.RoleHost, models.RoleManager:
+		cleaners, err := distinctIDs(ctx, db.Collection("properties"), "housekeepers", bson.M{"_id": bson.M{"$in": s.Owned}})
+		if err != nil {
+			return nil, err
+		}
+		guests, err := distinctIDs(ctx, db.Collection("bookings"), "user_id", bson.M{"property_id": bson.M{"$in": s.Owned}})
+		if err != nil {
+			return nil, err
+		}
+		related = append(related, cleaners...)
+		related = append(related, guests...)
+	case models.RoleCleaner:
+		owners, err := distinctIDs(ctx, db.Collection("properties"), "user_id", bson.M{"_id": bson.M{"$in": s.Assigned}})
+		if err != nil {
+			return nil, err
+		}
+		related = append(related, owners...)
+	}
+
+	return and(filter, bson.M{"_id": bson.M{"$in": related}}), nil
+}
+
+func and(filter, scope bson.M) bson.M {
+	if len(filter) == 0 {
+		return scope
+	}
+	return bson.M{"$and": bson.A{filter, scope}}
+}
+
+func distinctIDs(ctx context.Context, col *mongo.Collection, field string, filter bson.M) ([]primitive.ObjectID, error) {
+	vals, err := col.Distinct(ctx, field, filter)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(vals))
+	for _, v := range vals {
+		if id, ok := v.(primitive.ObjectID); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
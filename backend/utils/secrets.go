@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/phillip/backend/config"
+)
+
+// Stored secrets use a versioned envelope:
+//
+//	v2:<key id>:<base64url(nonce | AES-256-GCM ciphertext+tag)>
+//
+// The "v2:<key id>" header is authenticated as additional data, so neither the
+// ciphertext nor the key ID can be altered without decryption failing.
+// Anything without the prefix is a legacy AES-CFB value written by Encrypt.
+const secretVersion = "v2"
+
+var (
+	ErrUnknownKeyID    = errors.New("ciphertext uses an unknown key id")
+	ErrSecretTampered  = errors.New("ciphertext failed authentication")
+	ErrMalformedSecret = errors.New("malformed ciphertext")
+)
+
+// EncryptSecret seals plaintext with the active key
+func EncryptSecret(cfg *config.Config, plaintext string) (string, error) {
+	return sealWithKey(cfg.AESKeys, cfg.AESActiveKeyID, plaintext)
+}
+
+// DecryptSecret opens a value written by EncryptSecret with whichever key it
+// names, falling back to the legacy AES-CFB format for older records
+func DecryptSecret(cfg *config.Config, encoded string) (string, error) {
+	if !strings.HasPrefix(encoded, secretVersion+":") {
+		if len(cfg.AESKey) == 0 {
+			return "", errors.New("legacy ciphertext but AES_KEY is not configured")
+		}
+		return Decrypt(cfg.AESKey, encoded)
+	}
+	return openWithKeys(cfg.AESKeys, encoded)
+}
+
+func sealWithKey(keys map[string][]byte, keyID string, plaintext string) (string, error) {
+	key, ok := keys[keyID]
+	if !ok {
+		return "", ErrUnknownKeyID
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	header := secretVersion + ":" + keyID
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), []byte(header))
+	return header + ":" + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+func openWithKeys(keys map[string][]byte, encoded string) (string, error) {
+	parts := strings.SplitN(encoded, ":", 3)
+	if len(parts) != 3 || parts[0] != secretVersion {
+		return "", ErrMalformedSecret
+	}
+	key, ok := keys[parts[1]]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownKeyID, parts[1])
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(raw) < gcm.NonceSize()+gcm.Overhead() {
+		return "", ErrMalformedSecret
+	}
+	nonce, ct := raw[:gcm.NonceSize()], raw[gcm.NonceSize():]
+	pt, err := gcm.Open(nil, nonce, ct, []byte(parts[0]+":"+parts[1]))
+	if err != nil {
+		return "", ErrSecretTampered
+	}
+	return string(pt), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, errors.New("aes key must be 32 bytes")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/phillip/backend/config"
+)
+
+// AccessTokenTTL is how long an access token lives, and so how long a
+// denylist entry has to outlive a revocation
+const AccessTokenTTL = 15 * time.Minute
+
+// RevokeAccessToken denylists one access token until it expires
+func RevokeAccessToken(ctx context.Context, cfg *config.Config, jti string, expiresAt time.Time) error {
+	return denylist(ctx, cfg, jti, expiresAt)
+}
+
+// RevokeSessionTokens denylists every access token already issued for a session
+func RevokeSessionTokens(ctx context.Context, cfg *config.Config, sessionID string) error {
+	return denylist(ctx, cfg, "sid:"+sessionID, time.Now().Add(AccessTokenTTL))
+}
+
+// IsTokenRevoked reports whether an access token, or its session, is denylisted
+func IsTokenRevoked(ctx context.Context, cfg *config.Config, jti, sessionID string) (bool, error) {
+	ids := bson.A{}
+	if jti != "" {
+		ids = append(ids, jti)
+	}
+	if sessionID != "" {
+		ids = append(ids, "sid:"+sessionID)
+	}
+	if len(ids) == 0 {
+		return false, nil
+	}
+
+	err := cfg.MongoClient.Database(cfg.DBName).Collection("revoked_tokens").
+		FindOne(ctx, bson.M{"_id": bson.M{"$in": ids}, "expires_at": bson.M{"$gt": time.Now()}},
+			options.FindOne().SetProjection(bson.M{"_id": 1})).Err()
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// denylist upserts {_id, expires_at} into revoked_tokens, whose TTL index
+// drops entries once the tokens they block have expired
+func denylist(ctx context.Context, cfg *config.Config, id string, expiresAt time.Time) error {
+	_, err := cfg.MongoClient.Database(cfg.DBName).Collection("revoked_tokens").UpdateOne(ctx,
+		bson.M{"_id": id},
+		bson.M{"$max": bson.M{"expires_at": expiresAt}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/phillip/backend/config"
+)
+
+// Token types, carried in the "type" claim so one can't stand in for the other
+const (
+	AccessToken  = "access"
+	RefreshToken = "refresh"
+)
+
+// ErrInvalidToken covers every way a token can fail verification
+var ErrInvalidToken = errors.New("invalid token")
+
+// SignToken signs claims with the active JWT key, adding iss and aud, and
+// names the key in the "kid" header so verifiers can find it in the JWKS
+func SignToken(cfg *config.Config, claims jwt.MapClaims) (string, error) {
+	key, ok := cfg.JWTKeys[cfg.JWTActiveKeyID]
+	if !ok {
+		return "", fmt.Errorf("JWT key %q not loaded", cfg.JWTActiveKeyID)
+	}
+	method, err := signingMethod(key)
+	if err != nil {
+		return "", err
+	}
+
+	claims["iss"] = cfg.JWTIssuer
+	claims["aud"] = cfg.JWTAudience
+
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = cfg.JWTActiveKeyID
+	return token.SignedString(key)
+}
+
+// ParseToken verifies a token of the given type. The algorithm is pinned to
+// the one its kid's key uses, so "none", HMAC and mismatched algorithms are
+// all refused, and iss, aud and exp must be present and valid.
+func ParseToken(cfg *config.Config, tokenStr, tokenType string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := cfg.JWTKeys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key %q", kid)
+		}
+		method, err := signingMethod(key)
+		if err != nil {
+			return nil, err
+		}
+		if token.Method.Alg() != method.Alg() {
+			return nil, fmt.Errorf("key %q does not sign %s", kid, token.Method.Alg())
+		}
+		return key.Public(), nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg(), jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer(cfg.JWTIssuer),
+		jwt.WithAudience(cfg.JWTAudience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil || !token.Valid || claims["type"] != tokenType {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// ParseLegacyRefreshToken verifies an HS256 refresh token signed with
+// JWT_SECRET before tokens moved to asymmetric keys. Refreshing rotates it
+// into a key-signed token, so each one is accepted at most once.
+func ParseLegacyRefreshToken(cfg *config.Config, tokenStr string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
+		return cfg.JWTSecret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil || !token.Valid || claims["type"] != RefreshToken {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// JWKS returns the public half of every loaded JWT key as a JSON Web Key Set
+// (RFC 7517), retired keys included so their tokens verify until they expire
+func JWKS(cfg *config.Config) (map[string]interface{}, error) {
+	kids := make([]string, 0, len(cfg.JWTKeys))
+	for kid := range cfg.JWTKeys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	keys := []map[string]string{}
+	for _, kid := range kids {
+		key := cfg.JWTKeys[kid]
+		jwk := map[string]string{"kid": kid, "use": "sig"}
+		switch pub := key.Public().(type) {
+		case ed25519.PublicKey:
+			jwk["kty"] = "OKP"
+			jwk["crv"] = "Ed25519"
+			jwk["alg"] = jwt.SigningMethodEdDSA.Alg()
+			jwk["x"] = base64.RawURLEncoding.EncodeToString(pub)
+		case *rsa.PublicKey:
+			jwk["kty"] = "RSA"
+			jwk["alg"] = jwt.SigningMethodRS256.Alg()
+			jwk["n"] = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk["e"] = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		default:
+			return nil, fmt.Errorf("unsupported JWT key type %T", pub)
+		}
+		keys = append(keys, jwk)
+	}
+	return map[string]interface{}{"keys": keys}, nil
+}
+
+// signingMethod is the one algorithm a key may be used with
+func signingMethod(key interface{}) (jwt.SigningMethod, error) {
+	switch key.(type) {
+	case ed25519.PrivateKey:
+		return jwt.SigningMethodEdDSA, nil
+	case *rsa.PrivateKey:
+		return jwt.SigningMethodRS256, nil
+	}
+	return nil, fmt.Errorf("unsupported JWT key type %T", key)
+}
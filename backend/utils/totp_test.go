@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+const (
+	rfc6238SeedSHA1   = "12345678901234567890"
+	rfc6238SeedSHA256 = "12345678901234567890123456789012"
+	rfc6238SeedSHA512 = "1234567890123456789012345678901234567890123456789012345678901234"
+)
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	// RFC 6238 Appendix B: 8 digits, 30 second steps
+	cases := []struct {
+		unix                 int64
+		sha1, sha256, sha512 string
+	}{
+		{59, "94287082", "46119246", "90693936"},
+		{1111111109, "07081804", "68084774", "25091201"},
+		{1111111111, "14050471", "67062674", "99943326"},
+		{1234567890, "89005924", "91819424", "93441116"},
+		{2000000000, "69279037", "90698825", "38618901"},
+		{20000000000, "65353130", "77737706", "47863826"},
+	}
+	for _, tc := range cases {
+		now := time.Unix(tc.unix, 0)
+		for _, v := range []struct{ alg, seed, want string }{
+			{"SHA1", rfc6238SeedSHA1, tc.sha1},
+			{"SHA256", rfc6238SeedSHA256, tc.sha256},
+			{"SHA512", rfc6238SeedSHA512, tc.sha512},
+		} {
+			totp := TOTP{Secret: []byte(v.seed), Algorithm: v.alg, Digits: 8, Period: 30}
+			if got, _ := totp.Code(now); got != v.want {
+				t.Errorf("%s at %d: got %s, want %s", v.alg, tc.unix, got, v.want)
+			}
+			if _, ok := totp.Match(v.want, now, 0); !ok {
+				t.Errorf("%s at %d: Match rejected %s", v.alg, tc.unix, v.want)
+			}
+		}
+	}
+}
+
+func TestParseTOTP(t *testing.T) {
+	cases := []struct {
+		name   string
+		input  string
+		want   TOTP
+		wantAt string // code at 1111111109, RFC 6238 Appendix B
+	}{
+		{
+			name:  "bare base32",
+			input: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+			want:  TOTP{Secret: []byte(rfc6238SeedSHA1), Algorithm: "SHA1", Digits: 6, Period: 30},
+			// Last six digits of 07081804
+			wantAt: "081804",
+		},
+		{
+			name:   "bare base32 with spaces, padding and lower case",
+			input:  "  gezd gnbv gy3t qojq gezd gnbv gy3t qojq====  ",
+			want:   TOTP{Secret: []byte(rfc6238SeedSHA1), Algorithm: "SHA1", Digits: 6, Period: 30},
+			wantAt: "081804",
+		},
+		{
+			name:   "uri with defaults",
+			input:  "otpauth://totp/Airbnb:host@example.com?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&issuer=Airbnb",
+			want:   TOTP{Secret: []byte(rfc6238SeedSHA1), Algorithm: "SHA1", Digits: 6, Period: 30, Issuer: "Airbnb", Account: "host@example.com"},
+			wantAt: "081804",
+		},
+		{
+			name:   "uri with sha256 and 8 digits",
+			input:  "otpauth://totp/host@example.com?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA&algorithm=sha256&digits=8&period=30",
+			want:   TOTP{Secret: []byte(rfc6238SeedSHA256), Algorithm: "SHA256", Digits: 8, Period: 30, Account: "host@example.com"},
+			wantAt: "68084774",
+		},
+		{
+			name:   "uri with sha512, 8 digits and issuer parameter",
+			input:  "otpauth://totp/Booking:front-desk?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNA&algorithm=SHA512&digits=8&issuer=Booking.com",
+			want:   TOTP{Secret: []byte(rfc6238SeedSHA512), Algorithm: "SHA512", Digits: 8, Period: 30, Issuer: "Booking.com", Account: "front-desk"},
+			wantAt: "25091201",
+		},
+		{
+			name:  "uri with a 60 second period",
+			input: "OTPAUTH://TOTP/vrbo?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&digits=7&period=60",
+			want:  TOTP{Secret: []byte(rfc6238SeedSHA1), Algorithm: "SHA1", Digits: 7, Period: 60, Account: "vrbo"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseTOTP(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got.Secret) != string(tc.want.Secret) || got.Algorithm != tc.want.Algorithm || got.Digits != tc.want.Digits ||
+				got.Period != tc.want.Period || got.Issuer != tc.want.Issuer || got.Account != tc.want.Account {
+				t.Fatalf("got %+v, want %+v", got, tc.want)
+			}
+			if tc.wantAt != "" {
+				if code, _ := got.Code(time.Unix(1111111109, 0)); code != tc.wantAt {
+					t.Errorf("code = %s, want %s", code, tc.wantAt)
+				}
+			}
+
+			// What we store parses back to the same generator
+			again, err := ParseTOTP(got.URI())
+			if err != nil || string(again.Secret) != string(got.Secret) || again.Algorithm != got.Algorithm ||
+				again.Digits != got.Digits || again.Period != got.Period || again.Issuer != got.Issuer || again.Account != got.Account {
+				t.Errorf("URI round trip = %+v, %v", again, err)
+			}
+		})
+	}
+}
+
+func TestParseTOTPRejects(t *testing.T) {
+	cases := map[string]string{
+		"empty":              "",
+		"not base32":         "not-a-secret!",
+		"key too short":      "GEZDGNBV",
+		"hotp uri":           "otpauth://hotp/x?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&counter=1",
+		"missing secret":     "otpauth://totp/x?digits=6",
+		"unknown algorithm":  "otpauth://totp/x?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&algorithm=MD5",
+		"too few digits":     "otpauth://totp/x?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&digits=4",
+		"too many digits":    "otpauth://totp/x?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&digits=10",
+		"non-numeric digits": "otpauth://totp/x?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&digits=six",
+		"zero period":        "otpauth://totp/x?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&period=0",
+		"period over 5 mins": "otpauth://totp/x?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&period=301",
+	}
+	for name, input := range cases {
+		if got, err := ParseTOTP(input); err == nil {
+			t.Errorf("%s: accepted as %+v", name, got)
+		}
+	}
+	if _, err := ParseTOTP("GEZD"); !errors.Is(err, ErrInvalidTOTP) {
+		t.Errorf("short key: got %v, want ErrInvalidTOTP", err)
+	}
+}
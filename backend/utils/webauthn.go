@@ -0,0 +1,290 @@
+package utils
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+
+	"github.com/phillip/backend/config"
+)
+
+// COSE algorithms accepted for passkeys, most preferred first
+const (
+	COSEAlgEdDSA = -8
+	COSEAlgES256 = -7
+	COSEAlgRS256 = -257
+)
+
+// PasskeyAlgorithms is offered to browsers as pubKeyCredParams
+var PasskeyAlgorithms = []int{COSEAlgEdDSA, COSEAlgES256, COSEAlgRS256}
+
+// Authenticator data flags
+const (
+	flagUserPresent    = 0x01
+	flagUserVerified   = 0x04
+	flagBackupEligible = 0x08
+	flagBackedUp       = 0x10
+	flagAttested       = 0x40
+)
+
+// RelyingParty is who passkeys are created for: ID is the domain they are
+// scoped to, Origins the pages allowed to use them
+type RelyingParty struct {
+	ID      string
+	Name    string
+	Origins []string
+}
+
+// WebAuthnRP returns the relying party from WEBAUTHN_RP_ID and WEBAUTHN_ORIGINS
+func WebAuthnRP(cfg *config.Config) RelyingParty {
+	return RelyingParty{ID: cfg.WebAuthnRPID, Name: TOTPIssuer, Origins: cfg.WebAuthnOrigins}
+}
+
+// PasskeyCredential is a newly registered passkey
+type PasskeyCredential struct {
+	ID             []byte
+	PublicKey      []byte // COSE_Key, as the authenticator sent it
+	Algorithm      int
+	SignCount      uint32
+	BackupEligible bool
+	BackedUp       bool
+}
+
+// NewPasskeyChallenge returns a random challenge, base64url encoded as
+// browsers echo it back in clientDataJSON
+func NewPasskeyChallenge() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// clientData is the part of clientDataJSON that is checked
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// PasskeyChallengeOf returns the challenge a ceremony answers, so the
+// stored challenge can be found before the response is verified
+func PasskeyChallengeOf(clientDataJSON []byte) (string, error) {
+	var cd clientData
+	if err := json.Unmarshal(clientDataJSON, &cd); err != nil || cd.Challenge == "" {
+		return "", errors.New("webauthn: malformed clientDataJSON")
+	}
+	return cd.Challenge, nil
+}
+
+// VerifyPasskeyRegistration checks a navigator.credentials.create() response
+// and returns the new credential. The passkey must have verified the user
+// (PIN or biometric), since it will stand in for both login factors.
+// Attestation statements are not checked: the ceremony asks for "none", and
+// nothing here depends on the authenticator's make.
+func VerifyPasskeyRegistration(rp RelyingParty, challenge string, clientDataJSON, attestationObject []byte) (PasskeyCredential, error) {
+	var cred PasskeyCredential
+	if err := rp.checkClientData(clientDataJSON, "webauthn.create", challenge); err != nil {
+		return cred, err
+	}
+
+	obj, _, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return cred, fmt.Errorf("webauthn: attestation object: %w", err)
+	}
+	fields, ok := obj.(map[interface{}]interface{})
+	if !ok {
+		return cred, errors.New("webauthn: attestation object is not a map")
+	}
+	raw, ok := fields["authData"].([]byte)
+	if !ok {
+		return cred, errors.New("webauthn: attestation object has no authData")
+	}
+
+	auth, err := rp.parseAuthenticatorData(raw)
+	if err != nil {
+		return cred, err
+	}
+	if auth.flags&flagAttested == 0 || len(auth.credentialID) == 0 {
+		return cred, errors.New("webauthn: no credential in authenticator data")
+	}
+
+	alg, _, err := parseCOSEKey(auth.publicKey)
+	if err != nil {
+		return cred, err
+	}
+
+	return PasskeyCredential{
+		ID:             auth.credentialID,
+		PublicKey:      auth.publicKey,
+		Algorithm:      alg,
+		SignCount:      auth.signCount,
+		BackupEligible: auth.flags&flagBackupEligible != 0,
+		BackedUp:       auth.flags&flagBackedUp != 0,
+	}, nil
+}
+
+// VerifyPasskeyAssertion checks a navigator.credentials.get() response
+// against a stored passkey and returns the new signature counter. A counter
+// that fails to advance means the authenticator may have been cloned.
+func VerifyPasskeyAssertion(rp RelyingParty, challenge string, publicKey []byte, signCount uint32, clientDataJSON, authenticatorData, signature []byte) (uint32, error) {
+	if err := rp.checkClientData(clientDataJSON, "webauthn.get", challenge); err != nil {
+		return 0, err
+	}
+	auth, err := rp.parseAuthenticatorData(authenticatorData)
+	if err != nil {
+		return 0, err
+	}
+
+	alg, key, err := parseCOSEKey(publicKey)
+	if err != nil {
+		return 0, err
+	}
+	clientHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte(nil), authenticatorData...), clientHash[:]...)
+	if !verifyCOSESignature(alg, key, signed, signature) {
+		return 0, errors.New("webauthn: bad signature")
+	}
+
+	// Authenticators that don't count always send 0
+	if (auth.signCount != 0 || signCount != 0) && auth.signCount <= signCount {
+		return 0, errors.New("webauthn: signature counter went backwards")
+	}
+	return auth.signCount, nil
+}
+
+func (rp RelyingParty) checkClientData(raw []byte, typ, challenge string) error {
+	var cd clientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return errors.New("webauthn: malformed clientDataJSON")
+	}
+	if cd.Type != typ {
+		return fmt.Errorf("webauthn: expected %s, got %q", typ, cd.Type)
+	}
+	if subtle.ConstantTimeCompare([]byte(cd.Challenge), []byte(challenge)) != 1 {
+		return errors.New("webauthn: challenge mismatch")
+	}
+	if !slices.Contains(rp.Origins, cd.Origin) {
+		return fmt.Errorf("webauthn: origin %q not allowed", cd.Origin)
+	}
+	return nil
+}
+
+type authenticatorData struct {
+	flags        byte
+	signCount    uint32
+	credentialID []byte
+	publicKey    []byte
+}
+
+// parseAuthenticatorData reads authenticator data and checks it was made
+// for this relying party with the user present and verified
+func (rp RelyingParty) parseAuthenticatorData(b []byte) (authenticatorData, error) {
+	var auth authenticatorData
+	if len(b) < 37 {
+		return auth, errors.New("webauthn: authenticator data too short")
+	}
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(b[:32], rpIDHash[:]) {
+		return auth, errors.New("webauthn: credential is for another relying party")
+	}
+	auth.flags = b[32]
+	auth.signCount = binary.BigEndian.Uint32(b[33:37])
+	if auth.flags&flagUserPresent == 0 || auth.flags&flagUserVerified == 0 {
+		return auth, errors.New("webauthn: user was not verified")
+	}
+
+	if auth.flags&flagAttested != 0 {
+		rest := b[37:]
+		if len(rest) < 18 {
+			return auth, errors.New("webauthn: attested credential data too short")
+		}
+		idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLen == 0 || idLen > 1023 || len(rest) < idLen {
+			return auth, errors.New("webauthn: bad credential ID")
+		}
+		auth.credentialID = append([]byte(nil), rest[:idLen]...)
+		rest = rest[idLen:]
+
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return auth, fmt.Errorf("webauthn: credential public key: %w", err)
+		}
+		auth.publicKey = append([]byte(nil), rest[:len(rest)-len(after)]...)
+	}
+	return auth, nil
+}
+
+// parseCOSEKey reads a COSE_Key (RFC 9053) for one of PasskeyAlgorithms
+func parseCOSEKey(raw []byte) (int, crypto.PublicKey, error) {
+	v, _, err := decodeCBOR(raw)
+	if err != nil {
+		return 0, nil, fmt.Errorf("webauthn: public key: %w", err)
+	}
+	m, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return 0, nil, errors.New("webauthn: public key is not a COSE key")
+	}
+	kty, _ := m[int64(1)].(int64)
+	alg, _ := m[int64(3)].(int64)
+
+	switch {
+	case kty == 1 && alg == COSEAlgEdDSA:
+		x, _ := m[int64(-2)].([]byte)
+		if crv, _ := m[int64(-1)].(int64); crv != 6 || len(x) != ed25519.PublicKeySize {
+			return 0, nil, errors.New("webauthn: bad Ed25519 key")
+		}
+		return COSEAlgEdDSA, ed25519.PublicKey(x), nil
+
+	case kty == 2 && alg == COSEAlgES256:
+		x, _ := m[int64(-2)].([]byte)
+		y, _ := m[int64(-3)].([]byte)
+		if crv, _ := m[int64(-1)].(int64); crv != 1 || len(x) != 32 || len(y) != 32 {
+			return 0, nil, errors.New("webauthn: bad P-256 key")
+		}
+		// ecdh rejects points that are not on the curve
+		point := append(append([]byte{4}, x...), y...)
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return 0, nil, errors.New("webauthn: bad P-256 key")
+		}
+		return COSEAlgES256, &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+
+	case kty == 3 && alg == COSEAlgRS256:
+		n, _ := m[int64(-1)].([]byte)
+		e, _ := m[int64(-2)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return 0, nil, errors.New("webauthn: bad RSA key")
+		}
+		return COSEAlgRS256, &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	}
+	return 0, nil, fmt.Errorf("webauthn: unsupported key type %d with algorithm %d", kty, alg)
+}
+
+func verifyCOSESignature(alg int, key crypto.PublicKey, signed, sig []byte) bool {
+	switch alg {
+	case COSEAlgEdDSA:
+		return ed25519.Verify(key.(ed25519.PublicKey), signed, sig)
+	case COSEAlgES256:
+		digest := sha256.Sum256(signed)
+		return ecdsa.VerifyASN1(key.(*ecdsa.PublicKey), digest[:], sig)
+	case COSEAlgRS256:
+		digest := sha256.Sum256(signed)
+		return rsa.VerifyPKCS1v15(key.(*rsa.PublicKey), crypto.SHA256, digest[:], sig) == nil
+	}
+	return false
+}
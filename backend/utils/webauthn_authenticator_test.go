@@ -0,0 +1,213 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"testing"
+)
+
+// softwareAuthenticator is an in-memory passkey. It answers registration and
+// login challenges the way a browser and a platform authenticator would.
+// Origin, RPID and Flags start out honest and can be changed to forge responses;
+// Counterless makes it always report a zero signature counter.
+type softwareAuthenticator struct {
+	Origin       string
+	RPID         string
+	Flags        byte
+	Counterless  bool
+	CredentialID []byte
+	SignCount    uint32
+	alg          int
+	key          crypto.Signer
+}
+
+func newSoftwareAuthenticator(t *testing.T, rp RelyingParty, alg int) *softwareAuthenticator {
+	t.Helper()
+
+	var (
+		key crypto.Signer
+		err error
+	)
+	switch alg {
+	case COSEAlgEdDSA:
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	case COSEAlgES256:
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case COSEAlgRS256:
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	default:
+		t.Fatalf("unsupported algorithm %d", alg)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		t.Fatal(err)
+	}
+	return &softwareAuthenticator{
+		Origin:       rp.Origins[0],
+		RPID:         rp.ID,
+		Flags:        flagUserPresent | flagUserVerified,
+		CredentialID: id,
+		alg:          alg,
+		key:          key,
+	}
+}
+
+// Register answers a registration challenge with "none" attestation
+func (a *softwareAuthenticator) Register(t *testing.T, challenge string) (clientDataJSON, attestationObject []byte) {
+	t.Helper()
+	clientDataJSON = a.clientData(t, "webauthn.create", challenge)
+
+	publicKey, err := encodeCBOR(a.coseKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authData := a.authData(flagAttested)
+	authData = append(authData, make([]byte, 16)...) // AAGUID, zero for software keys
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.CredentialID)))
+	authData = append(append(authData, a.CredentialID...), publicKey...)
+
+	attestationObject, err = encodeCBOR(map[interface{}]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[interface{}]interface{}{},
+		"authData": authData,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return clientDataJSON, attestationObject
+}
+
+// Assert answers a login challenge. Each call advances the counter.
+func (a *softwareAuthenticator) Assert(t *testing.T, challenge string) (clientDataJSON, authenticatorData, signature []byte) {
+	t.Helper()
+	clientDataJSON = a.clientData(t, "webauthn.get", challenge)
+	if !a.Counterless {
+		a.SignCount++
+	}
+	authenticatorData = a.authData(0)
+
+	clientHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte(nil), authenticatorData...), clientHash[:]...)
+
+	var err error
+	if a.alg == COSEAlgEdDSA {
+		signature, err = a.key.Sign(rand.Reader, signed, crypto.Hash(0))
+	} else {
+		digest := sha256.Sum256(signed)
+		signature, err = a.key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return clientDataJSON, authenticatorData, signature
+}
+
+func (a *softwareAuthenticator) coseKey() map[interface{}]interface{} {
+	switch pub := a.key.Public().(type) {
+	case ed25519.PublicKey:
+		return map[interface{}]interface{}{1: 1, 3: COSEAlgEdDSA, -1: 6, -2: []byte(pub)}
+	case *ecdsa.PublicKey:
+		return map[interface{}]interface{}{1: 2, 3: COSEAlgES256, -1: 1, -2: pub.X.FillBytes(make([]byte, 32)), -3: pub.Y.FillBytes(make([]byte, 32))}
+	case *rsa.PublicKey:
+		return map[interface{}]interface{}{1: 3, 3: COSEAlgRS256, -1: pub.N.Bytes(), -2: big.NewInt(int64(pub.E)).Bytes()}
+	}
+	panic(fmt.Sprintf("unexpected key %T", a.key.Public()))
+}
+
+func (a *softwareAuthenticator) clientData(t *testing.T, typ, challenge string) []byte {
+	t.Helper()
+	b, err := json.Marshal(map[string]interface{}{
+		"type":        typ,
+		"challenge":   challenge,
+		"origin":      a.Origin,
+		"crossOrigin": false,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// authData is the fixed part of authenticator data: RP ID hash, flags and the counter
+func (a *softwareAuthenticator) authData(extra byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.RPID))
+	b := append([]byte(nil), rpIDHash[:]...)
+	b = append(b, a.Flags|extra)
+	return binary.BigEndian.AppendUint32(b, a.SignCount)
+}
+
+// encodeCBOR encodes int, int64, []byte, string and maps keyed by int or
+// string, with map keys in canonical order
+func encodeCBOR(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case int:
+		return encodeCBOR(int64(v))
+	case int64:
+		if v < 0 {
+			return cborHead(1, uint64(-1-v)), nil
+		}
+		return cborHead(0, uint64(v)), nil
+	case []byte:
+		return append(cborHead(2, uint64(len(v))), v...), nil
+	case string:
+		return append(cborHead(3, uint64(len(v))), v...), nil
+	case map[interface{}]interface{}:
+		type entry struct{ key, value []byte }
+		entries := make([]entry, 0, len(v))
+		for k, val := range v {
+			key, err := encodeCBOR(k)
+			if err != nil {
+				return nil, err
+			}
+			value, err := encodeCBOR(val)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry{key, value})
+		}
+		// Canonical order: shorter keys first, then bytewise
+		sort.Slice(entries, func(i, j int) bool {
+			a, b := entries[i].key, entries[j].key
+			if len(a) != len(b) {
+				return len(a) < len(b)
+			}
+			return string(a) < string(b)
+		})
+		out := cborHead(5, uint64(len(entries)))
+		for _, e := range entries {
+			out = append(append(out, e.key...), e.value...)
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("cbor: cannot encode %T", v)
+}
+
+func cborHead(major byte, arg uint64) []byte {
+	switch {
+	case arg < 24:
+		return []byte{major<<5 | byte(arg)}
+	case arg <= math.MaxUint8:
+		return []byte{major<<5 | 24, byte(arg)}
+	case arg <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(arg))
+	case arg <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(arg))
+	}
+	return binary.BigEndian.AppendUint64([]byte{major<<5 | 27}, arg)
+}
@@ -0,0 +1,187 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+var testRP = RelyingParty{ID: "unitwise.example", Name: "Unit Wise", Origins: []string{"https://app.unitwise.example"}}
+
+var passkeyAlgorithms = map[string]int{"EdDSA": COSEAlgEdDSA, "ES256": COSEAlgES256, "RS256": COSEAlgRS256}
+
+func mustChallenge(t *testing.T) string {
+	t.Helper()
+	c, err := NewPasskeyChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func registerPasskey(t *testing.T, a *softwareAuthenticator) PasskeyCredential {
+	t.Helper()
+	challenge := mustChallenge(t)
+	clientData, attestation := a.Register(t, challenge)
+	cred, err := VerifyPasskeyRegistration(testRP, challenge, clientData, attestation)
+	if err != nil {
+		t.Fatalf("VerifyPasskeyRegistration: %v", err)
+	}
+	return cred
+}
+
+func TestPasskeyRoundTrip(t *testing.T) {
+	for name, alg := range passkeyAlgorithms {
+		t.Run(name, func(t *testing.T) {
+			a := newSoftwareAuthenticator(t, testRP, alg)
+			cred := registerPasskey(t, a)
+
+			if string(cred.ID) != string(a.CredentialID) || cred.Algorithm != alg || cred.SignCount != 0 {
+				t.Fatalf("unexpected credential: %+v", cred)
+			}
+
+			count := cred.SignCount
+			for i := 0; i < 3; i++ {
+				challenge := mustChallenge(t)
+				clientData, authData, sig := a.Assert(t, challenge)
+
+				got, err := PasskeyChallengeOf(clientData)
+				if err != nil || got != challenge {
+					t.Fatalf("PasskeyChallengeOf = %q, %v", got, err)
+				}
+
+				count, err = VerifyPasskeyAssertion(testRP, challenge, cred.PublicKey, count, clientData, authData, sig)
+				if err != nil {
+					t.Fatalf("assertion %d: %v", i, err)
+				}
+			}
+			if count != 3 {
+				t.Errorf("sign count = %d, want 3", count)
+			}
+		})
+	}
+}
+
+func TestPasskeyRegistrationRejects(t *testing.T) {
+	cases := map[string]struct {
+		forge func(a *softwareAuthenticator)
+		want  string
+	}{
+		"wrong origin":     {func(a *softwareAuthenticator) { a.Origin = "https://phish.example" }, "origin"},
+		"wrong rp id hash": {func(a *softwareAuthenticator) { a.RPID = "phish.example" }, "another relying party"},
+		"missing uv flag":  {func(a *softwareAuthenticator) { a.Flags = flagUserPresent }, "not verified"},
+		"missing up flag":  {func(a *softwareAuthenticator) { a.Flags = flagUserVerified }, "not verified"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			a := newSoftwareAuthenticator(t, testRP, COSEAlgEdDSA)
+			tc.forge(a)
+
+			challenge := mustChallenge(t)
+			clientData, attestation := a.Register(t, challenge)
+			_, err := VerifyPasskeyRegistration(testRP, challenge, clientData, attestation)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("got %v, want error containing %q", err, tc.want)
+			}
+		})
+	}
+
+	t.Run("assertion instead of registration", func(t *testing.T) {
+		a := newSoftwareAuthenticator(t, testRP, COSEAlgEdDSA)
+		challenge := mustChallenge(t)
+		clientData, _, _ := a.Assert(t, challenge)
+		_, attestation := a.Register(t, challenge)
+		if _, err := VerifyPasskeyRegistration(testRP, challenge, clientData, attestation); err == nil {
+			t.Fatal("accepted webauthn.get client data for registration")
+		}
+	})
+}
+
+func TestPasskeyAssertionRejects(t *testing.T) {
+	for name, alg := range passkeyAlgorithms {
+		t.Run(name, func(t *testing.T) {
+			a := newSoftwareAuthenticator(t, testRP, alg)
+			cred := registerPasskey(t, a)
+
+			assertErr := func(t *testing.T, challenge string, signCount uint32, clientData, authData, sig []byte, want string) {
+				t.Helper()
+				_, err := VerifyPasskeyAssertion(testRP, challenge, cred.PublicKey, signCount, clientData, authData, sig)
+				if err == nil || !strings.Contains(err.Error(), want) {
+					t.Fatalf("got %v, want error containing %q", err, want)
+				}
+			}
+
+			t.Run("wrong origin", func(t *testing.T) {
+				a.Origin = "https://phish.example"
+				defer func() { a.Origin = testRP.Origins[0] }()
+				challenge := mustChallenge(t)
+				cd, ad, sig := a.Assert(t, challenge)
+				assertErr(t, challenge, 0, cd, ad, sig, "origin")
+			})
+
+			t.Run("wrong rp id hash", func(t *testing.T) {
+				a.RPID = "phish.example"
+				defer func() { a.RPID = testRP.ID }()
+				challenge := mustChallenge(t)
+				cd, ad, sig := a.Assert(t, challenge)
+				assertErr(t, challenge, 0, cd, ad, sig, "another relying party")
+			})
+
+			t.Run("missing uv flag", func(t *testing.T) {
+				a.Flags = flagUserPresent
+				defer func() { a.Flags = flagUserPresent | flagUserVerified }()
+				challenge := mustChallenge(t)
+				cd, ad, sig := a.Assert(t, challenge)
+				assertErr(t, challenge, 0, cd, ad, sig, "not verified")
+			})
+
+			t.Run("replayed challenge", func(t *testing.T) {
+				old := mustChallenge(t)
+				cd, ad, sig := a.Assert(t, old)
+				count, err := VerifyPasskeyAssertion(testRP, old, cred.PublicKey, 0, cd, ad, sig)
+				if err != nil {
+					t.Fatal(err)
+				}
+				// The same response presented for the next login's challenge
+				assertErr(t, mustChallenge(t), count, cd, ad, sig, "challenge mismatch")
+				// Or replayed against its own challenge after it was used
+				assertErr(t, old, count, cd, ad, sig, "counter")
+			})
+
+			t.Run("counter regression", func(t *testing.T) {
+				challenge := mustChallenge(t)
+				cd, ad, sig := a.Assert(t, challenge)
+				assertErr(t, challenge, a.SignCount+10, cd, ad, sig, "counter")
+			})
+
+			t.Run("tampered authenticator data", func(t *testing.T) {
+				challenge := mustChallenge(t)
+				cd, ad, sig := a.Assert(t, challenge)
+				ad[36] ^= 0xff // counter bytes are covered by the signature
+				assertErr(t, challenge, 0, cd, ad, sig, "bad signature")
+			})
+
+			t.Run("signature by another key", func(t *testing.T) {
+				other := newSoftwareAuthenticator(t, testRP, alg)
+				challenge := mustChallenge(t)
+				cd, ad, sig := other.Assert(t, challenge)
+				assertErr(t, challenge, 0, cd, ad, sig, "bad signature")
+			})
+		})
+	}
+}
+
+func TestPasskeyCounterlessAuthenticator(t *testing.T) {
+	// Authenticators that don't implement a counter always report 0
+	a := newSoftwareAuthenticator(t, testRP, COSEAlgEdDSA)
+	a.Counterless = true
+	cred := registerPasskey(t, a)
+
+	for i := 0; i < 2; i++ {
+		challenge := mustChallenge(t)
+		cd, ad, sig := a.Assert(t, challenge)
+		count, err := VerifyPasskeyAssertion(testRP, challenge, cred.PublicKey, 0, cd, ad, sig)
+		if err != nil || count != 0 {
+			t.Fatalf("assertion %d: count %d, %v", i, count, err)
+		}
+	}
+}
@@ -3,7 +3,10 @@ import { Component, inject, OnInit, signal } from '@angular/core';
 import { ActivatedRoute, RouterModule } from '@angular/router';
 import { HotToastService } from '@ngneat/hot-toast';
 import { PropertyService } from '../../../../shared/services/propertyService';
-import { PropertyResponseModel } from '../../../../shared/models/properties-model';
+import {
+  NightStatus,
+  PropertyResponseModel,
+} from '../../../../shared/models/properties-model';
 
 @Component({
   selector: 'app-property-details',
@@ -18,6 +21,8 @@ export class PropertyDetails implements OnInit {
 
   property = signal<PropertyResponseModel>({});
   selectedImage = signal<string>('');
+  // tonight's status from the availability calendar, null until it loads
+  tonight = signal<NightStatus | null>(null);
 
   ngOnInit(): void {
     this.getProperty();
@@ -41,6 +46,15 @@ export class PropertyDetails implements OnInit {
         this.toastService.error('Failed to fetch property details');
       },
     });
+
+    this.propertyService.getPropertyAvailability(id).subscribe({
+      next: (res) => {
+        this.tonight.set(res.nights[0]?.status ?? null);
+      },
+      error: () => {
+        this.tonight.set(null);
+      },
+    });
   }
 
   selectImage(image: string) {
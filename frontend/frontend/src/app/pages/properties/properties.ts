@@ -28,6 +28,8 @@ export class Properties implements OnInit {
 
   isLayout = signal<boolean>(true);
   properties = signal<PropertyResponseModel[]>([]);
+  nextCursor = signal<string | undefined>(undefined);
+  totalProperties = signal<number>(0);
 
   createPropertyForm = this.fb.nonNullable.group({
     title: ['', [Validators.required]],
@@ -45,12 +47,28 @@ export class Properties implements OnInit {
   }
 
   getAllProperties() {
+    this.loadProperties();
+  }
+
+  loadMoreProperties() {
+    const cursor = this.nextCursor();
+    if (cursor) {
+      this.loadProperties(cursor);
+    }
+  }
+
+  // first page replaces the list, later pages are appended
+  private loadProperties(cursor?: string) {
     const loadingToast = this.toastService.loading('Processing...');
 
-    this.propertyService.getAllProperty().subscribe({
+    this.propertyService.getAllProperty(cursor).subscribe({
       next: (res) => {
         loadingToast.close();
-        this.properties.set(res);
+        this.properties.set(
+          cursor ? [...this.properties(), ...res.data] : res.data
+        );
+        this.nextCursor.set(res.next_cursor || undefined);
+        this.totalProperties.set(res.total);
       },
       error: (err) => {
         loadingToast.close();
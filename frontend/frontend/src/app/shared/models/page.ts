@@ -0,0 +1,6 @@
+// Envelope returned by the API's list endpoints
+export interface PageResponse<T> {
+  data: T[];
+  next_cursor?: string;
+  total: number;
+}
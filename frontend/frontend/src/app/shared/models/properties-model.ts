@@ -4,7 +4,6 @@ export interface CreatePropertyModel {
   location?: string;
   price?: number;
   images?: string[];
-  availability?: boolean;
 }
 
 export interface UpdatePropertyModel {
@@ -20,8 +19,27 @@ export interface PropertyResponseModel {
   location?: string;
   price?: number;
   images?: string[];
-  availability?: boolean;
   housekeepers?: string[];
   created_at?: string;
   updated_at?: string;
 }
+
+// GET /properties/:id/availability, one entry per night
+export type NightStatus = 'free' | 'booked' | 'blocked';
+
+export interface PropertyNightModel {
+  date: string;
+  status: NightStatus;
+  booking_id?: string;
+  blackout_id?: string;
+}
+
+export interface PropertyAvailabilityModel {
+  property_id: string;
+  from: string;
+  to: string;
+  free_nights: number;
+  booked_nights: number;
+  blocked_nights: number;
+  nights: PropertyNightModel[];
+}
@@ -1,14 +1,16 @@
 import { Inject, Injectable, inject } from '@angular/core';
-import { HttpClient } from '@angular/common/http';
+import { HttpClient, HttpParams } from '@angular/common/http';
 import { Router } from '@angular/router';
 import { map } from 'rxjs/operators';
 
 import { API_CONFIG, ApiConfig } from '../../api.config';
 import {
   CreatePropertyModel,
+  PropertyAvailabilityModel,
   PropertyResponseModel,
   UpdatePropertyModel,
 } from '../models/properties-model';
+import { PageResponse } from '../models/page';
 
 @Injectable({
   providedIn: 'root',
@@ -19,10 +21,14 @@ export class PropertyService {
   router = inject(Router);
   http = inject(HttpClient);
 
-  // get all property
-  getAllProperty() {
+  // get a page of properties, pass the previous page's next_cursor for the next one
+  getAllProperty(cursor?: string, limit = 20) {
     const url = `${this.apiConfig.baseUrl}${this.apiConfig.endpoints.propertyUrl}`;
-    return this.http.get<PropertyResponseModel[]>(url).pipe(
+    let params = new HttpParams().set('limit', limit);
+    if (cursor) {
+      params = params.set('cursor', cursor);
+    }
+    return this.http.get<PageResponse<PropertyResponseModel>>(url, { params }).pipe(
       map((res) => {
         // if (res.status === 200) {
         //   return res;
@@ -45,6 +51,19 @@ export class PropertyService {
     );
   }
 
+  // get a property's per-night calendar, from today for 30 nights unless a range is given
+  getPropertyAvailability(propertyId: string, from?: string, to?: string) {
+    const url = `${this.apiConfig.baseUrl}${this.apiConfig.endpoints.propertyUrl}/${propertyId}/availability`;
+    let params = new HttpParams();
+    if (from) {
+      params = params.set('from', from);
+    }
+    if (to) {
+      params = params.set('to', to);
+    }
+    return this.http.get<PropertyAvailabilityModel>(url, { params });
+  }
+
   // create  property
   createProperty(propertyData: any) {
     const url = `${this.apiConfig.baseUrl}${this.apiConfig.endpoints.propertyUrl}`;